@@ -1,32 +1,21 @@
 package main
 
 import (
+	"errors"
+	"io/fs"
 	"log"
 	"os"
-	"strconv"
+	_ "time/tzdata" // Embeds the time zone database used by link schedules.
 
 	"github.com/joho/godotenv"
 	"github.com/tberk-s/learning-url-shortener-with-go/src/webserver"
 )
 
 func main() {
-	if err := godotenv.Load(); err != nil {
-		log.Fatal("Error loading .env file")
+	// The .env file is optional, its variables never override the environment.
+	if err := godotenv.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Fatal("Error loading .env file: ", err)
 	}
 
-	err := webserver.New(
-		webserver.WithServerEnv(os.Getenv("SERVER_ENV")),
-		webserver.WithDBName(os.Getenv("DB_NAME")),
-		webserver.WithDBHost(os.Getenv("DB_HOST")),
-		webserver.WithDBUser(os.Getenv("DB_USER")),
-		webserver.WithDBPassword(os.Getenv("DB_PASSWORD")),
-		webserver.WithDBPort(func() int {
-			port, _ := strconv.Atoi(os.Getenv("DB_PORT"))
-
-			return port
-		}()),
-	)
-	if err != nil {
-		log.Fatal("Failed to start server:", err)
-	}
+	os.Exit(webserver.Run(os.Args[1:], os.Stdout, os.Stderr))
 }
@@ -1,36 +1,307 @@
 package config
 
 import (
+	"net"
 	"net/http"
-	"os"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/db"
 	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/urlshortenererror"
 )
 
+// DefaultRedirectCode is the status code used when neither the deployment nor the link sets one.
+const DefaultRedirectCode = http.StatusPermanentRedirect
+
+// DefaultInterstitialCountdown is the number of seconds the interstitial page waits by default.
+const DefaultInterstitialCountdown = 5
+
+// Defaults of the link health checker.
+const (
+	DefaultHealthCheckInterval    = 5 * time.Minute
+	DefaultHealthCheckConcurrency = 4
+	DefaultHealthCheckFailures    = 3
+)
+
+// DefaultShutdownDrainDelay is how long the server keeps serving after it
+// reports as not ready, so load balancers stop sending requests first.
+const DefaultShutdownDrainDelay = 5 * time.Second
+
+// Default deadlines of database operations.
+const (
+	DefaultDBReadTimeout  = db.DefaultReadTimeout
+	DefaultDBWriteTimeout = db.DefaultWriteTimeout
+)
+
+// Defaults of the HTTP server.
+const (
+	DefaultListenAddr      = ":8000"
+	DefaultReadTimeout     = 10 * time.Second
+	DefaultWriteTimeout    = 10 * time.Second
+	DefaultIdleTimeout     = 15 * time.Second
+	DefaultShutdownTimeout = 10 * time.Second
+)
+
+// Defaults of the database connection.
+const (
+	DefaultDBHost = "localhost"
+	DefaultDBPort = 5432
+)
+
+// DefaultShortCodeLength is the length of generated short codes.
+const DefaultShortCodeLength = 6
+
+// Bounds of the generated short code length. Shorter codes run out of free
+// combinations quickly, longer ones defeat the purpose of a short URL.
+const (
+	MinShortCodeLength = 4
+	MaxShortCodeLength = 32
+)
+
+// DefaultTraceSampleRatio records every trace.
+const DefaultTraceSampleRatio = 1.0
+
+// MaxInterstitialCountdown is the longest countdown of the interstitial page in seconds.
+const MaxInterstitialCountdown = 60
+
+// DefaultTLSReloadInterval is how often the certificate files are checked for changes.
+const DefaultTLSReloadInterval = time.Minute
+
+// maxPort is the highest TCP port.
+const maxPort = 65535
+
 // Config struct to hold the configuration.
+//
+// Every field can be set in the YAML or TOML configuration file under its
+// yaml key, from the environment variable in its env tag and with the
+// command line flag named after the yaml key, for example --db-host. Fields tagged secret are
+// redacted when the configuration is printed.
 type Config struct {
-	ServerEnv  string
-	DBName     string
-	DBHost     string
-	DBUser     string
-	DBPassword string
-	DBPort     int
+	ServerEnv              string        `yaml:"server_env" env:"SERVER_ENV" usage:"name of the environment the server runs in"`
+	ListenAddr             string        `yaml:"listen_addr" env:"LISTEN_ADDR" usage:"address the HTTP server listens on"`
+	BaseURL                string        `yaml:"base_url" env:"BASE_URL" usage:"public URL short URLs are built on, e.g. https://sho.rt, empty to use the request host"`
+	TLSCertFile            string        `yaml:"tls_cert_file" env:"TLS_CERT_FILE" usage:"PEM certificate chain served over HTTPS, empty to serve HTTP"`
+	TLSKeyFile             string        `yaml:"tls_key_file" env:"TLS_KEY_FILE" usage:"PEM private key of the certificate"`
+	TLSClientCAFile        string        `yaml:"tls_client_ca_file" env:"TLS_CLIENT_CA_FILE" usage:"PEM certificates of the CAs whose client certificates the admin API requires"`
+	HTTPRedirectAddr       string        `yaml:"http_redirect_addr" env:"HTTP_REDIRECT_ADDR" usage:"address of a listener redirecting HTTP to HTTPS, empty disables it"`
+	DatabaseURL            string        `yaml:"database_url" env:"DATABASE_URL" secret:"password" usage:"PostgreSQL connection string, replaces the db_* connection settings"`
+	DBName                 string        `yaml:"db_name" env:"DB_NAME" usage:"database name"`
+	DBHost                 string        `yaml:"db_host" env:"DB_HOST" usage:"database host"`
+	DBUser                 string        `yaml:"db_user" env:"DB_USER" usage:"database user"`
+	DBPassword             string        `yaml:"db_password" env:"DB_PASSWORD" secret:"true" usage:"database password"`
+	DBSSLMode              string        `yaml:"db_sslmode" env:"DB_SSLMODE" usage:"TLS mode of the database connection, disable to verify-full"`
+	RedirectCacheControl   string        `yaml:"redirect_cache_control" env:"REDIRECT_CACHE_CONTROL" usage:"Cache-Control header of redirects"`
+	SecretKey              string        `yaml:"secret_key" env:"SECRET_KEY" secret:"true" usage:"key signing the cookies of unlocked links"`
+	AdminToken             string        `yaml:"admin_token" env:"ADMIN_TOKEN" secret:"true" usage:"bearer token of the admin API and metrics, empty disables the admin API"`
+	LogFormat              string        `yaml:"log_format" env:"LOG_FORMAT" usage:"log format, json or text"`
+	LogLevel               string        `yaml:"log_level" env:"LOG_LEVEL" usage:"lowest logged level, debug, info, warn or error"`
+	AccessLogFormat        string        `yaml:"access_log_format" env:"ACCESS_LOG_FORMAT" usage:"access log format, json, combined or off"`
+	TraceExporter          string        `yaml:"trace_exporter" env:"TRACE_EXPORTER" usage:"where spans are sent, none, stdout or otlp"`
+	OTLPEndpoint           string        `yaml:"otlp_endpoint" env:"OTEL_EXPORTER_OTLP_ENDPOINT" usage:"collector of the otlp trace exporter"`
+	TraceServiceName       string        `yaml:"trace_service_name" env:"OTEL_SERVICE_NAME" usage:"service name reported with spans"`
+	InterstitialDomains    []string      `yaml:"interstitial_domains" env:"INTERSTITIAL_DOMAINS" usage:"comma separated domains shown behind a warning page"`
+	InternalDomains        []string      `yaml:"internal_domains" env:"INTERNAL_DOMAINS" usage:"comma separated domains of the organization"`
+	TrustedProxies         []string      `yaml:"trusted_proxies" env:"TRUSTED_PROXIES" usage:"comma separated addresses and networks of proxies whose forwarded headers are believed"`
+	ReservedCodes          []string      `yaml:"reserved_codes" env:"RESERVED_CODES" usage:"comma separated words that cannot be short codes"`
+	ReadTimeout            time.Duration `yaml:"http_read_timeout" env:"HTTP_READ_TIMEOUT" usage:"deadline for reading a request"`
+	WriteTimeout           time.Duration `yaml:"http_write_timeout" env:"HTTP_WRITE_TIMEOUT" usage:"deadline for writing a response"`
+	IdleTimeout            time.Duration `yaml:"http_idle_timeout" env:"HTTP_IDLE_TIMEOUT" usage:"how long idle keep-alive connections stay open"`
+	ShutdownTimeout        time.Duration `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT" usage:"how long shutdown waits for running requests"`
+	TLSReloadInterval      time.Duration `yaml:"tls_reload_interval" env:"TLS_RELOAD_INTERVAL" usage:"how often the certificate files are checked for changes, 0 disables the reload"`
+	HSTSMaxAge             time.Duration `yaml:"hsts_max_age" env:"HSTS_MAX_AGE" usage:"max-age of the Strict-Transport-Security header of HTTPS responses, 0 disables it"`
+	HealthCheckInterval    time.Duration `yaml:"health_check_interval" env:"HEALTH_CHECK_INTERVAL" usage:"how often link destinations are probed, 0 disables the checks"`
+	ShutdownDrainDelay     time.Duration `yaml:"shutdown_drain_delay" env:"SHUTDOWN_DRAIN_DELAY" usage:"how long the server keeps serving after it reports as not ready"`
+	DBReadTimeout          time.Duration `yaml:"db_read_timeout" env:"DB_READ_TIMEOUT" usage:"deadline of database reads, 0 disables it"`
+	DBWriteTimeout         time.Duration `yaml:"db_write_timeout" env:"DB_WRITE_TIMEOUT" usage:"deadline of database writes, 0 disables it"`
+	TraceSampleRatio       float64       `yaml:"trace_sample_ratio" env:"TRACE_SAMPLE_RATIO" usage:"share of new traces that are recorded"`
+	DBPort                 int           `yaml:"db_port" env:"DB_PORT" usage:"database port"`
+	DBMaxConns             int           `yaml:"db_max_conns" env:"DB_MAX_CONNS" usage:"most open database connections, 0 uses the driver default"`
+	DBMinConns             int           `yaml:"db_min_conns" env:"DB_MIN_CONNS" usage:"database connections kept open when idle"`
+	ShortCodeLength        int           `yaml:"short_code_length" env:"SHORT_CODE_LENGTH" usage:"length of generated short codes"`
+	RedirectCode           int           `yaml:"redirect_code" env:"REDIRECT_CODE" usage:"status code of redirects, 301, 302, 307 or 308"`
+	InterstitialCountdown  int           `yaml:"interstitial_countdown" env:"INTERSTITIAL_COUNTDOWN" usage:"seconds before the warning page continues"`
+	HealthCheckConcurrency int           `yaml:"health_check_concurrency" env:"HEALTH_CHECK_CONCURRENCY" usage:"hosts probed at the same time"`
+	HealthCheckFailures    int           `yaml:"health_check_failures" env:"HEALTH_CHECK_FAILURES" usage:"failed checks in a row after which links use their fallback"`
+	HSTSIncludeSubdomains  bool          `yaml:"hsts_include_subdomains" env:"HSTS_INCLUDE_SUBDOMAINS" usage:"extend the Strict-Transport-Security header to subdomains"`
+	InterstitialUnsafe     bool          `yaml:"interstitial_unsafe" env:"INTERSTITIAL_UNSAFE" usage:"show the warning page for destinations failing the safety checks"`
+	MetricsEnabled         bool          `yaml:"metrics_enabled" env:"METRICS_ENABLED" usage:"serve Prometheus metrics on /metrics"`
+	PreviewsEnabled        bool          `yaml:"previews_enabled" env:"PREVIEWS_ENABLED" usage:"serve preview pages and the link preview API"`
 }
 
-// LoadConfig loads the configuration from the environment variables.
-func LoadConfig() (*Config, error) {
-	port, err := strconv.Atoi(os.Getenv("DB_PORT"))
-	if err != nil {
-		return nil, urlshortenererror.Wrap(err, "invalid db port", http.StatusInternalServerError, urlshortenererror.ErrInvalidDBPort)
+// Default returns the configuration used for every setting that is not set.
+func Default() *Config {
+	return &Config{
+		ServerEnv:              "development",
+		ListenAddr:             DefaultListenAddr,
+		DBHost:                 DefaultDBHost,
+		ReadTimeout:            DefaultReadTimeout,
+		WriteTimeout:           DefaultWriteTimeout,
+		IdleTimeout:            DefaultIdleTimeout,
+		ShutdownTimeout:        DefaultShutdownTimeout,
+		TLSReloadInterval:      DefaultTLSReloadInterval,
+		HealthCheckInterval:    DefaultHealthCheckInterval,
+		ShutdownDrainDelay:     DefaultShutdownDrainDelay,
+		DBReadTimeout:          DefaultDBReadTimeout,
+		DBWriteTimeout:         DefaultDBWriteTimeout,
+		TraceSampleRatio:       DefaultTraceSampleRatio,
+		DBPort:                 DefaultDBPort,
+		ShortCodeLength:        DefaultShortCodeLength,
+		RedirectCode:           DefaultRedirectCode,
+		InterstitialCountdown:  DefaultInterstitialCountdown,
+		HealthCheckConcurrency: DefaultHealthCheckConcurrency,
+		HealthCheckFailures:    DefaultHealthCheckFailures,
+		MetricsEnabled:         true,
+		PreviewsEnabled:        true,
 	}
+}
 
-	return &Config{
-		ServerEnv:  os.Getenv("SERVER_ENV"),
-		DBName:     os.Getenv("DB_NAME"),
-		DBHost:     os.Getenv("DB_HOST"),
-		DBUser:     os.Getenv("DB_USER"),
-		DBPassword: os.Getenv("DB_PASSWORD"),
-		DBPort:     port,
-	}, nil
+// Problems lists everything wrong with a configuration, one entry per setting.
+type Problems []string
+
+// Error joins the problems on one line.
+func (p Problems) Error() string {
+	return strings.Join(p, "; ")
+}
+
+// invalid wraps the problems into an ErrInvalidConfig, or returns nil without any.
+func invalid(problems Problems) error {
+	if len(problems) == 0 {
+		return nil
+	}
+
+	return urlshortenererror.Wrap(problems, "invalid configuration", http.StatusInternalServerError, urlshortenererror.ErrInvalidConfig)
+}
+
+// Check lists the problems of settings whose values are validated by the
+// packages using them, so the config package does not depend on them.
+type Check func(*Config) Problems
+
+// Validate checks the configuration values that have a restricted range and
+// reports every setting that is out of it, together with the problems found
+// by the checks.
+func (c *Config) Validate(checks ...Check) error {
+	return invalid(c.problems(checks))
+}
+
+// problems lists the settings out of their range, named by their environment variable.
+func (c *Config) problems(checks []Check) Problems {
+	var problems Problems
+	check := func(ok bool, problem string) {
+		if !ok {
+			problems = append(problems, problem)
+		}
+	}
+
+	check(c.ListenAddr != "", "LISTEN_ADDR: must be set")
+	if c.BaseURL != "" {
+		base, err := url.Parse(c.BaseURL)
+		check(err == nil && (base.Scheme == "http" || base.Scheme == "https") && base.Host != "" &&
+			base.User == nil && base.RawQuery == "" && base.Fragment == "",
+			"BASE_URL: must be an http or https URL without query, e.g. https://sho.rt")
+	}
+	check(c.ReadTimeout >= 0, "HTTP_READ_TIMEOUT: must not be negative, 0 disables it")
+	check(c.WriteTimeout >= 0, "HTTP_WRITE_TIMEOUT: must not be negative, 0 disables it")
+	check(c.IdleTimeout >= 0, "HTTP_IDLE_TIMEOUT: must not be negative, 0 disables it")
+	check(c.ShutdownTimeout > 0, "SHUTDOWN_TIMEOUT: must be positive")
+
+	check(c.TLSKeyFile != "" || c.TLSCertFile == "", "TLS_KEY_FILE: must be set with TLS_CERT_FILE")
+	check(c.TLSCertFile != "" || c.TLSKeyFile == "", "TLS_CERT_FILE: must be set with TLS_KEY_FILE")
+	check(c.TLSClientCAFile == "" || c.TLSEnabled(), "TLS_CLIENT_CA_FILE: requires TLS_CERT_FILE and TLS_KEY_FILE")
+	check(c.HTTPRedirectAddr == "" || c.TLSEnabled(), "HTTP_REDIRECT_ADDR: requires TLS_CERT_FILE and TLS_KEY_FILE")
+	check(c.HTTPRedirectAddr == "" || c.HTTPRedirectAddr != c.ListenAddr, "HTTP_REDIRECT_ADDR: must differ from LISTEN_ADDR")
+	check(c.TLSReloadInterval >= 0, "TLS_RELOAD_INTERVAL: must not be negative, 0 disables the reload")
+	check(c.HSTSMaxAge >= 0, "HSTS_MAX_AGE: must not be negative, 0 disables it")
+
+	if c.DatabaseURL == "" {
+		check(c.DBName != "", "DB_NAME: must be set unless DATABASE_URL is")
+		check(c.DBUser != "", "DB_USER: must be set unless DATABASE_URL is")
+		check(c.DBHost != "", "DB_HOST: must be set unless DATABASE_URL is")
+		check(c.DBPort > 0 && c.DBPort <= maxPort, "DB_PORT: must be between 1 and 65535")
+	} else if scheme, _, found := strings.Cut(c.DatabaseURL, "://"); found {
+		check(scheme == "postgres" || scheme == "postgresql", "DATABASE_URL: scheme must be postgres or postgresql")
+	}
+
+	switch c.DBSSLMode {
+	case "", "disable", "allow", "prefer", "require", "verify-ca", "verify-full":
+	default:
+		problems = append(problems, "DB_SSLMODE: must be disable, allow, prefer, require, verify-ca or verify-full")
+	}
+
+	check(c.DBMaxConns >= 0, "DB_MAX_CONNS: must not be negative, 0 uses the driver default")
+	check(c.DBMinConns >= 0, "DB_MIN_CONNS: must not be negative")
+	check(c.DBMaxConns == 0 || c.DBMinConns <= c.DBMaxConns, "DB_MIN_CONNS: must not be above DB_MAX_CONNS")
+	check(c.DBReadTimeout >= 0, "DB_READ_TIMEOUT: must not be negative, 0 disables it")
+	check(c.DBWriteTimeout >= 0, "DB_WRITE_TIMEOUT: must not be negative, 0 disables it")
+
+	check(c.ShortCodeLength >= MinShortCodeLength && c.ShortCodeLength <= MaxShortCodeLength,
+		"SHORT_CODE_LENGTH: must be between 4 and 32")
+
+	check(c.InterstitialCountdown >= 0 && c.InterstitialCountdown <= MaxInterstitialCountdown,
+		"INTERSTITIAL_COUNTDOWN: must be between 0 and 60 seconds")
+	check(c.HealthCheckInterval == 0 || c.HealthCheckInterval >= time.Minute,
+		"HEALTH_CHECK_INTERVAL: must be 0 to disable checks or at least 1m")
+	check(c.HealthCheckConcurrency >= 1, "HEALTH_CHECK_CONCURRENCY: must be at least 1")
+	check(c.HealthCheckFailures >= 1, "HEALTH_CHECK_FAILURES: must be at least 1")
+	check(c.ShutdownDrainDelay >= 0, "SHUTDOWN_DRAIN_DELAY: must not be negative")
+
+	check(c.TraceSampleRatio >= 0 && c.TraceSampleRatio <= 1, "TRACE_SAMPLE_RATIO: must be between 0 and 1")
+
+	for _, check := range checks {
+		problems = append(problems, check(c)...)
+	}
+
+	return problems
+}
+
+// TLSEnabled reports whether the server serves HTTPS itself.
+func (c *Config) TLSEnabled() bool {
+	return c.TLSCertFile != "" && c.TLSKeyFile != ""
+}
+
+// DSN returns the connection string of the database: DATABASE_URL when it
+// is set, otherwise one built from the db_* settings. DB_SSLMODE applies to
+// both.
+func (c *Config) DSN() string {
+	if c.DatabaseURL != "" {
+		if c.DBSSLMode == "" {
+			return c.DatabaseURL
+		}
+
+		dsn, err := url.Parse(c.DatabaseURL)
+		if err != nil || dsn.Scheme == "" {
+			// A keyword/value connection string takes the last value of a repeated keyword.
+			return c.DatabaseURL + " sslmode=" + c.DBSSLMode
+		}
+		query := dsn.Query()
+		query.Set("sslmode", c.DBSSLMode)
+		dsn.RawQuery = query.Encode()
+
+		return dsn.String()
+	}
+
+	dsn := url.URL{
+		Scheme: "postgres",
+		User:   url.UserPassword(c.DBUser, c.DBPassword),
+		Host:   net.JoinHostPort(c.DBHost, strconv.Itoa(c.DBPort)),
+		Path:   "/" + c.DBName,
+	}
+	if c.DBSSLMode != "" {
+		dsn.RawQuery = url.Values{"sslmode": {c.DBSSLMode}}.Encode()
+	}
+
+	return dsn.String()
+}
+
+// splitList reads a comma separated list, leaving out empty entries.
+func splitList(value string) []string {
+	var list []string
+	for _, entry := range strings.Split(value, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			list = append(list, entry)
+		}
+	}
+
+	return list
 }
@@ -0,0 +1,387 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/urlshortenererror"
+	"gopkg.in/yaml.v3"
+)
+
+// FileEnv is the environment variable naming the configuration file, the
+// --config flag takes precedence over it.
+const FileEnv = "CONFIG_FILE"
+
+// fileFlag is the command line flag naming the configuration file.
+const fileFlag = "config"
+
+// Redacted replaces secrets when the configuration is printed.
+const Redacted = "[redacted]"
+
+// Values of the secret struct tag.
+const (
+	secretFull     = "true"
+	secretPassword = "password"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// setting is one field of the configuration with the names it is set by.
+type setting struct {
+	value  reflect.Value
+	key    string
+	env    string
+	usage  string
+	secret string
+}
+
+// flagName returns the command line flag setting the field.
+func (s setting) flagName() string {
+	return strings.ReplaceAll(s.key, "_", "-")
+}
+
+// settings lists the fields of the configuration, their values can be set.
+func (c *Config) settings() []setting {
+	value := reflect.ValueOf(c).Elem()
+	settings := make([]setting, 0, value.NumField())
+	for i := range value.NumField() {
+		field := value.Type().Field(i)
+		settings = append(settings, setting{
+			value:  value.Field(i),
+			key:    field.Tag.Get("yaml"),
+			env:    field.Tag.Get("env"),
+			usage:  field.Tag.Get("usage"),
+			secret: field.Tag.Get("secret"),
+		})
+	}
+
+	return settings
+}
+
+// set parses a value read from the environment or the command line.
+func (s setting) set(raw string) error {
+	switch {
+	case s.value.Type() == durationType:
+		duration, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("must be a duration such as 30s or 5m, got %q", raw)
+		}
+		s.value.SetInt(int64(duration))
+	case s.value.Kind() == reflect.String:
+		s.value.SetString(raw)
+	case s.value.Kind() == reflect.Slice:
+		s.value.Set(reflect.ValueOf(splitList(raw)))
+	case s.value.Kind() == reflect.Int:
+		number, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("must be an integer, got %q", raw)
+		}
+		s.value.SetInt(int64(number))
+	case s.value.Kind() == reflect.Float64:
+		number, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("must be a number, got %q", raw)
+		}
+		s.value.SetFloat(number)
+	case s.value.Kind() == reflect.Bool:
+		enabled, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("must be true or false, got %q", raw)
+		}
+		s.value.SetBool(enabled)
+	default:
+		return fmt.Errorf("unsupported setting type %s", s.value.Type())
+	}
+
+	return nil
+}
+
+// flagValue is a value given on the command line, applied after the file and the environment.
+type flagValue struct {
+	setting setting
+	raw     string
+}
+
+// newFlagSet creates the command line flags of the configuration. Values
+// are only collected while parsing, so a bad one does not stop the others
+// from being checked.
+func newFlagSet(settings []setting, values *[]flagValue, file *string) *flag.FlagSet {
+	flags := flag.NewFlagSet("url-shortener", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	flags.StringVar(file, fileFlag, "", "path of the YAML or TOML configuration file (env "+FileEnv+")")
+	for _, s := range settings {
+		collect := func(raw string) error {
+			*values = append(*values, flagValue{setting: s, raw: raw})
+
+			return nil
+		}
+		usage := fmt.Sprintf("%s (env %s)", s.usage, s.env)
+		if s.value.Kind() == reflect.Bool {
+			flags.BoolFunc(s.flagName(), usage, collect)
+		} else {
+			flags.Func(s.flagName(), usage, collect)
+		}
+	}
+
+	return flags
+}
+
+// Usage writes the command line flags of the configuration to w.
+func Usage(w io.Writer) {
+	var values []flagValue
+	var file string
+	flags := newFlagSet(Default().settings(), &values, &file)
+	flags.SetOutput(w)
+	flags.PrintDefaults()
+}
+
+// Load builds the configuration from its layers, each overriding the one
+// before: the defaults, the file named by --config or CONFIG_FILE, the
+// environment and the command line flags in args. Empty environment
+// variables count as unset.
+//
+// Every bad value, every setting out of its range and every problem found
+// by the checks is reported in one ErrInvalidConfig holding Problems. The
+// configuration is returned with it, so it can still be printed, unless the
+// command line could not be parsed.
+func Load(args []string, lookupEnv func(string) (string, bool), checks ...Check) (*Config, error) {
+	cfg := Default()
+	settings := cfg.settings()
+
+	var flagValues []flagValue
+	var file string
+	flags := newFlagSet(settings, &flagValues, &file)
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil, err
+		}
+
+		return nil, urlshortenererror.Wrap(err, "invalid command line", http.StatusInternalServerError, urlshortenererror.ErrInvalidConfig)
+	}
+
+	var problems Problems
+	if flags.NArg() > 0 {
+		problems = append(problems, fmt.Sprintf("unexpected arguments %q", flags.Args()))
+	}
+
+	if file == "" {
+		file, _ = lookupEnv(FileEnv)
+	}
+	if file != "" {
+		problems = append(problems, cfg.loadFile(file)...)
+	}
+
+	for _, s := range settings {
+		raw, _ := lookupEnv(s.env)
+		if raw == "" {
+			continue
+		}
+		if err := s.set(raw); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", s.env, err))
+		}
+	}
+
+	for _, value := range flagValues {
+		if err := value.setting.set(value.raw); err != nil {
+			problems = append(problems, fmt.Sprintf("--%s: %v", value.setting.flagName(), err))
+		}
+	}
+
+	return cfg, invalid(append(problems, cfg.problems(checks)...))
+}
+
+// loadFile reads the configuration file over the configuration, as TOML
+// when its name ends in .toml and as YAML otherwise. Keys that are not
+// settings are reported, so typos do not go unnoticed.
+func (c *Config) loadFile(path string) Problems {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Problems{fmt.Sprintf("%s: %v", path, err)}
+	}
+
+	if strings.EqualFold(filepath.Ext(path), ".toml") {
+		return c.decodeTOML(path, data)
+	}
+
+	return c.decodeYAML(path, data)
+}
+
+// decodeYAML reads a YAML configuration file over the configuration.
+func (c *Config) decodeYAML(path string, data []byte) Problems {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	err := decoder.Decode(c)
+
+	var typeErr *yaml.TypeError
+	switch {
+	case err == nil, errors.Is(err, io.EOF):
+		return nil
+	case errors.As(err, &typeErr):
+		problems := make(Problems, 0, len(typeErr.Errors))
+		for _, message := range typeErr.Errors {
+			problems = append(problems, path+": "+message)
+		}
+
+		return problems
+	default:
+		return Problems{fmt.Sprintf("%s: %v", path, err)}
+	}
+}
+
+// decodeTOML reads a TOML configuration file over the configuration. Its
+// values are read like those of the environment, so durations are strings
+// such as "30s".
+func (c *Config) decodeTOML(path string, data []byte) Problems {
+	var values map[string]any
+	if _, err := toml.Decode(string(data), &values); err != nil {
+		return Problems{fmt.Sprintf("%s: %v", path, err)}
+	}
+
+	settings := make(map[string]setting, len(values))
+	for _, s := range c.settings() {
+		settings[s.key] = s
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var problems Problems
+	for _, key := range keys {
+		s, ok := settings[key]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s: unknown setting %s", path, key))
+
+			continue
+		}
+
+		raw, err := tomlValue(values[key], s)
+		if err == nil {
+			err = s.set(raw)
+		}
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %s: %v", path, key, err))
+		}
+	}
+
+	return problems
+}
+
+// tomlValue returns a TOML value in the form it has in the environment.
+func tomlValue(value any, s setting) (string, error) {
+	switch value := value.(type) {
+	case string:
+		return value, nil
+	case int64:
+		return strconv.FormatInt(value, 10), nil
+	case float64:
+		return strconv.FormatFloat(value, 'g', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(value), nil
+	case []any:
+		if s.value.Kind() != reflect.Slice {
+			return "", errors.New("must not be a list")
+		}
+
+		entries := make([]string, 0, len(value))
+		for _, entry := range value {
+			text, ok := entry.(string)
+			if !ok || strings.Contains(text, ",") {
+				return "", fmt.Errorf("must be a list of strings without commas, got %v", entry)
+			}
+			entries = append(entries, text)
+		}
+
+		return strings.Join(entries, ","), nil
+	case map[string]any:
+		return "", errors.New("must not be a table")
+	default:
+		return "", fmt.Errorf("unsupported value %v", value)
+	}
+}
+
+// Redact returns a copy of the configuration with its secrets replaced.
+// Connection strings keep everything but their password.
+func (c *Config) Redact() *Config {
+	redacted := *c
+	for _, s := range redacted.settings() {
+		if s.secret == "" || s.value.String() == "" {
+			continue
+		}
+
+		switch s.secret {
+		case secretFull:
+			s.value.SetString(Redacted)
+		case secretPassword:
+			s.value.SetString(redactPassword(s.value.String()))
+		}
+	}
+
+	return &redacted
+}
+
+// Print writes the configuration as YAML with its secrets redacted, in the
+// format of the configuration file.
+func (c *Config) Print(w io.Writer) error {
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(c.Redact()); err != nil {
+		return fmt.Errorf("failed to encode configuration: %w", err)
+	}
+
+	if err := encoder.Close(); err != nil {
+		return fmt.Errorf("failed to encode configuration: %w", err)
+	}
+
+	return nil
+}
+
+// keywordPassword matches the password of a keyword/value connection string.
+var keywordPassword = regexp.MustCompile(`(password\s*=\s*)('(?:[^'\\]|\\.)*'|\S+)`)
+
+// redactPassword replaces the password of a connection string, in its user
+// info or its parameters. A value that cannot be read as either form is
+// replaced as a whole, it may well hold a password.
+func redactPassword(dsn string) string {
+	parsed, err := url.Parse(dsn)
+	switch {
+	case err != nil, parsed.Opaque != "":
+		return Redacted
+	case parsed.Scheme == "" && keywordPassword.MatchString(dsn):
+		return keywordPassword.ReplaceAllString(dsn, "${1}"+Redacted)
+	case parsed.Scheme == "":
+		return Redacted
+	}
+
+	query := parsed.Query()
+	if query.Has("password") {
+		query.Set("password", Redacted)
+		parsed.RawQuery = query.Encode()
+	}
+
+	user := parsed.User
+	if _, hasPassword := user.Password(); !hasPassword {
+		return parsed.String()
+	}
+	parsed.User = nil
+
+	// The user info is written by hand, url.UserPassword would escape the brackets.
+	return parsed.Scheme + "://" + url.User(user.Username()).String() + ":" + Redacted + "@" +
+		strings.TrimPrefix(parsed.String(), parsed.Scheme+"://")
+}
@@ -2,48 +2,304 @@ package db
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"math"
 	"net/http"
+	"reflect"
 	"time"
 
 	"github.com/jackc/pgconn"
 	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/logging"
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/tracing"
 	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/urlshortenererror"
 )
 
 // Database interface to hold the database methods.
 type Database interface {
-	StoreURLs(shortURL, originalURL string) (string, error)
-	GetOriginalURL(shortURL string) (string, error)
+	StoreURLs(ctx context.Context, shortURL, originalURL string) (string, error)
+	StoreURLWithOptions(ctx context.Context, shortURL, originalURL string, options LinkOptions) (string, error)
+	GetOriginalURL(ctx context.Context, shortURL string) (string, error)
+	VisitURL(ctx context.Context, shortURL string, guard VisitGuard) (*URLMap, bool, error)
+	CountVariantHit(ctx context.Context, shortURL, variant string) error
+	GetURLMap(ctx context.Context, shortURL string) (*URLMap, error)
+	GetURLMaps(ctx context.Context, shortURLs []string) ([]URLMap, error)
+	GetVariantHits(ctx context.Context, shortURLs []string) (map[string]map[string]int64, error)
+	GetLinksToCheck(ctx context.Context, checkedBefore time.Time, limit int) ([]URLMap, error)
+	RecordLinkCheck(ctx context.Context, check LinkCheck) error
+	GetLinkChecks(ctx context.Context, shortURL string) ([]LinkCheck, error)
+	GetDomains(ctx context.Context, accountID string) ([]Domain, error)
+	StoreDomain(ctx context.Context, domain Domain) (*Domain, error)
+	DeleteDomain(ctx context.Context, host, accountID string) error
 	Close()
 }
 
 // URLMap struct to hold the URL map.
 type URLMap struct {
-	CreatedAt   time.Time `db:"created_at"`
-	ShortURL    string    `db:"short_url"`
-	OriginalURL string    `db:"original_url"`
-	Hits        int64     `db:"hits"`
+	CreatedAt time.Time `db:"created_at"`
+	// ConsumedAt is set once a one-time link has been used.
+	ConsumedAt *time.Time `db:"consumed_at"`
+	// CheckedAt is the time the destination was last probed.
+	CheckedAt   *time.Time  `db:"checked_at"`
+	ShortURL    string      `db:"short_url"`
+	OriginalURL string      `db:"original_url"`
+	Options     LinkOptions `db:"options"`
+	Hits        int64       `db:"hits"`
+	// ConsecutiveFailures counts the failed probes since the last successful one.
+	ConsecutiveFailures int `db:"consecutive_failures"`
+}
+
+// LinkOptions holds the per-link settings stored in the options column.
+// Zero values fall back to the deployment defaults. The password hash is kept
+// in its own column so it never leaves the database with the options.
+type LinkOptions struct {
+	Passthrough  *Passthrough      `json:"passthrough,omitempty"`
+	Schedule     *Schedule         `json:"schedule,omitempty"`
+	Languages    map[string]string `json:"languages,omitempty"`
+	CacheControl string            `json:"cache_control,omitempty"`
+	Fallback     string            `json:"fallback,omitempty"`
+	Targeting    []TargetingRule   `json:"targeting,omitempty"`
+	Variants     []Variant         `json:"variants,omitempty"`
+	PasswordHash string            `json:"-"`
+	Interstitial string            `json:"interstitial,omitempty"`
+	RedirectCode int               `json:"redirect_code,omitempty"`
+	OneTime      bool              `json:"one_time,omitempty"`
+}
+
+// Per-link interstitial settings. Links without one follow the deployment policy.
+const (
+	InterstitialAlways = "always"
+	InterstitialNever  = "never"
+)
+
+// Schedule sets when a link goes live and which destination it uses over time.
+// Times are stored as RFC 3339 with the offset of the time zone.
+type Schedule struct {
+	TimeZone   string           `json:"time_zone,omitempty"`
+	ActiveFrom string           `json:"active_from,omitempty"`
+	Windows    []ScheduleWindow `json:"windows,omitempty"`
+}
+
+// ScheduleWindow sends visitors to its destination between its start and end.
+type ScheduleWindow struct {
+	Start       string `json:"start,omitempty"`
+	End         string `json:"end,omitempty"`
+	Destination string `json:"destination"`
+}
+
+// Variant is one of the destinations a link splits its traffic across.
+type Variant struct {
+	Name        string `json:"name"`
+	Destination string `json:"destination"`
+	Weight      int    `json:"weight"`
+}
+
+// TargetingRule sends clients matching every set criterion to its own destination.
+type TargetingRule struct {
+	OS          string `json:"os,omitempty"`
+	Device      string `json:"device,omitempty"`
+	Browser     string `json:"browser,omitempty"`
+	Destination string `json:"destination"`
+}
+
+// Conflict rules for query parameters present in both the destination and the request.
+const (
+	QueryConflictKeep     = "keep"
+	QueryConflictOverride = "override"
+	QueryConflictAppend   = "append"
+)
+
+// Passthrough controls which parts of the request are forwarded to the destination.
+type Passthrough struct {
+	QueryConflict string `json:"query_conflict,omitempty"`
+	Path          bool   `json:"path,omitempty"`
+	Query         bool   `json:"query,omitempty"`
+}
+
+// IsZero reports whether no per-link setting is set.
+func (o LinkOptions) IsZero() bool {
+	return reflect.ValueOf(o).IsZero()
 }
 
+// urlMapColumns lists the columns read into a URLMap by scanURLMap.
+const urlMapColumns = `short_url, original_url, hits, created_at, options, password_hash, consumed_at,
+    checked_at, consecutive_failures`
+
+// scanURLMap reads a row selected with urlMapColumns.
+func scanURLMap(row pgx.Row, urlMap *URLMap) error {
+	var options []byte
+	var passwordHash string
+	if err := row.Scan(
+		&urlMap.ShortURL, &urlMap.OriginalURL, &urlMap.Hits, &urlMap.CreatedAt, &options, &passwordHash,
+		&urlMap.ConsumedAt, &urlMap.CheckedAt, &urlMap.ConsecutiveFailures,
+	); err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(options, &urlMap.Options); err != nil {
+		return fmt.Errorf("invalid options for %s: %w", urlMap.ShortURL, err)
+	}
+	urlMap.Options.PasswordHash = passwordHash
+
+	return nil
+}
+
+// PoolStats holds the statistics of the database connection pool.
+type PoolStats struct {
+	AcquireDuration      time.Duration
+	AcquireCount         int64
+	EmptyAcquireCount    int64
+	CanceledAcquireCount int64
+	AcquiredConns        int32
+	IdleConns            int32
+	TotalConns           int32
+	MaxConns             int32
+}
+
+// Default deadlines of database operations.
+const (
+	DefaultReadTimeout  = 3 * time.Second
+	DefaultWriteTimeout = 5 * time.Second
+)
+
 // DB struct to hold the database connection pool.
 type DB struct {
-	pool *pgxpool.Pool
+	pool         *pgxpool.Pool
+	logger       *slog.Logger
+	tracer       trace.Tracer
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+	maxConns     int
+	minConns     int
+}
+
+// Option configures a DB.
+type Option func(*DB)
+
+// WithLogger sets the logger of the database layer.
+func WithLogger(logger *slog.Logger) Option {
+	return func(db *DB) {
+		db.logger = logger
+	}
+}
+
+// WithTracer sets the tracer recording a span per database operation.
+func WithTracer(tracer trace.Tracer) Option {
+	return func(db *DB) {
+		db.tracer = tracer
+	}
+}
+
+// WithReadTimeout sets the deadline of operations that only read, 0 disables it.
+func WithReadTimeout(timeout time.Duration) Option {
+	return func(db *DB) {
+		db.readTimeout = timeout
+	}
+}
+
+// WithWriteTimeout sets the deadline of operations that write, 0 disables it.
+func WithWriteTimeout(timeout time.Duration) Option {
+	return func(db *DB) {
+		db.writeTimeout = timeout
+	}
+}
+
+// WithMaxConns sets the most connections the pool opens, 0 keeps the
+// driver default or the pool_max_conns of the connection string.
+func WithMaxConns(conns int) Option {
+	return func(db *DB) {
+		db.maxConns = conns
+	}
+}
+
+// WithMinConns sets the connections the pool keeps open when idle.
+func WithMinConns(conns int) Option {
+	return func(db *DB) {
+		db.minConns = conns
+	}
+}
+
+// operation starts the span of a database operation and bounds it by the
+// timeout. The returned function must be deferred with the error result of
+// the operation, it reports timeouts and cancellations as ErrTimeout.
+func (db *DB) operation(ctx context.Context, name string, timeout time.Duration) (context.Context, func(*error)) {
+	ctx, span := db.tracer.Start(ctx, "db."+name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "postgresql"),
+			attribute.String("db.operation.name", name),
+		),
+	)
+
+	cancel := context.CancelFunc(func() {})
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	}
+
+	return ctx, func(err *error) {
+		*err = contextError(*err)
+		tracing.End(span, err)
+		cancel()
+	}
+}
+
+// contextError turns errors caused by an expired or cancelled context into
+// an ErrTimeout. Deadlines answer 504, cancellations come from a client that
+// left or a server shutting down and answer 503.
+func contextError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, context.DeadlineExceeded):
+		return urlshortenererror.New(urlshortenererror.ErrTimeout, err, "The database did not answer in time", http.StatusGatewayTimeout)
+	case errors.Is(err, context.Canceled):
+		return urlshortenererror.New(urlshortenererror.ErrTimeout, err, "The request was cancelled", http.StatusServiceUnavailable)
+	default:
+		return err
+	}
 }
 
 // New creates a new DB instance.
-func New(user, password, host, dbname string, port int) (*DB, error) {
-	config, err := pgxpool.ParseConfig(fmt.Sprintf(
+func New(user, password, host, dbname string, port int, opts ...Option) (*DB, error) {
+	return Open(fmt.Sprintf(
 		"postgres://%s:%s@%s:%d/%s",
 		user, password, host, port, dbname,
-	))
+	), opts...)
+}
+
+// Open creates a new DB instance connected with a PostgreSQL connection
+// string, in URL or keyword/value form.
+func Open(dsn string, opts ...Option) (*DB, error) {
+	db := &DB{
+		logger:       slog.Default(),
+		tracer:       tracing.NoopTracer(),
+		readTimeout:  DefaultReadTimeout,
+		writeTimeout: DefaultWriteTimeout,
+	}
+	for _, opt := range opts {
+		opt(db)
+	}
+
+	config, err := pgxpool.ParseConfig(dsn)
 	if err != nil {
 		return nil, urlshortenererror.Wrap(err, "invalid connection config", http.StatusInternalServerError, urlshortenererror.ErrDBConnection)
 	}
+	if db.maxConns > 0 {
+		config.MaxConns = int32(min(db.maxConns, math.MaxInt32))
+	}
+	if db.minConns > 0 {
+		config.MinConns = int32(min(db.minConns, math.MaxInt32))
+	}
 
 	pool, err := pgxpool.ConnectConfig(context.Background(), config)
 	if err != nil {
@@ -51,38 +307,44 @@ func New(user, password, host, dbname string, port int) (*DB, error) {
 	}
 
 	if pingErr := pool.Ping(context.Background()); pingErr != nil {
+		pool.Close()
+
 		return nil, urlshortenererror.Wrap(pingErr, "failed to ping the db", http.StatusInternalServerError, urlshortenererror.ErrDBConnection)
 	}
+	db.pool = pool
 
-	return &DB{pool: pool}, nil
+	return db, nil
 }
 
 // StoreURLs stores the short URL and original URL in the database.
-func (db *DB) StoreURLs(shortURL, originalURL string) (string, error) {
-	log.Printf("Attempting to store URL: short=%s, original=%s", shortURL, originalURL)
+func (db *DB) StoreURLs(ctx context.Context, shortURL, originalURL string) (_ string, err error) {
+	ctx, finish := db.operation(ctx, "StoreURLs", db.writeTimeout)
+	defer finish(&err)
 
-	tx, err := db.pool.Begin(context.Background())
+	tx, err := db.pool.Begin(ctx)
 	if err != nil {
 		return "", urlshortenererror.Wrap(err, "failed to begin transaction", http.StatusInternalServerError, urlshortenererror.ErrDBQuery)
 	}
 	defer func() {
 		if deferErr := tx.Rollback(context.Background()); deferErr != nil && !errors.Is(deferErr, pgx.ErrTxClosed) {
-			log.Printf("Failed to rollback transaction: %v", deferErr)
+			db.logger.Error("Failed to rollback transaction", logging.Error(deferErr))
 		}
 	}()
 
 	var resultShortURL string
 
-	// Try to update existing row and return in one query.
-	err = tx.QueryRow(context.Background(),
+	// Try to update existing row and return in one query. Only plain links of
+	// the same domain are reused, a password is kept outside of the options.
+	domain, _ := SplitLinkKey(shortURL)
+	err = tx.QueryRow(ctx,
 		`UPDATE urlmap 
          SET hits = hits + 1
-         WHERE original_url = $1
+         WHERE original_url = $1 AND domain = $2 AND options = '{}'::jsonb AND password_hash = ''
          RETURNING short_url`, // Removed the extra comma after hits + 1
-		originalURL).Scan(&resultShortURL)
+		originalURL, domain).Scan(&resultShortURL)
 
 	if err == nil {
-		return commitAndReturn(tx, resultShortURL)
+		return db.commitAndReturn(ctx, tx, resultShortURL)
 	}
 
 	if !errors.Is(err, pgx.ErrNoRows) {
@@ -90,20 +352,20 @@ func (db *DB) StoreURLs(shortURL, originalURL string) (string, error) {
 	}
 
 	// Try to insert new row
-	err = tx.QueryRow(context.Background(),
-		`INSERT INTO urlmap (short_url, original_url, hits) 
-         VALUES ($1, $2, 1) 
+	err = tx.QueryRow(ctx,
+		`INSERT INTO urlmap (short_url, original_url, hits, domain) 
+         VALUES ($1, $2, 1, $3) 
          RETURNING short_url`,
-		shortURL, originalURL).Scan(&resultShortURL)
+		shortURL, originalURL, domain).Scan(&resultShortURL)
 
 	if err == nil {
-		return commitAndReturn(tx, resultShortURL)
+		return db.commitAndReturn(ctx, tx, resultShortURL)
 	}
 
 	// Handle insert errors
 	var pgErr *pgconn.PgError
 	if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation {
-		log.Println("URL HASH COLLISION", err, pgErr.Code, pgErr.Message)
+		db.logger.Warn("Short URL hash collision", "short_url", shortURL, "code", pgErr.Code)
 
 		return "", urlshortenererror.Wrap(err, "URL hash collision", http.StatusConflict, urlshortenererror.ErrDuplicate)
 	}
@@ -111,49 +373,259 @@ func (db *DB) StoreURLs(shortURL, originalURL string) (string, error) {
 	return "", urlshortenererror.Wrap(err, "failed to insert URL", http.StatusInternalServerError, urlshortenererror.ErrDBQuery)
 }
 
+// StoreURLWithOptions stores a new short URL with per-link settings. Unlike
+// StoreURLs it never reuses an existing short URL for the same original URL.
+func (db *DB) StoreURLWithOptions(ctx context.Context, shortURL, originalURL string, options LinkOptions) (_ string, err error) {
+	ctx, finish := db.operation(ctx, "StoreURLWithOptions", db.writeTimeout)
+	defer finish(&err)
+
+	encoded, err := json.Marshal(options)
+	if err != nil {
+		return "", urlshortenererror.Wrap(err, "invalid link options", http.StatusBadRequest, urlshortenererror.ErrInvalidInput)
+	}
+
+	var resultShortURL string
+	domain, _ := SplitLinkKey(shortURL)
+	err = db.pool.QueryRow(ctx,
+		`INSERT INTO urlmap (short_url, original_url, hits, options, password_hash, domain)
+         VALUES ($1, $2, 0, $3, $4, $5)
+         RETURNING short_url`,
+		shortURL, originalURL, encoded, options.PasswordHash, domain).Scan(&resultShortURL)
+
+	if err == nil {
+		return resultShortURL, nil
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation {
+		return "", urlshortenererror.Wrap(err, "URL hash collision", http.StatusConflict, urlshortenererror.ErrDuplicate)
+	}
+
+	return "", urlshortenererror.Wrap(err, "failed to insert URL", http.StatusInternalServerError, urlshortenererror.ErrDBQuery)
+}
+
 // Helper function to avoid repetition.
-func commitAndReturn(tx pgx.Tx, shortURL string) (string, error) {
-	if err := tx.Commit(context.Background()); err != nil {
+func (db *DB) commitAndReturn(ctx context.Context, tx pgx.Tx, shortURL string) (string, error) {
+	if err := tx.Commit(ctx); err != nil {
 		return "", urlshortenererror.Wrap(err, "failed to commit transaction", http.StatusInternalServerError, urlshortenererror.ErrDBQuery)
 	}
-	log.Printf("Successfully stored URL: %s", shortURL)
+	db.logger.Debug("Stored short URL", "short_url", shortURL)
 
 	return shortURL, nil
 }
 
 // GetOriginalURL gets the original URL from the short URL.
-func (db *DB) GetOriginalURL(shortURL string) (string, error) {
-	tx, err := db.pool.Begin(context.Background())
-	if err != nil {
-		return "", urlshortenererror.Wrap(err, "failed to begin transaction", http.StatusInternalServerError, urlshortenererror.ErrDBQuery)
+func (db *DB) GetOriginalURL(ctx context.Context, shortURL string) (_ string, err error) {
+	ctx, finish := db.operation(ctx, "GetOriginalURL", db.writeTimeout)
+	defer finish(&err)
+
+	urlMap, visited, err := db.VisitURL(ctx, shortURL, VisitGuard{Now: time.Now()})
+	switch {
+	case err != nil:
+		return "", err
+	case !visited && urlMap.ConsumedAt != nil:
+		return "", urlshortenererror.Wrap(nil, "This link has already been used", http.StatusGone, urlshortenererror.ErrGone)
+	case !visited:
+		return "", urlshortenererror.Wrap(nil, "URL not found", http.StatusNotFound, urlshortenererror.ErrNotFound)
 	}
-	defer func() {
-		if deferErr := tx.Rollback(context.Background()); deferErr != nil && !errors.Is(deferErr, pgx.ErrTxClosed) {
-			log.Printf("Failed to rollback transaction: %v", deferErr)
-		}
-	}()
 
-	var originalURL string
-	err = tx.QueryRow(context.Background(),
+	return urlMap.OriginalURL, nil
+}
+
+// VisitGuard holds what a visit has to satisfy to be counted, besides the
+// link not being a used one-time link.
+type VisitGuard struct {
+	// Now must not be before the activation time of the link's schedule.
+	Now time.Time
+	// PasswordFingerprint must match the PasswordFingerprint of a protected
+	// link, it comes from the unlock token of the visitor.
+	PasswordFingerprint string
+	// ExtraPath is set when the visit carries a path after the short URL,
+	// which the link must pass through.
+	ExtraPath bool
+}
+
+// PasswordFingerprint identifies a password hash without revealing it. It
+// matches the fingerprint computed by the visit query.
+func PasswordFingerprint(hash string) string {
+	sum := sha256.Sum256([]byte(hash))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// VisitURL counts a hit for the short URL and returns its URL map. The guard
+// is checked by the same update that counts the hit and consumes one-time
+// links, so a visit cannot slip in between a check and the update. When the
+// link exists but the guard does not let the visit through, nothing is
+// counted and the URL map is returned with visited false, for the caller to
+// tell the visitor why.
+func (db *DB) VisitURL(ctx context.Context, shortURL string, guard VisitGuard) (_ *URLMap, visited bool, err error) {
+	ctx, finish := db.operation(ctx, "VisitURL", db.writeTimeout)
+	defer finish(&err)
+
+	// The row lock makes concurrent visits of a one-time link wait, and they
+	// then no longer match.
+	var urlMap URLMap
+	err = scanURLMap(db.pool.QueryRow(ctx,
 		`UPDATE urlmap 
-         SET hits = hits + 1 
-         WHERE short_url = $1 
-         RETURNING original_url`,
-		shortURL).Scan(&originalURL)
+         SET hits = hits + 1,
+             consumed_at = CASE WHEN (options->>'one_time')::boolean THEN NOW() END
+         WHERE short_url = $1 AND consumed_at IS NULL
+           AND COALESCE((options->'schedule'->>'active_from')::timestamptz <= $2, TRUE)
+           AND (password_hash = '' OR encode(sha256(convert_to(password_hash, 'UTF8')), 'hex') = $3)
+           AND (NOT $4 OR COALESCE((options->'passthrough'->>'path')::boolean, FALSE))
+         RETURNING `+urlMapColumns,
+		shortURL, guard.Now, guard.PasswordFingerprint, guard.ExtraPath), &urlMap)
+
+	if err == nil {
+		return &urlMap, true, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, false, urlshortenererror.Wrap(err, "failed to get original URL", http.StatusInternalServerError, urlshortenererror.ErrDBQuery)
+	}
+
+	// Only refused and unknown links take a second query.
+	refused, err := db.GetURLMap(ctx, shortURL)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return refused, false, nil
+}
+
+// CountVariantHit counts a hit for the split test variant of the short URL.
+func (db *DB) CountVariantHit(ctx context.Context, shortURL, variant string) (err error) {
+	ctx, finish := db.operation(ctx, "CountVariantHit", db.writeTimeout)
+	defer finish(&err)
+
+	if _, err = db.pool.Exec(ctx,
+		`INSERT INTO variant_hits (short_url, variant, hits)
+         VALUES ($1, $2, 1)
+         ON CONFLICT (short_url, variant) DO UPDATE SET hits = variant_hits.hits + 1`,
+		shortURL, variant); err != nil {
+		return urlshortenererror.Wrap(err, "failed to count variant hit", http.StatusInternalServerError, urlshortenererror.ErrDBQuery)
+	}
+
+	return nil
+}
+
+// Ping checks that a connection to the database can be acquired and used.
+func (db *DB) Ping(ctx context.Context) (err error) {
+	ctx, finish := db.operation(ctx, "Ping", db.readTimeout)
+	defer finish(&err)
+
+	if err = db.pool.Ping(ctx); err != nil {
+		return urlshortenererror.Wrap(err, "failed to ping the db", http.StatusServiceUnavailable, urlshortenererror.ErrDBConnection)
+	}
+
+	return nil
+}
+
+// PoolStats returns the current statistics of the connection pool.
+func (db *DB) PoolStats() PoolStats {
+	stat := db.pool.Stat()
+
+	return PoolStats{
+		AcquireDuration:      stat.AcquireDuration(),
+		AcquireCount:         stat.AcquireCount(),
+		EmptyAcquireCount:    stat.EmptyAcquireCount(),
+		CanceledAcquireCount: stat.CanceledAcquireCount(),
+		AcquiredConns:        stat.AcquiredConns(),
+		IdleConns:            stat.IdleConns(),
+		TotalConns:           stat.TotalConns(),
+		MaxConns:             stat.MaxConns(),
+	}
+}
+
+// GetURLMap gets the URL map of the short URL without counting a hit.
+func (db *DB) GetURLMap(ctx context.Context, shortURL string) (_ *URLMap, err error) {
+	ctx, finish := db.operation(ctx, "GetURLMap", db.readTimeout)
+	defer finish(&err)
+
+	var urlMap URLMap
+	err = scanURLMap(db.pool.QueryRow(ctx,
+		`SELECT `+urlMapColumns+`
+         FROM urlmap
+         WHERE short_url = $1`,
+		shortURL), &urlMap)
 
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			return "", urlshortenererror.Wrap(err, "URL not found", http.StatusNotFound, urlshortenererror.ErrNotFound)
+			return nil, urlshortenererror.Wrap(err, "URL not found", http.StatusNotFound, urlshortenererror.ErrNotFound)
 		}
 
-		return "", urlshortenererror.Wrap(err, "failed to get original URL", http.StatusInternalServerError, urlshortenererror.ErrDBQuery)
+		return nil, urlshortenererror.Wrap(err, "failed to get URL", http.StatusInternalServerError, urlshortenererror.ErrDBQuery)
 	}
 
-	if err = tx.Commit(context.Background()); err != nil {
-		return "", urlshortenererror.Wrap(err, "failed to commit transaction", http.StatusInternalServerError, urlshortenererror.ErrDBQuery)
+	return &urlMap, nil
+}
+
+// GetURLMaps gets the URL maps of the short URLs without counting hits.
+// Short URLs that do not exist are left out of the result.
+func (db *DB) GetURLMaps(ctx context.Context, shortURLs []string) (_ []URLMap, err error) {
+	ctx, finish := db.operation(ctx, "GetURLMaps", db.readTimeout)
+	defer finish(&err)
+
+	rows, err := db.pool.Query(ctx,
+		`SELECT `+urlMapColumns+`
+         FROM urlmap
+         WHERE short_url = ANY($1)`,
+		shortURLs)
+	if err != nil {
+		return nil, urlshortenererror.Wrap(err, "failed to get URLs", http.StatusInternalServerError, urlshortenererror.ErrDBQuery)
+	}
+	defer rows.Close()
+
+	urlMaps := make([]URLMap, 0, len(shortURLs))
+	for rows.Next() {
+		var urlMap URLMap
+		if err = scanURLMap(rows, &urlMap); err != nil {
+			return nil, urlshortenererror.Wrap(err, "failed to read URL", http.StatusInternalServerError, urlshortenererror.ErrDBQuery)
+		}
+		urlMaps = append(urlMaps, urlMap)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, urlshortenererror.Wrap(err, "failed to get URLs", http.StatusInternalServerError, urlshortenererror.ErrDBQuery)
+	}
+
+	return urlMaps, nil
+}
+
+// GetVariantHits gets the hits of every variant of the short URLs, keyed by
+// short URL and then by variant name.
+func (db *DB) GetVariantHits(ctx context.Context, shortURLs []string) (_ map[string]map[string]int64, err error) {
+	ctx, finish := db.operation(ctx, "GetVariantHits", db.readTimeout)
+	defer finish(&err)
+
+	rows, err := db.pool.Query(ctx,
+		`SELECT short_url, variant, hits
+         FROM variant_hits
+         WHERE short_url = ANY($1)`,
+		shortURLs)
+	if err != nil {
+		return nil, urlshortenererror.Wrap(err, "failed to get variant hits", http.StatusInternalServerError, urlshortenererror.ErrDBQuery)
+	}
+	defer rows.Close()
+
+	variantHits := make(map[string]map[string]int64)
+	for rows.Next() {
+		var shortURL, variant string
+		var hits int64
+		if err = rows.Scan(&shortURL, &variant, &hits); err != nil {
+			return nil, urlshortenererror.Wrap(err, "failed to read variant hits", http.StatusInternalServerError, urlshortenererror.ErrDBQuery)
+		}
+		if variantHits[shortURL] == nil {
+			variantHits[shortURL] = make(map[string]int64)
+		}
+		variantHits[shortURL][variant] = hits
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, urlshortenererror.Wrap(err, "failed to get variant hits", http.StatusInternalServerError, urlshortenererror.ErrDBQuery)
 	}
 
-	return originalURL, nil
+	return variantHits, nil
 }
 
 // GetAllUrls ...
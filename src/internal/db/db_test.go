@@ -1,8 +1,12 @@
 package db_test
 
 import (
+	"context"
 	"errors"
+	"net/http"
+	"reflect"
 	"testing"
+	"time"
 
 	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/db"
 	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/urlshortenererror"
@@ -34,6 +38,9 @@ func setupTestDB(t *testing.T) *db.DB {
 	if err != nil {
 		t.Fatalf("Failed to connect to test database: %v", err)
 	}
+	if err = database.Migrate(context.Background()); err != nil {
+		t.Fatalf("Failed to migrate test database: %v", err)
+	}
 	return database
 }
 
@@ -123,7 +130,7 @@ func TestStoreURLs(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result, err := database.StoreURLs(tt.shortURL, tt.originalURL)
+			result, err := database.StoreURLs(context.Background(), tt.shortURL, tt.originalURL)
 
 			if tt.expectedError {
 				if err == nil {
@@ -180,7 +187,7 @@ func TestGetOriginalURL(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result, err := database.GetOriginalURL(tt.shortURL)
+			result, err := database.GetOriginalURL(context.Background(), tt.shortURL)
 
 			if tt.expectedError {
 				if err == nil {
@@ -216,7 +223,7 @@ func TestConcurrentAccess(t *testing.T) {
 	originalURL := "https://example123.com"
 
 	// Store initial URL
-	_, err := database.StoreURLs(shortURL, originalURL)
+	_, err := database.StoreURLs(context.Background(), shortURL, originalURL)
 	if err != nil {
 		t.Fatalf("Failed to store initial URL: %v", err)
 	}
@@ -227,7 +234,7 @@ func TestConcurrentAccess(t *testing.T) {
 
 	for i := 0; i < concurrentRequests; i++ {
 		go func() {
-			_, err := database.GetOriginalURL(shortURL)
+			_, err := database.GetOriginalURL(context.Background(), shortURL)
 			if err != nil {
 				t.Errorf("Concurrent get failed: %v", err)
 			}
@@ -240,3 +247,421 @@ func TestConcurrentAccess(t *testing.T) {
 		<-done
 	}
 }
+
+func TestGetURLMaps(t *testing.T) {
+	database := setupTestDB(t)
+	defer cleanupTestDB(database)
+
+	shortURL := "expand1"
+	originalURL := "https://expand.example.com"
+
+	if _, err := database.StoreURLs(context.Background(), shortURL, originalURL); err != nil {
+		t.Fatalf("Failed to store URL: %v", err)
+	}
+
+	before, err := database.GetURLMap(context.Background(), shortURL)
+	if err != nil {
+		t.Fatalf("Failed to get URL map: %v", err)
+	}
+
+	urlMaps, err := database.GetURLMaps(context.Background(), []string{shortURL, "nonexistent"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(urlMaps) != 1 || urlMaps[0].OriginalURL != originalURL {
+		t.Errorf("Expected only %s but got %+v", shortURL, urlMaps)
+	}
+
+	after, err := database.GetURLMap(context.Background(), shortURL)
+	if err != nil {
+		t.Fatalf("Failed to get URL map: %v", err)
+	}
+	if after.Hits != before.Hits {
+		t.Errorf("Expected hits to stay at %d but got %d", before.Hits, after.Hits)
+	}
+}
+
+func TestStoreURLWithOptions(t *testing.T) {
+	database := setupTestDB(t)
+	defer cleanupTestDB(database)
+
+	options := db.LinkOptions{RedirectCode: 302, CacheControl: "no-store", PasswordHash: "$2a$10$hash"}
+
+	if _, err := database.StoreURLWithOptions(context.Background(), "opts123", "https://options.example.com", options); err != nil {
+		t.Fatalf("Failed to store URL: %v", err)
+	}
+
+	urlMap, visited, err := database.VisitURL(context.Background(), "opts123", db.VisitGuard{
+		Now:                 time.Now(),
+		PasswordFingerprint: db.PasswordFingerprint(options.PasswordHash),
+	})
+	if err != nil || !visited {
+		t.Fatalf("Expected the visit to be counted but got %v, %v", visited, err)
+	}
+	if !reflect.DeepEqual(urlMap.Options, options) {
+		t.Errorf("Expected options %+v but got %+v", options, urlMap.Options)
+	}
+	if urlMap.Hits != 1 {
+		t.Errorf("Expected 1 hit but got %d", urlMap.Hits)
+	}
+
+	_, err = database.StoreURLWithOptions(context.Background(), "opts123", "https://other.example.com", options)
+	var webErr *urlshortenererror.WebError
+	if !errors.As(err, &webErr) || webErr.ErrType != urlshortenererror.ErrDuplicate {
+		t.Errorf("Expected duplicate error but got %v", err)
+	}
+}
+
+func TestStoreURLsSkipsProtectedLinks(t *testing.T) {
+	database := setupTestDB(t)
+	defer cleanupTestDB(database)
+
+	ctx := context.Background()
+	originalURL := "https://protected.example.com"
+	options := db.LinkOptions{PasswordHash: "$2a$10$hash"}
+	if _, err := database.StoreURLWithOptions(ctx, "locked1", originalURL, options); err != nil {
+		t.Fatalf("Failed to store URL: %v", err)
+	}
+
+	result, err := database.StoreURLs(ctx, "plain1", originalURL)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result != "plain1" {
+		t.Errorf("Expected a new short URL plain1 but got %s", result)
+	}
+
+	urlMap, err := database.GetURLMap(ctx, "locked1")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if urlMap.Hits != 0 {
+		t.Errorf("Expected the protected link to keep 0 hits but got %d", urlMap.Hits)
+	}
+}
+
+func TestVisitURLVariant(t *testing.T) {
+	database := setupTestDB(t)
+	defer cleanupTestDB(database)
+
+	options := db.LinkOptions{Variants: []db.Variant{
+		{Name: "a", Destination: "https://a.example.com", Weight: 1},
+		{Name: "b", Destination: "https://b.example.com", Weight: 1},
+	}}
+	if _, err := database.StoreURLWithOptions(context.Background(), "split1", "https://a.example.com", options); err != nil {
+		t.Fatalf("Failed to store URL: %v", err)
+	}
+
+	for _, variant := range []string{"a", "b", "b"} {
+		if err := database.CountVariantHit(context.Background(), "split1", variant); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	variantHits, err := database.GetVariantHits(context.Background(), []string{"split1"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if variantHits["split1"]["a"] != 1 || variantHits["split1"]["b"] != 2 {
+		t.Errorf("Expected 1 hit for a and 2 for b but got %v", variantHits["split1"])
+	}
+}
+
+func TestVisitURLOneTime(t *testing.T) {
+	database := setupTestDB(t)
+	defer cleanupTestDB(database)
+
+	if _, err := database.StoreURLWithOptions(context.Background(), "once123", "https://once.example.com", db.LinkOptions{OneTime: true}); err != nil {
+		t.Fatalf("Failed to store URL: %v", err)
+	}
+
+	const visitors = 10
+	results := make(chan *db.URLMap, visitors)
+	for range visitors {
+		go func() {
+			urlMap, visited, err := database.VisitURL(context.Background(), "once123", db.VisitGuard{Now: time.Now()})
+			if err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+			if !visited {
+				urlMap = nil
+			}
+			results <- urlMap
+		}()
+	}
+
+	succeeded := 0
+	for range visitors {
+		if <-results != nil {
+			succeeded++
+		}
+	}
+	if succeeded != 1 {
+		t.Errorf("Expected exactly 1 successful visit but got %d", succeeded)
+	}
+
+	urlMap, err := database.GetURLMap(context.Background(), "once123")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if urlMap.ConsumedAt == nil || urlMap.Hits != 1 {
+		t.Errorf("Expected the link to be consumed after 1 hit but got %+v", urlMap)
+	}
+}
+
+func TestVisitURLGuard(t *testing.T) {
+	database := setupTestDB(t)
+	defer cleanupTestDB(database)
+
+	ctx := context.Background()
+	now := time.Now()
+	links := map[string]db.LinkOptions{
+		"guard1": {Schedule: &db.Schedule{ActiveFrom: now.Add(time.Hour).Format(time.RFC3339)}},
+		"guard2": {PasswordHash: "$2a$10$hash"},
+		"guard3": {},
+	}
+	for shortURL, options := range links {
+		if _, err := database.StoreURLWithOptions(ctx, shortURL, "https://guard.example.com", options); err != nil {
+			t.Fatalf("Failed to store URL: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		shortURL string
+		guard    db.VisitGuard
+		visited  bool
+	}{
+		{name: "Not active yet", shortURL: "guard1", guard: db.VisitGuard{Now: now}},
+		{name: "Active", shortURL: "guard1", guard: db.VisitGuard{Now: now.Add(2 * time.Hour)}, visited: true},
+		{name: "Locked", shortURL: "guard2", guard: db.VisitGuard{Now: now, PasswordFingerprint: "forged"}},
+		{name: "Unlocked", shortURL: "guard2", guard: db.VisitGuard{Now: now, PasswordFingerprint: db.PasswordFingerprint("$2a$10$hash")}, visited: true},
+		{name: "Extra path without passthrough", shortURL: "guard3", guard: db.VisitGuard{Now: now, ExtraPath: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before, err := database.GetURLMap(ctx, tt.shortURL)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			urlMap, visited, err := database.VisitURL(ctx, tt.shortURL, tt.guard)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if visited != tt.visited || urlMap.ShortURL != tt.shortURL {
+				t.Errorf("Expected visited %v for %s but got %v for %+v", tt.visited, tt.shortURL, visited, urlMap)
+			}
+
+			after, err := database.GetURLMap(ctx, tt.shortURL)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if counted := after.Hits - before.Hits; counted != map[bool]int64{true: 1}[tt.visited] {
+				t.Errorf("Expected only counted visits to add a hit but got %d", counted)
+			}
+		})
+	}
+
+	_, _, err := database.VisitURL(ctx, "guard404", db.VisitGuard{Now: now})
+	var webErr *urlshortenererror.WebError
+	if !errors.As(err, &webErr) || webErr.ErrType != urlshortenererror.ErrNotFound {
+		t.Errorf("Expected not found error but got %v", err)
+	}
+}
+
+func TestRecordLinkCheck(t *testing.T) {
+	database := setupTestDB(t)
+	defer cleanupTestDB(database)
+
+	ctx := context.Background()
+	fallback := db.LinkOptions{Fallback: "https://fallback.example.com"}
+	if _, err := database.StoreURLWithOptions(ctx, "check123", "https://check.example.com", fallback); err != nil {
+		t.Fatalf("Failed to store URL: %v", err)
+	}
+	if _, err := database.StoreURLs(ctx, "check124", "https://nofallback.example.com"); err != nil {
+		t.Fatalf("Failed to store URL: %v", err)
+	}
+	hidden := db.LinkOptions{Fallback: "https://fallback.example.com", OneTime: true}
+	if _, err := database.StoreURLWithOptions(ctx, "check125", "https://hidden.example.com", hidden); err != nil {
+		t.Fatalf("Failed to store URL: %v", err)
+	}
+
+	due, err := database.GetLinksToCheck(ctx, time.Now(), 1000)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !containsShortURL(due, "check123") {
+		t.Fatalf("Expected the unchecked link to be due but got %v", due)
+	}
+	if containsShortURL(due, "check124") || containsShortURL(due, "check125") {
+		t.Errorf("Expected only links with a visible fallback to be due but got %v", due)
+	}
+
+	// A claimed link is not handed to another checker before it is recorded.
+	again, err := database.GetLinksToCheck(ctx, time.Now().Add(-time.Minute), 1000)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if containsShortURL(again, "check123") {
+		t.Errorf("Expected the claimed link not to be due again but got %v", again)
+	}
+
+	checkedAt := time.Now()
+	for i, ok := range []bool{false, false, true, false} {
+		check := db.LinkCheck{ShortURL: "check123", CheckedAt: checkedAt.Add(time.Duration(i) * time.Second), OK: ok}
+		if err = database.RecordLinkCheck(context.Background(), check); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	urlMap, err := database.GetURLMap(context.Background(), "check123")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if urlMap.ConsecutiveFailures != 1 || urlMap.CheckedAt == nil {
+		t.Errorf("Expected 1 failure since the last success but got %d", urlMap.ConsecutiveFailures)
+	}
+
+	checks, err := database.GetLinkChecks(context.Background(), "check123")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(checks) != 4 || checks[0].OK {
+		t.Errorf("Expected 4 checks, most recent first, but got %v", checks)
+	}
+
+	due, err = database.GetLinksToCheck(context.Background(), checkedAt, 1000)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if containsShortURL(due, "check123") {
+		t.Errorf("Expected the checked link not to be due but got %v", due)
+	}
+}
+
+func containsShortURL(urlMaps []db.URLMap, shortURL string) bool {
+	for _, urlMap := range urlMaps {
+		if urlMap.ShortURL == shortURL {
+			return true
+		}
+	}
+
+	return false
+}
+
+func TestLinkKey(t *testing.T) {
+	tests := []struct {
+		domain string
+		code   string
+		key    string
+	}{
+		{domain: "", code: "abc123", key: "abc123"},
+		{domain: "a.co", code: "abc123", key: "a.co/abc123"},
+	}
+
+	for _, tt := range tests {
+		if key := db.LinkKey(tt.domain, tt.code); key != tt.key {
+			t.Errorf("Expected key %s but got %s", tt.key, key)
+		}
+		if domain, code := db.SplitLinkKey(tt.key); domain != tt.domain || code != tt.code {
+			t.Errorf("Expected %q and %q but got %q and %q", tt.domain, tt.code, domain, code)
+		}
+	}
+}
+
+func TestDomains(t *testing.T) {
+	database := setupTestDB(t)
+	defer cleanupTestDB(database)
+
+	ctx := context.Background()
+	domain := db.Domain{Host: "brand-a.example", AccountID: "acct-1", RedirectCode: 302}
+	if _, err := database.StoreDomain(ctx, domain); err != nil {
+		t.Fatalf("Failed to store domain: %v", err)
+	}
+	defer func() {
+		if err := database.DeleteDomain(ctx, domain.Host, domain.AccountID); err != nil {
+			t.Errorf("Failed to delete domain: %v", err)
+		}
+	}()
+
+	domain.NotFoundURL = "https://brand-a.example/404"
+	stored, err := database.StoreDomain(ctx, domain)
+	if err != nil || stored.NotFoundURL != domain.NotFoundURL {
+		t.Errorf("Expected the owner to update the domain but got %v, %v", stored, err)
+	}
+
+	_, err = database.StoreDomain(ctx, db.Domain{Host: domain.Host, AccountID: "acct-2"})
+	var webErr *urlshortenererror.WebError
+	if !errors.As(err, &webErr) || webErr.Code != http.StatusConflict {
+		t.Errorf("Expected a conflict for another account but got %v", err)
+	}
+
+	if err = database.DeleteDomain(ctx, domain.Host, "acct-2"); !errors.As(err, &webErr) || webErr.Code != http.StatusNotFound {
+		t.Errorf("Expected another account not to delete the domain but got %v", err)
+	}
+
+	domains, err := database.GetDomains(ctx, "acct-1")
+	if err != nil || len(domains) != 1 || domains[0].Host != domain.Host {
+		t.Errorf("Expected the domain of the account but got %v, %v", domains, err)
+	}
+
+	// The same destination gets a link of its own in every namespace.
+	defaultKey, err := database.StoreURLs(ctx, "dom123", "https://namespaces.example.com")
+	if err != nil {
+		t.Fatalf("Failed to store URL: %v", err)
+	}
+	domainKey, err := database.StoreURLs(ctx, db.LinkKey(domain.Host, "dom123"), "https://namespaces.example.com")
+	if err != nil {
+		t.Fatalf("Failed to store URL: %v", err)
+	}
+	if domainKey == defaultKey || domainKey != db.LinkKey(domain.Host, "dom123") {
+		t.Errorf("Expected a link of the domain but got %s", domainKey)
+	}
+}
+
+func TestReadiness(t *testing.T) {
+	database := setupTestDB(t)
+	defer cleanupTestDB(database)
+
+	if err := database.Ping(context.Background()); err != nil {
+		t.Errorf("Expected the database to answer but got %v", err)
+	}
+
+	pending, err := database.PendingMigrations(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(pending) != 0 {
+		t.Errorf("Expected no pending migrations after Migrate but got %v", pending)
+	}
+}
+
+func TestOperationTimeout(t *testing.T) {
+	database, err := db.New(
+		testConfig.user,
+		testConfig.password,
+		testConfig.host,
+		testConfig.dbname,
+		testConfig.port,
+		db.WithReadTimeout(time.Nanosecond),
+	)
+	if err != nil {
+		t.Fatalf("Failed to connect to test database: %v", err)
+	}
+	defer cleanupTestDB(database)
+
+	_, err = database.GetURLMap(context.Background(), "abc123")
+	var webErr *urlshortenererror.WebError
+	if !errors.As(err, &webErr) || !errors.Is(webErr.ErrType, urlshortenererror.ErrTimeout) || webErr.Code != http.StatusGatewayTimeout {
+		t.Errorf("Expected a 504 timeout error but got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = database.GetURLMaps(ctx, []string{"abc123"})
+	if !errors.As(err, &webErr) || !errors.Is(webErr.ErrType, urlshortenererror.ErrTimeout) || webErr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected a 503 error for a cancelled request but got %v", err)
+	}
+}
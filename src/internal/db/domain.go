@@ -0,0 +1,133 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/urlshortenererror"
+)
+
+// Domain is a custom domain serving its own namespace of short URLs.
+type Domain struct {
+	CreatedAt time.Time `json:"created_at"`
+	Host      string    `json:"host"`
+	// AccountID identifies the account owning the domain in the system
+	// managing the accounts, only its owner can change or remove it.
+	AccountID string `json:"account_id"`
+	// NotFoundURL is where unknown short URLs of the domain redirect to,
+	// empty to show the not found page.
+	NotFoundURL string `json:"not_found_url,omitempty"`
+	// RedirectCode replaces the deployment default for links of the domain
+	// without their own, 0 keeps the default.
+	RedirectCode int `json:"redirect_code,omitempty"`
+}
+
+// LinkKey returns the key a short code is stored under. Links of a custom
+// domain are stored as <host>/<code>, links of the default namespace, the
+// empty domain, under the bare code. Codes never contain a slash.
+func LinkKey(domain, code string) string {
+	if domain == "" {
+		return code
+	}
+
+	return domain + "/" + code
+}
+
+// SplitLinkKey returns the domain and the short code of a stored key.
+func SplitLinkKey(key string) (domain, code string) {
+	domain, code, found := strings.Cut(key, "/")
+	if !found {
+		return "", key
+	}
+
+	return domain, code
+}
+
+// domainColumns lists the columns read into a Domain by scanDomain.
+const domainColumns = `host, account_id, redirect_code, not_found_url, created_at`
+
+func scanDomain(row pgx.Row, domain *Domain) error {
+	return row.Scan(&domain.Host, &domain.AccountID, &domain.RedirectCode, &domain.NotFoundURL, &domain.CreatedAt)
+}
+
+// GetDomains gets the custom domains, of one account or of all when the
+// account is empty, ordered by host.
+func (db *DB) GetDomains(ctx context.Context, accountID string) (_ []Domain, err error) {
+	ctx, finish := db.operation(ctx, "GetDomains", db.readTimeout)
+	defer finish(&err)
+
+	rows, err := db.pool.Query(ctx,
+		`SELECT `+domainColumns+`
+         FROM domains
+         WHERE $1 = '' OR account_id = $1
+         ORDER BY host`,
+		accountID)
+	if err != nil {
+		return nil, urlshortenererror.Wrap(err, "failed to get domains", http.StatusInternalServerError, urlshortenererror.ErrDBQuery)
+	}
+	defer rows.Close()
+
+	domains := []Domain{}
+	for rows.Next() {
+		var domain Domain
+		if err = scanDomain(rows, &domain); err != nil {
+			return nil, urlshortenererror.Wrap(err, "failed to read domain", http.StatusInternalServerError, urlshortenererror.ErrDBQuery)
+		}
+		domains = append(domains, domain)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, urlshortenererror.Wrap(err, "failed to get domains", http.StatusInternalServerError, urlshortenererror.ErrDBQuery)
+	}
+
+	return domains, nil
+}
+
+// StoreDomain adds a custom domain or updates its settings. A domain owned
+// by another account is not changed and reported as ErrDuplicate.
+func (db *DB) StoreDomain(ctx context.Context, domain Domain) (_ *Domain, err error) {
+	ctx, finish := db.operation(ctx, "StoreDomain", db.writeTimeout)
+	defer finish(&err)
+
+	var stored Domain
+	err = scanDomain(db.pool.QueryRow(ctx,
+		`INSERT INTO domains (host, account_id, redirect_code, not_found_url)
+         VALUES ($1, $2, $3, $4)
+         ON CONFLICT (host) DO UPDATE
+         SET redirect_code = EXCLUDED.redirect_code, not_found_url = EXCLUDED.not_found_url
+         WHERE domains.account_id = EXCLUDED.account_id
+         RETURNING `+domainColumns,
+		domain.Host, domain.AccountID, domain.RedirectCode, domain.NotFoundURL), &stored)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, urlshortenererror.Wrap(err, "The domain "+domain.Host+" belongs to another account", http.StatusConflict, urlshortenererror.ErrDuplicate)
+	}
+	if err != nil {
+		return nil, urlshortenererror.Wrap(err, "failed to store domain", http.StatusInternalServerError, urlshortenererror.ErrDBQuery)
+	}
+
+	return &stored, nil
+}
+
+// DeleteDomain removes a custom domain of the account. Its links are kept
+// and come back when the domain is added again.
+func (db *DB) DeleteDomain(ctx context.Context, host, accountID string) (err error) {
+	ctx, finish := db.operation(ctx, "DeleteDomain", db.writeTimeout)
+	defer finish(&err)
+
+	tag, err := db.pool.Exec(ctx,
+		`DELETE FROM domains WHERE host = $1 AND account_id = $2`,
+		host, accountID)
+	if err != nil {
+		return urlshortenererror.Wrap(err, "failed to delete domain", http.StatusInternalServerError, urlshortenererror.ErrDBQuery)
+	}
+	if tag.RowsAffected() == 0 {
+		return urlshortenererror.Wrap(nil, "Domain not found", http.StatusNotFound, urlshortenererror.ErrNotFound)
+	}
+
+	return nil
+}
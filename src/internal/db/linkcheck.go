@@ -0,0 +1,154 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/logging"
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/urlshortenererror"
+)
+
+// MaxLinkChecks is the number of checks kept in the history of a link.
+const MaxLinkChecks = 20
+
+// LinkCheck holds the result of probing the destination of a link.
+type LinkCheck struct {
+	CheckedAt  time.Time `json:"checked_at"`
+	ShortURL   string    `json:"-"`
+	Error      string    `json:"error,omitempty"`
+	StatusCode int       `json:"status_code,omitempty"`
+	OK         bool      `json:"ok"`
+}
+
+// GetLinksToCheck claims the links whose destination was not checked since
+// the given time, least recently checked first. Only links with a fallback
+// are checked, and never those whose destination is hidden behind a password
+// or a single use. Claimed links get their checked_at bumped right away and
+// rows locked by another instance are skipped, so no link is probed twice.
+func (db *DB) GetLinksToCheck(ctx context.Context, checkedBefore time.Time, limit int) (_ []URLMap, err error) {
+	ctx, finish := db.operation(ctx, "GetLinksToCheck", db.writeTimeout)
+	defer finish(&err)
+
+	rows, err := db.pool.Query(ctx,
+		`UPDATE urlmap
+         SET checked_at = NOW()
+         WHERE short_url IN (
+             SELECT short_url
+             FROM urlmap
+             WHERE (checked_at IS NULL OR checked_at < $1)
+               AND COALESCE(options->>'fallback', '') <> ''
+               AND password_hash = ''
+               AND NOT COALESCE((options->>'one_time')::boolean, FALSE)
+             ORDER BY checked_at NULLS FIRST
+             LIMIT $2
+             FOR UPDATE SKIP LOCKED
+         )
+         RETURNING `+urlMapColumns,
+		checkedBefore, limit)
+	if err != nil {
+		return nil, urlshortenererror.Wrap(err, "failed to get links to check", http.StatusInternalServerError, urlshortenererror.ErrDBQuery)
+	}
+	defer rows.Close()
+
+	var urlMaps []URLMap
+	for rows.Next() {
+		var urlMap URLMap
+		if err = scanURLMap(rows, &urlMap); err != nil {
+			return nil, urlshortenererror.Wrap(err, "failed to read URL", http.StatusInternalServerError, urlshortenererror.ErrDBQuery)
+		}
+		urlMaps = append(urlMaps, urlMap)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, urlshortenererror.Wrap(err, "failed to get links to check", http.StatusInternalServerError, urlshortenererror.ErrDBQuery)
+	}
+
+	return urlMaps, nil
+}
+
+// RecordLinkCheck stores the result of a check, updates the failure streak of
+// the link and trims its history to MaxLinkChecks entries.
+func (db *DB) RecordLinkCheck(ctx context.Context, check LinkCheck) (err error) {
+	ctx, finish := db.operation(ctx, "RecordLinkCheck", db.writeTimeout)
+	defer finish(&err)
+
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		return urlshortenererror.Wrap(err, "failed to begin transaction", http.StatusInternalServerError, urlshortenererror.ErrDBQuery)
+	}
+	defer func() {
+		if deferErr := tx.Rollback(context.Background()); deferErr != nil && !errors.Is(deferErr, pgx.ErrTxClosed) {
+			db.logger.Error("Failed to rollback transaction", logging.Error(deferErr))
+		}
+	}()
+
+	tag, err := tx.Exec(ctx,
+		`UPDATE urlmap
+         SET checked_at = $2,
+             consecutive_failures = CASE WHEN $3 THEN 0 ELSE consecutive_failures + 1 END
+         WHERE short_url = $1`,
+		check.ShortURL, check.CheckedAt, check.OK)
+	if err != nil {
+		return urlshortenererror.Wrap(err, "failed to update link health", http.StatusInternalServerError, urlshortenererror.ErrDBQuery)
+	}
+	if tag.RowsAffected() == 0 {
+		return urlshortenererror.Wrap(nil, "URL not found", http.StatusNotFound, urlshortenererror.ErrNotFound)
+	}
+
+	if _, err = tx.Exec(ctx,
+		`INSERT INTO link_checks (short_url, checked_at, status_code, error, ok)
+         VALUES ($1, $2, $3, $4, $5)`,
+		check.ShortURL, check.CheckedAt, check.StatusCode, check.Error, check.OK); err != nil {
+		return urlshortenererror.Wrap(err, "failed to store link check", http.StatusInternalServerError, urlshortenererror.ErrDBQuery)
+	}
+
+	if _, err = tx.Exec(ctx,
+		`DELETE FROM link_checks
+         WHERE short_url = $1 AND id NOT IN (
+             SELECT id FROM link_checks WHERE short_url = $1 ORDER BY checked_at DESC LIMIT $2
+         )`,
+		check.ShortURL, MaxLinkChecks); err != nil {
+		return urlshortenererror.Wrap(err, "failed to trim link checks", http.StatusInternalServerError, urlshortenererror.ErrDBQuery)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return urlshortenererror.Wrap(err, "failed to commit transaction", http.StatusInternalServerError, urlshortenererror.ErrDBQuery)
+	}
+
+	return nil
+}
+
+// GetLinkChecks gets the check history of a link, most recent first.
+func (db *DB) GetLinkChecks(ctx context.Context, shortURL string) (_ []LinkCheck, err error) {
+	ctx, finish := db.operation(ctx, "GetLinkChecks", db.readTimeout)
+	defer finish(&err)
+
+	rows, err := db.pool.Query(ctx,
+		`SELECT short_url, checked_at, status_code, error, ok
+         FROM link_checks
+         WHERE short_url = $1
+         ORDER BY checked_at DESC`,
+		shortURL)
+	if err != nil {
+		return nil, urlshortenererror.Wrap(err, "failed to get link checks", http.StatusInternalServerError, urlshortenererror.ErrDBQuery)
+	}
+	defer rows.Close()
+
+	checks := make([]LinkCheck, 0)
+	for rows.Next() {
+		var check LinkCheck
+		if err = rows.Scan(&check.ShortURL, &check.CheckedAt, &check.StatusCode, &check.Error, &check.OK); err != nil {
+			return nil, urlshortenererror.Wrap(err, "failed to read link check", http.StatusInternalServerError, urlshortenererror.ErrDBQuery)
+		}
+		checks = append(checks, check)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, urlshortenererror.Wrap(err, "failed to get link checks", http.StatusInternalServerError, urlshortenererror.ErrDBQuery)
+	}
+
+	return checks, nil
+}
@@ -0,0 +1,136 @@
+package db
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"io/fs"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/logging"
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/urlshortenererror"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// Migrate applies the embedded SQL migrations that have not been applied yet.
+// Each migration runs in its own transaction and is recorded in schema_migrations.
+func (db *DB) Migrate(ctx context.Context) error {
+	if _, err := db.pool.Exec(ctx,
+		`CREATE TABLE IF NOT EXISTS schema_migrations (
+             version    TEXT PRIMARY KEY,
+             applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+         )`); err != nil {
+		return urlshortenererror.Wrap(err, "failed to create schema_migrations", http.StatusInternalServerError, urlshortenererror.ErrDBQuery)
+	}
+
+	versions, err := migrationVersions()
+	if err != nil {
+		return err
+	}
+
+	for _, version := range versions {
+		if err = db.applyMigration(ctx, version); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (db *DB) applyMigration(ctx context.Context, version string) error {
+	script, err := migrations.ReadFile("migrations/" + version + ".sql")
+	if err != nil {
+		return urlshortenererror.Wrap(err, "failed to read migration "+version, http.StatusInternalServerError, urlshortenererror.ErrServerError)
+	}
+
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		return urlshortenererror.Wrap(err, "failed to begin transaction", http.StatusInternalServerError, urlshortenererror.ErrDBQuery)
+	}
+	defer func() {
+		if deferErr := tx.Rollback(context.Background()); deferErr != nil && !errors.Is(deferErr, pgx.ErrTxClosed) {
+			db.logger.Error("Failed to rollback transaction", logging.Error(deferErr))
+		}
+	}()
+
+	tag, err := tx.Exec(ctx,
+		`INSERT INTO schema_migrations (version) VALUES ($1) ON CONFLICT DO NOTHING`,
+		version)
+	if err != nil {
+		return urlshortenererror.Wrap(err, "failed to record migration "+version, http.StatusInternalServerError, urlshortenererror.ErrDBQuery)
+	}
+	if tag.RowsAffected() == 0 {
+		return nil
+	}
+
+	if _, err = tx.Exec(ctx, string(script)); err != nil {
+		return urlshortenererror.Wrap(err, "failed to apply migration "+version, http.StatusInternalServerError, urlshortenererror.ErrDBQuery)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return urlshortenererror.Wrap(err, "failed to commit transaction", http.StatusInternalServerError, urlshortenererror.ErrDBQuery)
+	}
+	db.logger.Info("Applied migration", "version", version)
+
+	return nil
+}
+
+// PendingMigrations returns the embedded migrations that have not been
+// applied to the database.
+func (db *DB) PendingMigrations(ctx context.Context) (_ []string, err error) {
+	ctx, finish := db.operation(ctx, "PendingMigrations", db.readTimeout)
+	defer finish(&err)
+
+	versions, err := migrationVersions()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.pool.Query(ctx, `SELECT version FROM schema_migrations`)
+	if err != nil {
+		return nil, urlshortenererror.Wrap(err, "failed to read applied migrations", http.StatusInternalServerError, urlshortenererror.ErrDBQuery)
+	}
+	defer rows.Close()
+
+	applied := make(map[string]bool, len(versions))
+	for rows.Next() {
+		var version string
+		if err = rows.Scan(&version); err != nil {
+			return nil, urlshortenererror.Wrap(err, "failed to read applied migrations", http.StatusInternalServerError, urlshortenererror.ErrDBQuery)
+		}
+		applied[version] = true
+	}
+	if err = rows.Err(); err != nil {
+		return nil, urlshortenererror.Wrap(err, "failed to read applied migrations", http.StatusInternalServerError, urlshortenererror.ErrDBQuery)
+	}
+
+	var pending []string
+	for _, version := range versions {
+		if !applied[version] {
+			pending = append(pending, version)
+		}
+	}
+
+	return pending, nil
+}
+
+// migrationVersions lists the embedded migrations in the order they apply.
+func migrationVersions() ([]string, error) {
+	entries, err := fs.ReadDir(migrations, "migrations")
+	if err != nil {
+		return nil, urlshortenererror.Wrap(err, "failed to list migrations", http.StatusInternalServerError, urlshortenererror.ErrServerError)
+	}
+
+	versions := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		versions = append(versions, strings.TrimSuffix(entry.Name(), ".sql"))
+	}
+	sort.Strings(versions)
+
+	return versions, nil
+}
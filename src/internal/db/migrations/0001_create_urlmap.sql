@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS urlmap (
+    short_url    VARCHAR(64) PRIMARY KEY,
+    original_url TEXT        NOT NULL,
+    hits         BIGINT      NOT NULL DEFAULT 0,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS urlmap_original_url_idx ON urlmap (original_url);
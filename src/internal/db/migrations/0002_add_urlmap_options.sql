@@ -0,0 +1 @@
+ALTER TABLE urlmap ADD COLUMN IF NOT EXISTS options JSONB NOT NULL DEFAULT '{}'::jsonb;
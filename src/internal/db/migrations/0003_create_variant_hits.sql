@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS variant_hits (
+    short_url VARCHAR(64) NOT NULL REFERENCES urlmap (short_url) ON DELETE CASCADE,
+    variant   VARCHAR(64) NOT NULL,
+    hits      BIGINT      NOT NULL DEFAULT 0,
+    PRIMARY KEY (short_url, variant)
+);
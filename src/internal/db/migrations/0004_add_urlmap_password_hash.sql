@@ -0,0 +1 @@
+ALTER TABLE urlmap ADD COLUMN IF NOT EXISTS password_hash TEXT NOT NULL DEFAULT '';
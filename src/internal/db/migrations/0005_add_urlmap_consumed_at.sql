@@ -0,0 +1 @@
+ALTER TABLE urlmap ADD COLUMN IF NOT EXISTS consumed_at TIMESTAMPTZ;
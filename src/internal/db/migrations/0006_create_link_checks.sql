@@ -0,0 +1,14 @@
+ALTER TABLE urlmap ADD COLUMN IF NOT EXISTS checked_at TIMESTAMPTZ;
+ALTER TABLE urlmap ADD COLUMN IF NOT EXISTS consecutive_failures INTEGER NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS link_checks (
+    id          BIGSERIAL PRIMARY KEY,
+    short_url   VARCHAR(64) NOT NULL REFERENCES urlmap (short_url) ON DELETE CASCADE,
+    checked_at  TIMESTAMPTZ NOT NULL,
+    status_code INTEGER     NOT NULL DEFAULT 0,
+    error       TEXT        NOT NULL DEFAULT '',
+    ok          BOOLEAN     NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS link_checks_short_url_checked_at ON link_checks (short_url, checked_at DESC);
+CREATE INDEX IF NOT EXISTS urlmap_checked_at ON urlmap (checked_at NULLS FIRST);
@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS domains (
+    host          VARCHAR(253) PRIMARY KEY,
+    account_id    TEXT         NOT NULL,
+    redirect_code INTEGER      NOT NULL DEFAULT 0,
+    not_found_url TEXT         NOT NULL DEFAULT '',
+    created_at    TIMESTAMPTZ  NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS domains_account_id ON domains (account_id);
+
+-- Links of a custom domain are stored under <host>/<code>, which needs room
+-- for the host. The domain column keeps reused short URLs in their namespace.
+ALTER TABLE urlmap ALTER COLUMN short_url TYPE VARCHAR(320);
+ALTER TABLE variant_hits ALTER COLUMN short_url TYPE VARCHAR(320);
+ALTER TABLE link_checks ALTER COLUMN short_url TYPE VARCHAR(320);
+ALTER TABLE urlmap ADD COLUMN IF NOT EXISTS domain VARCHAR(253) NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS urlmap_domain_original_url_idx ON urlmap (domain, original_url);
@@ -0,0 +1,250 @@
+// Package forwarded reads the client address, scheme and host that trusted
+// reverse proxies pass on in the Forwarded and X-Forwarded-* headers.
+package forwarded
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"regexp"
+	"strings"
+)
+
+// Headers read from trusted proxies.
+const (
+	HeaderForwarded      = "Forwarded"
+	HeaderForwardedFor   = "X-Forwarded-For"
+	HeaderForwardedProto = "X-Forwarded-Proto"
+	HeaderForwardedHost  = "X-Forwarded-Host"
+)
+
+// validHost matches host names and addresses with an optional port, so a
+// forwarded host cannot inject a path or user info into generated links.
+var validHost = regexp.MustCompile(`^(\[[0-9A-Fa-f:.]+\]|[A-Za-z0-9.-]+)(:[0-9]{1,5})?$`)
+
+type contextKey struct{}
+
+// Trusted holds the networks of the proxies whose forwarded headers are
+// believed. Headers from any other peer are ignored, as clients can set them.
+// A nil Trusted trusts no one.
+type Trusted struct {
+	prefixes []netip.Prefix
+}
+
+// ParseTrusted reads proxy addresses and networks, e.g. 10.0.0.1 or 10.0.0.0/8.
+// It returns nil for an empty list.
+func ParseTrusted(entries []string) (*Trusted, error) {
+	if len(entries) == 0 {
+		return nil, nil
+	}
+
+	trusted := &Trusted{prefixes: make([]netip.Prefix, 0, len(entries))}
+	for _, entry := range entries {
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(entry)
+			if addrErr != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q, expected an address or a network", entry)
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		trusted.prefixes = append(trusted.prefixes, prefix.Masked())
+	}
+
+	return trusted, nil
+}
+
+// Contains reports whether the address belongs to a trusted proxy.
+func (t *Trusted) Contains(addr netip.Addr) bool {
+	if t == nil {
+		return false
+	}
+
+	addr = addr.Unmap()
+	for _, prefix := range t.prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// forwardedFor holds what the proxies passed on about the original request.
+type forwardedFor struct {
+	// chain lists the client and the proxies before the last one, oldest first.
+	chain []string
+	// protos and hosts list the protocols and hosts the proxies passed on,
+	// oldest first. They line up with the chain when every proxy added one.
+	protos []string
+	hosts  []string
+}
+
+// entry returns what the proxy that added the hop at index i of the chain
+// passed on. A list that does not line up with the chain is only believed for
+// its last entry, which the nearest proxy added.
+func (f *forwardedFor) entry(entries []string, i int) string {
+	switch {
+	case len(entries) == 0:
+		return ""
+	case len(entries) == len(f.chain):
+		return entries[i]
+	default:
+		return entries[len(entries)-1]
+	}
+}
+
+// Middleware rewrites requests received from a trusted proxy as the client
+// sent them: RemoteAddr becomes the client address, Host the forwarded host
+// and Scheme reports the forwarded protocol. The Forwarded header takes
+// precedence over the X-Forwarded-* headers. The client is the last address
+// in the chain that is not a trusted proxy, and the protocol and host are
+// taken from what the proxy that received the client's request added.
+func (t *Trusted) Middleware(next http.Handler) http.Handler {
+	if t == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
+		peer, err := remoteAddr(req.RemoteAddr)
+		if err != nil || !t.Contains(peer) {
+			next.ServeHTTP(wr, req)
+
+			return
+		}
+
+		info := parseForwarded(req.Header.Values(HeaderForwarded))
+		if info == nil {
+			info = parseXForwarded(req.Header)
+		}
+
+		client, hop := t.client(info.chain)
+
+		ctx := req.Context()
+		if proto := strings.ToLower(info.entry(info.protos, hop)); proto == "http" || proto == "https" {
+			ctx = context.WithValue(ctx, contextKey{}, proto)
+		}
+		req = req.WithContext(ctx)
+
+		if host := info.entry(info.hosts, hop); host != "" && validHost.MatchString(host) {
+			req.Host = host
+		}
+		if client.IsValid() {
+			req.RemoteAddr = client.String()
+		}
+
+		next.ServeHTTP(wr, req)
+	})
+}
+
+// client walks the chain back from the nearest proxy and returns the first
+// address that is not trusted. When every hop is trusted, the oldest one is
+// the client. It also returns the index of the hop added by the outermost
+// trusted proxy, the one the client connected to. Hops before it come from
+// the client and cannot be believed.
+func (t *Trusted) client(chain []string) (netip.Addr, int) {
+	var client netip.Addr
+	i := len(chain) - 1
+	for ; i >= 0; i-- {
+		addr, err := remoteAddr(chain[i])
+		if err != nil {
+			// Hidden or unknown hops end the chain that can be followed.
+			break
+		}
+		client = addr
+		if !t.Contains(addr) {
+			break
+		}
+	}
+
+	return client, max(i, 0)
+}
+
+// Scheme returns the protocol the client used, https or http, from a trusted
+// proxy or the connection itself.
+func Scheme(req *http.Request) string {
+	if scheme, ok := req.Context().Value(contextKey{}).(string); ok {
+		return scheme
+	}
+	if req.TLS != nil {
+		return "https"
+	}
+
+	return "http"
+}
+
+// remoteAddr reads an address with or without a port, IPv6 addresses may be
+// in brackets.
+func remoteAddr(value string) (netip.Addr, error) {
+	value = strings.TrimSpace(value)
+	if host, _, err := net.SplitHostPort(value); err == nil {
+		value = host
+	}
+	addr, err := netip.ParseAddr(strings.Trim(value, "[]"))
+	if err != nil {
+		return netip.Addr{}, fmt.Errorf("invalid address %q: %w", value, err)
+	}
+
+	return addr.Unmap(), nil
+}
+
+// parseForwarded reads the Forwarded headers of RFC 7239, it returns nil
+// without any. Every element describes one hop, so the protocols and hosts
+// line up with the chain.
+func parseForwarded(values []string) *forwardedFor {
+	if len(values) == 0 {
+		return nil
+	}
+
+	info := &forwardedFor{}
+	for _, value := range values {
+		for _, element := range strings.Split(value, ",") {
+			var hop, proto, host string
+			for _, pair := range strings.Split(element, ";") {
+				key, val, found := strings.Cut(strings.TrimSpace(pair), "=")
+				if !found {
+					continue
+				}
+				val = strings.Trim(val, `"`)
+
+				switch strings.ToLower(key) {
+				case "for":
+					hop = val
+				case "proto":
+					proto = val
+				case "host":
+					host = val
+				}
+			}
+			info.chain = append(info.chain, hop)
+			info.protos = append(info.protos, proto)
+			info.hosts = append(info.hosts, host)
+		}
+	}
+
+	return info
+}
+
+// parseXForwarded reads the X-Forwarded-* headers, proxies append to a comma
+// separated list in each of them.
+func parseXForwarded(header http.Header) *forwardedFor {
+	return &forwardedFor{
+		chain:  entries(header.Values(HeaderForwardedFor)),
+		protos: entries(header.Values(HeaderForwardedProto)),
+		hosts:  entries(header.Values(HeaderForwardedHost)),
+	}
+}
+
+// entries splits comma separated header values into their entries.
+func entries(values []string) []string {
+	var list []string
+	for _, value := range values {
+		for _, entry := range strings.Split(value, ",") {
+			list = append(list, strings.TrimSpace(entry))
+		}
+	}
+
+	return list
+}
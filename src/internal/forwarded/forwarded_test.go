@@ -0,0 +1,171 @@
+package forwarded_test
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/forwarded"
+)
+
+type seen struct {
+	remoteAddr string
+	host       string
+	scheme     string
+}
+
+func serve(t *testing.T, trusted *forwarded.Trusted, req *http.Request) seen {
+	t.Helper()
+
+	var got seen
+	trusted.Middleware(http.HandlerFunc(func(_ http.ResponseWriter, req *http.Request) {
+		got = seen{remoteAddr: req.RemoteAddr, host: req.Host, scheme: forwarded.Scheme(req)}
+	})).ServeHTTP(httptest.NewRecorder(), req)
+
+	return got
+}
+
+func TestParseTrusted(t *testing.T) {
+	trusted, err := forwarded.ParseTrusted(nil)
+	if err != nil || trusted != nil {
+		t.Errorf("Expected nil for an empty list, got %v, %v", trusted, err)
+	}
+
+	if _, err = forwarded.ParseTrusted([]string{"10.0.0.0/8", "::1", "192.168.1.1"}); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	if _, err = forwarded.ParseTrusted([]string{"proxy.local"}); err == nil {
+		t.Error("Expected an error for a host name")
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	trusted, err := forwarded.ParseTrusted([]string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		headers    map[string]string
+		trusted    *forwarded.Trusted
+		want       seen
+	}{
+		{
+			name:       "untrusted peer",
+			remoteAddr: "203.0.113.5:1234",
+			headers:    map[string]string{"X-Forwarded-For": "198.51.100.1", "X-Forwarded-Proto": "https", "X-Forwarded-Host": "evil.example"},
+			trusted:    trusted,
+			want:       seen{remoteAddr: "203.0.113.5:1234", host: "sho.rt", scheme: "http"},
+		},
+		{
+			name:       "no trusted proxies",
+			remoteAddr: "10.0.0.2:1234",
+			headers:    map[string]string{"X-Forwarded-For": "198.51.100.1", "X-Forwarded-Proto": "https"},
+			want:       seen{remoteAddr: "10.0.0.2:1234", host: "sho.rt", scheme: "http"},
+		},
+		{
+			name:       "x-forwarded headers",
+			remoteAddr: "10.0.0.2:1234",
+			headers:    map[string]string{"X-Forwarded-For": "198.51.100.1", "X-Forwarded-Proto": "https", "X-Forwarded-Host": "links.example"},
+			trusted:    trusted,
+			want:       seen{remoteAddr: "198.51.100.1", host: "links.example", scheme: "https"},
+		},
+		{
+			name:       "spoofed entries before the client are skipped",
+			remoteAddr: "10.0.0.2:1234",
+			headers:    map[string]string{"X-Forwarded-For": "1.2.3.4, 198.51.100.1, 10.0.0.9"},
+			trusted:    trusted,
+			want:       seen{remoteAddr: "198.51.100.1", host: "sho.rt", scheme: "http"},
+		},
+		{
+			name:       "forwarded header",
+			remoteAddr: "10.0.0.2:1234",
+			headers: map[string]string{
+				"Forwarded":       `for="[2001:db8::1]:4711";proto=https;host=links.example, for=10.0.0.7`,
+				"X-Forwarded-For": "198.51.100.1",
+			},
+			trusted: trusted,
+			want:    seen{remoteAddr: "2001:db8::1", host: "links.example", scheme: "https"},
+		},
+		{
+			name:       "forwarded elements sent by the client are ignored",
+			remoteAddr: "10.0.0.2:1234",
+			headers: map[string]string{
+				"Forwarded": `host=evil.example;proto=https, for=198.51.100.1;proto=http;host=links.example`,
+			},
+			trusted: trusted,
+			want:    seen{remoteAddr: "198.51.100.1", host: "links.example", scheme: "http"},
+		},
+		{
+			name:       "forwarded host from the proxy the client connected to",
+			remoteAddr: "10.0.0.2:1234",
+			headers: map[string]string{
+				"Forwarded": `for=203.0.113.9;host=evil.example, for=198.51.100.1;host=links.example;proto=https, for=10.0.0.7;host=internal.local`,
+			},
+			trusted: trusted,
+			want:    seen{remoteAddr: "198.51.100.1", host: "links.example", scheme: "https"},
+		},
+		{
+			name:       "x-forwarded host appended to a client value",
+			remoteAddr: "10.0.0.2:1234",
+			headers: map[string]string{
+				"X-Forwarded-For":   "198.51.100.1",
+				"X-Forwarded-Host":  "evil.example, links.example",
+				"X-Forwarded-Proto": "https, http",
+			},
+			trusted: trusted,
+			want:    seen{remoteAddr: "198.51.100.1", host: "links.example", scheme: "http"},
+		},
+		{
+			name:       "x-forwarded lists lined up with the chain",
+			remoteAddr: "10.0.0.2:1234",
+			headers: map[string]string{
+				"X-Forwarded-For":  "203.0.113.9, 198.51.100.1, 10.0.0.7",
+				"X-Forwarded-Host": "evil.example, links.example, internal.local",
+			},
+			trusted: trusted,
+			want:    seen{remoteAddr: "198.51.100.1", host: "links.example", scheme: "http"},
+		},
+		{
+			name:       "invalid host and proto are ignored",
+			remoteAddr: "10.0.0.2:1234",
+			headers:    map[string]string{"X-Forwarded-Proto": "gopher", "X-Forwarded-Host": "evil.example/path"},
+			trusted:    trusted,
+			want:       seen{remoteAddr: "10.0.0.2:1234", host: "sho.rt", scheme: "http"},
+		},
+		{
+			name:       "unknown client",
+			remoteAddr: "10.0.0.2:1234",
+			headers:    map[string]string{"Forwarded": "for=unknown"},
+			trusted:    trusted,
+			want:       seen{remoteAddr: "10.0.0.2:1234", host: "sho.rt", scheme: "http"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "http://sho.rt/abc", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+
+			if got := serve(t, tt.trusted, req); got != tt.want {
+				t.Errorf("Expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestScheme_TLS(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "https://sho.rt/abc", nil)
+	req.TLS = &tls.ConnectionState{}
+
+	if got := forwarded.Scheme(req); got != "https" {
+		t.Errorf("Expected https, got %s", got)
+	}
+}
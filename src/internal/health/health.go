@@ -0,0 +1,170 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/logging"
+)
+
+// DefaultTimeout bounds all readiness checks of a probe.
+const DefaultTimeout = 2 * time.Second
+
+// Statuses of a probe and its checks.
+const (
+	StatusOK          = "ok"
+	StatusUnavailable = "unavailable"
+)
+
+// ErrDraining is reported by the readiness probe once shutdown has started.
+var ErrDraining = errors.New("server is shutting down")
+
+// Check reports whether a dependency of the server is usable.
+type Check func(ctx context.Context) error
+
+// CheckResult is the outcome of a single check. Why a check failed is only
+// logged, as the probe endpoints are public and errors can reveal internals
+// such as database addresses.
+type CheckResult struct {
+	Status string `json:"status"`
+}
+
+// Response is the body of the probe endpoints.
+type Response struct {
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+	Status string                 `json:"status"`
+}
+
+type namedCheck struct {
+	check Check
+	name  string
+}
+
+// Probe serves the liveness and readiness endpoints of the server.
+type Probe struct {
+	logger   *slog.Logger
+	checks   []namedCheck
+	timeout  time.Duration
+	draining atomic.Bool
+}
+
+// Option type for functional options.
+type Option func(*Probe)
+
+// WithCheck adds a check the server must pass to be ready.
+func WithCheck(name string, check Check) Option {
+	return func(p *Probe) {
+		p.checks = append(p.checks, namedCheck{name: name, check: check})
+	}
+}
+
+// WithTimeout sets how long the readiness checks may take together.
+func WithTimeout(timeout time.Duration) Option {
+	return func(p *Probe) {
+		p.timeout = timeout
+	}
+}
+
+// WithLogger sets the logger of the probe.
+func WithLogger(logger *slog.Logger) Option {
+	return func(p *Probe) {
+		p.logger = logger
+	}
+}
+
+// New creates a new Probe instance.
+func New(opts ...Option) *Probe {
+	p := &Probe{timeout: DefaultTimeout, logger: slog.Default()}
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	return p
+}
+
+// StartDraining makes the server report as not ready, so load balancers stop
+// sending new requests before it shuts down.
+func (p *Probe) StartDraining() {
+	p.draining.Store(true)
+}
+
+// Live answers as long as the process can serve requests.
+func (p *Probe) Live() http.Handler {
+	return http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
+		p.writeResponse(wr, req, http.StatusOK, Response{Status: StatusOK})
+	})
+}
+
+// Ready runs the checks and answers 503 when any of them fails or the
+// server is draining.
+func (p *Probe) Ready() http.Handler {
+	return http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
+		response := p.Check(req.Context())
+
+		code := http.StatusOK
+		if response.Status != StatusOK {
+			code = http.StatusServiceUnavailable
+		}
+		p.writeResponse(wr, req, code, response)
+	})
+}
+
+// Check runs the readiness checks concurrently and logs why any failed.
+func (p *Probe) Check(ctx context.Context) Response {
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	response := Response{Status: StatusOK, Checks: make(map[string]CheckResult, len(p.checks)+1)}
+	response.Checks["draining"] = result(nil)
+	if p.draining.Load() {
+		response.Checks["draining"] = result(ErrDraining)
+		response.Status = StatusUnavailable
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, named := range p.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := named.check(ctx)
+			if err != nil {
+				p.logger.WarnContext(ctx, "Readiness check failed", slog.String("check", named.name), logging.Error(err))
+			}
+			checkResult := result(err)
+
+			mu.Lock()
+			defer mu.Unlock()
+			response.Checks[named.name] = checkResult
+			if checkResult.Status != StatusOK {
+				response.Status = StatusUnavailable
+			}
+		}()
+	}
+	wg.Wait()
+
+	return response
+}
+
+func result(err error) CheckResult {
+	if err != nil {
+		return CheckResult{Status: StatusUnavailable}
+	}
+
+	return CheckResult{Status: StatusOK}
+}
+
+func (p *Probe) writeResponse(wr http.ResponseWriter, req *http.Request, code int, response Response) {
+	wr.Header().Set("Content-Type", "application/json; charset=utf-8")
+	wr.Header().Set("Cache-Control", "no-store")
+	wr.WriteHeader(code)
+	if err := json.NewEncoder(wr).Encode(response); err != nil {
+		p.logger.ErrorContext(req.Context(), "Failed to write health response", logging.Error(err))
+	}
+}
@@ -0,0 +1,118 @@
+package health_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/health"
+)
+
+func serve(t *testing.T, handler http.Handler) (int, health.Response) {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	var response health.Response
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("Expected a JSON body, got %v", err)
+	}
+
+	return rec.Code, response
+}
+
+func TestLive(t *testing.T) {
+	probe := health.New(health.WithCheck("database", func(context.Context) error {
+		return errors.New("connection refused")
+	}))
+	probe.StartDraining()
+
+	// Liveness ignores the dependencies, restarting would not fix them.
+	code, response := serve(t, probe.Live())
+	if code != http.StatusOK || response.Status != health.StatusOK {
+		t.Errorf("Expected 200 ok, got %d %s", code, response.Status)
+	}
+}
+
+func TestReady(t *testing.T) {
+	failing := func(context.Context) error { return errors.New("connection refused") }
+	passing := func(context.Context) error { return nil }
+	slow := func(ctx context.Context) error {
+		<-ctx.Done()
+
+		return ctx.Err()
+	}
+
+	tests := []struct {
+		name     string
+		checks   map[string]health.Check
+		failed   []string
+		draining bool
+	}{
+		{name: "ready", checks: map[string]health.Check{"database": passing, "migrations": passing}},
+		{name: "failing check", checks: map[string]health.Check{"database": failing, "migrations": passing}, failed: []string{"database"}},
+		{name: "slow check", checks: map[string]health.Check{"database": slow}, failed: []string{"database"}},
+		{name: "draining", checks: map[string]health.Check{"database": passing}, draining: true, failed: []string{"draining"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := []health.Option{health.WithTimeout(50 * time.Millisecond)}
+			for name, check := range tt.checks {
+				opts = append(opts, health.WithCheck(name, check))
+			}
+			probe := health.New(opts...)
+			if tt.draining {
+				probe.StartDraining()
+			}
+
+			code, response := serve(t, probe.Ready())
+
+			expectedCode, expectedStatus := http.StatusOK, health.StatusOK
+			if len(tt.failed) > 0 {
+				expectedCode, expectedStatus = http.StatusServiceUnavailable, health.StatusUnavailable
+			}
+			if code != expectedCode || response.Status != expectedStatus {
+				t.Errorf("Expected %d %s, got %d %s", expectedCode, expectedStatus, code, response.Status)
+			}
+			if len(response.Checks) != len(tt.checks)+1 {
+				t.Errorf("Expected every check in the response, got %v", response.Checks)
+			}
+			for _, name := range tt.failed {
+				if result := response.Checks[name]; result.Status != health.StatusUnavailable {
+					t.Errorf("Expected check %s to fail, got %v", name, result)
+				}
+			}
+		})
+	}
+}
+
+func TestReady_HidesErrors(t *testing.T) {
+	var logs bytes.Buffer
+	probe := health.New(
+		health.WithLogger(slog.New(slog.NewTextHandler(&logs, nil))),
+		health.WithCheck("database", func(context.Context) error {
+			return errors.New("dial tcp 10.0.0.5:5432: connection refused")
+		}),
+	)
+
+	rec := httptest.NewRecorder()
+	probe.Ready().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503, got %d", rec.Code)
+	}
+	if bytes.Contains(rec.Body.Bytes(), []byte("10.0.0.5")) {
+		t.Errorf("Expected the error to stay out of the response, got %s", rec.Body.String())
+	}
+	if !bytes.Contains(logs.Bytes(), []byte("check=database")) || !bytes.Contains(logs.Bytes(), []byte("10.0.0.5")) {
+		t.Errorf("Expected the failed check to be logged, got %s", logs.String())
+	}
+}
@@ -0,0 +1,150 @@
+package language
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var tagPattern = regexp.MustCompile(`^[a-zA-Z]{1,8}(-[a-zA-Z0-9]{1,8})*$`)
+
+// Preference is a language range from an Accept-Language header with its quality.
+type Preference struct {
+	Tag     string
+	Quality float64
+}
+
+// IsValidTag reports whether the value is a well-formed language tag such as en or pt-BR.
+func IsValidTag(tag string) bool {
+	return tagPattern.MatchString(tag)
+}
+
+// ParseAcceptLanguage reads the language ranges of an Accept-Language header,
+// lower-cased and sorted by descending quality. Ranges keep their header order
+// on equal quality and malformed entries are skipped. The q parameter may come
+// with others in any order, e.g. en;x=1;q=0.5.
+func ParseAcceptLanguage(header string) []Preference {
+	var preferences []Preference
+
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag != "*" && !IsValidTag(tag) {
+			continue
+		}
+
+		quality, ok := parseQuality(params)
+		if !ok {
+			continue
+		}
+
+		preferences = append(preferences, Preference{Tag: tag, Quality: quality})
+	}
+
+	sort.SliceStable(preferences, func(i, j int) bool {
+		return preferences[i].Quality > preferences[j].Quality
+	})
+
+	return preferences
+}
+
+// parseQuality reads the q parameter among the semicolon separated parameters
+// of a language range, 1 without one. It reports false for malformed ones.
+func parseQuality(params string) (float64, bool) {
+	quality := 1.0
+	if params == "" {
+		return quality, true
+	}
+
+	for _, param := range strings.Split(params, ";") {
+		name, value, found := strings.Cut(strings.TrimSpace(param), "=")
+		if !found {
+			return 0, false
+		}
+		if !strings.EqualFold(strings.TrimSpace(name), "q") {
+			continue
+		}
+
+		parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || parsed < 0 || parsed > 1 {
+			return 0, false
+		}
+		quality = parsed
+	}
+
+	return quality, true
+}
+
+// Match picks the best of the available tags for an Accept-Language header,
+// comparing them case-insensitively. Each preferred range is tried in order:
+// first as is, then with its subtags removed one by one (en-US falls back to
+// en), then as a prefix of a more specific available tag (en matches en-GB).
+// Ranges with quality 0 never match and refuse the tags they cover for the
+// fallbacks of other ranges, so en-US, en;q=0 does not fall back to en or
+// en-GB. The wildcard stands for the default, so once it is reached no less
+// preferred range is tried and the caller falls back to its default.
+func Match(header string, available []string) (string, bool) {
+	tags := make(map[string]string, len(available))
+	sorted := make([]string, 0, len(available))
+	for _, tag := range available {
+		lower := strings.ToLower(tag)
+		tags[lower] = tag
+		sorted = append(sorted, lower)
+	}
+	sort.Strings(sorted)
+
+	preferences := ParseAcceptLanguage(header)
+	var refused []string
+	for _, preference := range preferences {
+		if preference.Quality == 0 && preference.Tag != "*" {
+			refused = append(refused, preference.Tag)
+		}
+	}
+
+	for _, preference := range preferences {
+		if preference.Quality == 0 {
+			continue
+		}
+		if preference.Tag == "*" {
+			break
+		}
+
+		for candidate := preference.Tag; candidate != ""; candidate = truncate(candidate) {
+			tag, ok := tags[candidate]
+			if ok && (candidate == preference.Tag || !isRefused(candidate, refused)) {
+				return tag, true
+			}
+		}
+
+		for _, candidate := range sorted {
+			if strings.HasPrefix(candidate, preference.Tag+"-") && !isRefused(candidate, refused) {
+				return tags[candidate], true
+			}
+		}
+	}
+
+	return "", false
+}
+
+// isRefused reports whether one of the refused ranges covers the tag, either
+// as the same tag or as one of its prefixes.
+func isRefused(tag string, refused []string) bool {
+	for _, prefix := range refused {
+		if tag == prefix || strings.HasPrefix(tag, prefix+"-") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// truncate removes the last subtag of a language tag.
+func truncate(tag string) string {
+	index := strings.LastIndex(tag, "-")
+	if index < 0 {
+		return ""
+	}
+
+	return tag[:index]
+}
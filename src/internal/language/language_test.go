@@ -0,0 +1,65 @@
+package language_test
+
+import (
+	"testing"
+
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/language"
+)
+
+func TestParseAcceptLanguage(t *testing.T) {
+	preferences := language.ParseAcceptLanguage("fr-CH, fr;q=0.9, en;level=1;q=0.8, de;Q=0.7, *;q=0.5, bad tag, es;q=x, it;q")
+
+	expected := []language.Preference{
+		{Tag: "fr-ch", Quality: 1},
+		{Tag: "fr", Quality: 0.9},
+		{Tag: "en", Quality: 0.8},
+		{Tag: "de", Quality: 0.7},
+		{Tag: "*", Quality: 0.5},
+	}
+
+	if len(preferences) != len(expected) {
+		t.Fatalf("Expected %d preferences, got %+v", len(expected), preferences)
+	}
+	for i := range expected {
+		if preferences[i] != expected[i] {
+			t.Errorf("Expected preference %d to be %+v, got %+v", i, expected[i], preferences[i])
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	available := []string{"en", "de", "pt-BR", "zh-Hant"}
+
+	tests := []struct {
+		name     string
+		header   string
+		expected string
+		found    bool
+	}{
+		{name: "Exact match", header: "de", expected: "de", found: true},
+		{name: "Quality order wins over header order", header: "fr;q=0.1, de;q=0.5, en;q=0.9", expected: "en", found: true},
+		{name: "Region falls back to language", header: "en-US", expected: "en", found: true},
+		{name: "Language matches region", header: "pt", expected: "pt-BR", found: true},
+		{name: "Case insensitive", header: "ZH-hant-TW", expected: "zh-Hant", found: true},
+		{name: "Skips unavailable languages", header: "fr, ja;q=0.9, de;q=0.1", expected: "de", found: true},
+		{name: "Quality zero never matches", header: "de;q=0", found: false},
+		{name: "Quality zero refuses the fallback", header: "en-US;q=1, en;q=0", found: false},
+		{name: "Quality zero refuses prefix matches", header: "pt-PT, pt-BR;q=0", found: false},
+		{name: "Quality zero refuses more specific tags", header: "zh-Hant-TW, zh;q=0, de;q=0.5", expected: "de", found: true},
+		{name: "Explicit range beats a refused prefix", header: "zh;q=0, zh-Hant", expected: "zh-Hant", found: true},
+		{name: "Wildcard falls back to default", header: "*", found: false},
+		{name: "Wildcard beats less preferred ranges", header: "fr, *;q=0.9, de;q=0.1", found: false},
+		{name: "More preferred range beats wildcard", header: "*;q=0.5, de", expected: "de", found: true},
+		{name: "Quality after other parameters", header: "de;level=1;q=0.1, en;q=0.5", expected: "en", found: true},
+		{name: "No header", header: "", found: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, found := language.Match(tt.header, available)
+			if found != tt.found || result != tt.expected {
+				t.Errorf("Expected (%q, %v), got (%q, %v)", tt.expected, tt.found, result, found)
+			}
+		})
+	}
+}
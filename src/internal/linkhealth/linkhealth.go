@@ -0,0 +1,339 @@
+package linkhealth
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/db"
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/logging"
+)
+
+// Constants for default checker settings.
+const (
+	DefaultInterval    = 5 * time.Minute
+	DefaultTimeout     = 10 * time.Second
+	DefaultConcurrency = 4
+	DefaultHostDelay   = time.Second
+	DefaultBatchSize   = 100
+	DefaultUserAgent   = "url-shortener-link-checker/1.0"
+
+	// maxBodyRead bounds how much of a GET response is read before the
+	// connection is released.
+	maxBodyRead = 4 << 10
+)
+
+// Error classes stored with a failed check. The raw error is only logged, as
+// the checks are shown by the public preview API.
+const (
+	ErrorBlocked    = "blocked_address"
+	ErrorDNS        = "dns_failure"
+	ErrorTimeout    = "timeout"
+	ErrorTLS        = "tls_failure"
+	ErrorConnection = "connection_failure"
+	ErrorRequest    = "request_failure"
+)
+
+// ErrBlockedAddress is returned when a destination, or a redirect it sends
+// the checker to, resolves to an address that is not public.
+var ErrBlockedAddress = errors.New("destination address is not public")
+
+// sharedAddressSpace is the carrier-grade NAT range, which netip does not
+// count as private.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// Store is the part of the database the checker uses.
+type Store interface {
+	GetLinksToCheck(ctx context.Context, checkedBefore time.Time, limit int) ([]db.URLMap, error)
+	RecordLinkCheck(ctx context.Context, check db.LinkCheck) error
+}
+
+// Checker periodically probes the destinations of links and records whether
+// they are reachable.
+type Checker struct {
+	store       Store
+	client      *http.Client
+	now         func() time.Time
+	logger      *slog.Logger
+	userAgent   string
+	interval    time.Duration
+	hostDelay   time.Duration
+	concurrency int
+	batchSize   int
+}
+
+// Option type for functional options.
+type Option func(*Checker)
+
+// WithInterval sets how often a destination is checked.
+func WithInterval(interval time.Duration) Option {
+	return func(c *Checker) {
+		c.interval = interval
+	}
+}
+
+// WithConcurrency sets how many hosts are probed at the same time.
+func WithConcurrency(concurrency int) Option {
+	return func(c *Checker) {
+		c.concurrency = concurrency
+	}
+}
+
+// WithHostDelay sets the pause between two probes of the same host.
+func WithHostDelay(delay time.Duration) Option {
+	return func(c *Checker) {
+		c.hostDelay = delay
+	}
+}
+
+// WithBatchSize sets how many links are checked per round.
+func WithBatchSize(size int) Option {
+	return func(c *Checker) {
+		c.batchSize = size
+	}
+}
+
+// WithHTTPClient sets the client used to probe destinations. It replaces the
+// default client along with its guard against non-public addresses.
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *Checker) {
+		c.client = client
+	}
+}
+
+// WithClock sets the function returning the current time.
+func WithClock(now func() time.Time) Option {
+	return func(c *Checker) {
+		c.now = now
+	}
+}
+
+// WithLogger sets the logger of the checker.
+func WithLogger(logger *slog.Logger) Option {
+	return func(c *Checker) {
+		c.logger = logger
+	}
+}
+
+// New creates a new Checker instance.
+func New(store Store, opts ...Option) *Checker {
+	c := &Checker{
+		store:       store,
+		client:      newClient(),
+		now:         time.Now,
+		logger:      slog.Default(),
+		userAgent:   DefaultUserAgent,
+		interval:    DefaultInterval,
+		hostDelay:   DefaultHostDelay,
+		concurrency: DefaultConcurrency,
+		batchSize:   DefaultBatchSize,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.concurrency = max(c.concurrency, 1)
+
+	return c
+}
+
+// Run checks links until the context is cancelled. Each round picks the links
+// that were not checked for an interval, so a large number of links is
+// spread over several rounds.
+func (c *Checker) Run(ctx context.Context) {
+	ticker := time.NewTicker(max(c.interval/10, time.Second))
+	defer ticker.Stop()
+
+	for {
+		if _, err := c.CheckDue(ctx); err != nil {
+			c.logger.ErrorContext(ctx, "Link health check failed", logging.Error(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// CheckDue probes one batch of links that are due and returns the number of
+// links checked.
+func (c *Checker) CheckDue(ctx context.Context) (int, error) {
+	urlMaps, err := c.store.GetLinksToCheck(ctx, c.now().Add(-c.interval), c.batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	// Links are grouped by host so that each host is probed by one worker at a
+	// time, with a pause between its requests.
+	byHost := make(map[string][]db.URLMap)
+	for _, urlMap := range urlMaps {
+		host := hostOf(urlMap.OriginalURL)
+		byHost[host] = append(byHost[host], urlMap)
+	}
+
+	sem := make(chan struct{}, c.concurrency)
+	var wg sync.WaitGroup
+	for _, links := range byHost {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			c.checkHost(ctx, links)
+		}()
+	}
+	wg.Wait()
+
+	return len(urlMaps), ctx.Err()
+}
+
+// checkHost probes the links of a single host one after another.
+func (c *Checker) checkHost(ctx context.Context, links []db.URLMap) {
+	for i, urlMap := range links {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(c.hostDelay):
+			}
+		}
+
+		check := c.Probe(ctx, urlMap.OriginalURL)
+		if ctx.Err() != nil {
+			// A probe cut short by shutdown says nothing about the destination.
+			return
+		}
+
+		check.ShortURL = urlMap.ShortURL
+		if err := c.store.RecordLinkCheck(ctx, check); err != nil {
+			c.logger.ErrorContext(ctx, "Failed to record link check", "short_url", urlMap.ShortURL, logging.Error(err))
+		}
+	}
+}
+
+// Probe requests the destination with HEAD, falling back to GET for servers
+// that do not support HEAD. Responses below 400 count as reachable.
+func (c *Checker) Probe(ctx context.Context, destination string) db.LinkCheck {
+	check := db.LinkCheck{CheckedAt: c.now()}
+
+	statusCode, err := c.request(ctx, http.MethodHead, destination)
+	if err != nil || statusCode == http.StatusMethodNotAllowed || statusCode == http.StatusNotImplemented {
+		statusCode, err = c.request(ctx, http.MethodGet, destination)
+	}
+
+	if err != nil {
+		check.Error = errorClass(err)
+		c.logger.DebugContext(ctx, "Destination check failed", logging.URL("destination", destination), logging.Error(err))
+
+		return check
+	}
+
+	check.StatusCode = statusCode
+	check.OK = statusCode < http.StatusBadRequest
+
+	return check
+}
+
+func (c *Checker) request(ctx context.Context, method, destination string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, method, destination, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("User-Agent", c.userAgent)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if _, err = io.CopyN(io.Discard, resp.Body, maxBodyRead); err != nil && !errors.Is(err, io.EOF) {
+		c.logger.DebugContext(ctx, "Failed to read response", logging.URL("destination", destination), logging.Error(err))
+	}
+
+	return resp.StatusCode, nil
+}
+
+// newClient creates the client probing destinations. Every connection it
+// opens, including those of followed redirects, is refused unless the
+// address is public, so links cannot be used to scan internal networks.
+func newClient() *http.Client {
+	dialer := &net.Dialer{Timeout: DefaultTimeout, Control: guardAddress}
+
+	return &http.Client{
+		Timeout: DefaultTimeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: DefaultTimeout,
+			MaxIdleConnsPerHost: 1,
+		},
+	}
+}
+
+// guardAddress is the dialer Control hook, it runs with the resolved address
+// right before each connection is made.
+func guardAddress(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return ErrBlockedAddress
+	}
+
+	ip, err := netip.ParseAddr(host)
+	if err != nil || !isPublic(ip.Unmap()) {
+		return ErrBlockedAddress
+	}
+
+	return nil
+}
+
+// isPublic reports whether the address is reachable on the internet, which
+// rules out private, loopback, link-local and unspecified addresses.
+func isPublic(ip netip.Addr) bool {
+	return ip.IsGlobalUnicast() && !ip.IsPrivate() && !sharedAddressSpace.Contains(ip)
+}
+
+// errorClass reduces a failed request to one of the error classes.
+func errorClass(err error) string {
+	var dnsErr *net.DNSError
+	var netErr net.Error
+	var certErr *tls.CertificateVerificationError
+	var recordErr tls.RecordHeaderError
+	var opErr *net.OpError
+
+	switch {
+	case errors.Is(err, ErrBlockedAddress):
+		return ErrorBlocked
+	case errors.As(err, &dnsErr):
+		return ErrorDNS
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return ErrorTimeout
+	case errors.As(err, &certErr), errors.As(err, &recordErr):
+		return ErrorTLS
+	case errors.As(err, &opErr):
+		return ErrorConnection
+	default:
+		return ErrorRequest
+	}
+}
+
+func hostOf(destination string) string {
+	parsedURL, err := url.Parse(destination)
+	if err != nil {
+		return destination
+	}
+
+	return strings.ToLower(parsedURL.Host)
+}
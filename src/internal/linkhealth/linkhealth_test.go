@@ -0,0 +1,159 @@
+package linkhealth_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/db"
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/linkhealth"
+)
+
+// MockStore records the checks of the links it hands out.
+type MockStore struct {
+	links  []db.URLMap
+	checks []db.LinkCheck
+	lock   sync.Mutex
+}
+
+func (m *MockStore) GetLinksToCheck(_ context.Context, _ time.Time, limit int) ([]db.URLMap, error) {
+	return m.links[:min(limit, len(m.links))], nil
+}
+
+func (m *MockStore) RecordLinkCheck(_ context.Context, check db.LinkCheck) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.checks = append(m.checks, check)
+
+	return nil
+}
+
+func newTarget(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ok", func(wr http.ResponseWriter, _ *http.Request) {
+		wr.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc("/down", func(wr http.ResponseWriter, _ *http.Request) {
+		wr.WriteHeader(http.StatusInternalServerError)
+	})
+	mux.HandleFunc("/no-head", func(wr http.ResponseWriter, req *http.Request) {
+		if req.Method == http.MethodHead {
+			wr.WriteHeader(http.StatusMethodNotAllowed)
+
+			return
+		}
+		wr.WriteHeader(http.StatusOK)
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestProbe(t *testing.T) {
+	target := newTarget(t)
+	checker := linkhealth.New(&MockStore{}, linkhealth.WithHTTPClient(target.Client()))
+
+	tests := []struct {
+		name       string
+		path       string
+		statusCode int
+		ok         bool
+	}{
+		{name: "Reachable", path: "/ok", statusCode: http.StatusOK, ok: true},
+		{name: "Server error", path: "/down", statusCode: http.StatusInternalServerError, ok: false},
+		{name: "Not found", path: "/missing", statusCode: http.StatusNotFound, ok: false},
+		{name: "HEAD not allowed", path: "/no-head", statusCode: http.StatusOK, ok: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			check := checker.Probe(context.Background(), target.URL+tt.path)
+			if check.StatusCode != tt.statusCode || check.OK != tt.ok {
+				t.Errorf("Expected status %d and ok %v, got %+v", tt.statusCode, tt.ok, check)
+			}
+		})
+	}
+}
+
+func TestProbe_Unreachable(t *testing.T) {
+	target := newTarget(t)
+	address := target.URL
+	target.Close()
+
+	check := linkhealth.New(&MockStore{}, linkhealth.WithHTTPClient(&http.Client{})).Probe(context.Background(), address+"/ok")
+	if check.OK || check.Error != linkhealth.ErrorConnection {
+		t.Errorf("Expected a failed check with a connection error, got %+v", check)
+	}
+}
+
+func TestProbe_BlockedAddress(t *testing.T) {
+	target := newTarget(t)
+	checker := linkhealth.New(&MockStore{})
+
+	for _, destination := range []string{
+		target.URL + "/ok",
+		"http://[::1]:9/",
+		"http://169.254.169.254/latest/meta-data/",
+		"http://10.0.0.5:5432/",
+	} {
+		check := checker.Probe(context.Background(), destination)
+		if check.OK || check.StatusCode != 0 || check.Error != linkhealth.ErrorBlocked {
+			t.Errorf("Expected %s to be blocked, got %+v", destination, check)
+		}
+	}
+}
+
+func TestCheckDue(t *testing.T) {
+	target := newTarget(t)
+
+	var times []time.Time
+	var lock sync.Mutex
+	politeTarget := httptest.NewServer(http.HandlerFunc(func(wr http.ResponseWriter, _ *http.Request) {
+		lock.Lock()
+		times = append(times, time.Now())
+		lock.Unlock()
+		wr.WriteHeader(http.StatusOK)
+	}))
+	defer politeTarget.Close()
+
+	store := &MockStore{links: []db.URLMap{
+		{ShortURL: "up1", OriginalURL: target.URL + "/ok"},
+		{ShortURL: "down1", OriginalURL: target.URL + "/down"},
+		{ShortURL: "polite1", OriginalURL: politeTarget.URL + "/a"},
+		{ShortURL: "polite2", OriginalURL: politeTarget.URL + "/b"},
+	}}
+	const delay = 50 * time.Millisecond
+	checker := linkhealth.New(store,
+		linkhealth.WithHostDelay(delay),
+		linkhealth.WithConcurrency(2),
+		linkhealth.WithHTTPClient(&http.Client{}),
+	)
+
+	checked, err := checker.CheckDue(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if checked != 4 || len(store.checks) != 4 {
+		t.Fatalf("Expected 4 links to be checked, got %d with %d results", checked, len(store.checks))
+	}
+
+	results := make(map[string]bool)
+	for _, check := range store.checks {
+		results[check.ShortURL] = check.OK
+	}
+	if !results["up1"] || results["down1"] || !results["polite1"] || !results["polite2"] {
+		t.Errorf("Unexpected check results %v", results)
+	}
+
+	if len(times) != 2 || times[1].Sub(times[0]) < delay {
+		t.Errorf("Expected requests to the same host at least %v apart, got %v", delay, times)
+	}
+}
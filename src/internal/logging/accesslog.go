@@ -0,0 +1,94 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/response"
+)
+
+// Formats of the access log.
+const (
+	AccessLogJSON     = "json"
+	AccessLogCombined = "combined"
+	AccessLogOff      = "off"
+)
+
+// combinedTimeFormat is the time format of the Combined Log Format.
+const combinedTimeFormat = "02/Jan/2006:15:04:05 -0700"
+
+// AccessLog writes a line to out for every request served by next, as JSON
+// or in the Combined Log Format followed by the duration in microseconds and
+// the request ID. It must run inside Middleware to log the request ID. Query
+// strings are left out and referers are redacted like logged URLs.
+func AccessLog(out io.Writer, format string, next http.Handler) (http.Handler, error) {
+	var logger *slog.Logger
+	switch format {
+	case AccessLogOff:
+		return next, nil
+	case "", AccessLogJSON:
+		logger = slog.New(slog.NewJSONHandler(out, nil))
+	case AccessLogCombined:
+	default:
+		return nil, fmt.Errorf("unknown access log format %q, expected json, combined or off", format)
+	}
+
+	return http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		recorder := response.NewRecorder(wr)
+		next.ServeHTTP(recorder, req)
+		duration := time.Since(start)
+
+		if logger == nil {
+			writeCombined(out, req, recorder, start, duration)
+
+			return
+		}
+
+		logger.LogAttrs(req.Context(), slog.LevelInfo, "request",
+			slog.String("request_id", RequestID(req.Context())),
+			slog.String("method", req.Method),
+			slog.String("path", req.URL.Path),
+			slog.String("proto", req.Proto),
+			slog.Int("status", recorder.Status()),
+			slog.Int64("bytes", recorder.Bytes()),
+			slog.Float64("duration_ms", float64(duration.Microseconds())/1000),
+			slog.String("client_ip", clientIP(req)),
+			slog.String("referer", redactReferer(req.Referer())),
+			slog.String("user_agent", req.UserAgent()),
+		)
+	}), nil
+}
+
+func writeCombined(out io.Writer, req *http.Request, recorder *response.Recorder, start time.Time, duration time.Duration) {
+	bytes := "-"
+	if recorder.Bytes() > 0 {
+		bytes = strconv.FormatInt(recorder.Bytes(), 10)
+	}
+
+	// A single write per line keeps concurrent requests from interleaving.
+	line := fmt.Sprintf("%s - - [%s] %s %d %s %s %s %d %s\n",
+		clientIP(req),
+		start.Format(combinedTimeFormat),
+		strconv.Quote(req.Method+" "+req.URL.Path+" "+req.Proto),
+		recorder.Status(),
+		bytes,
+		strconv.Quote(redactReferer(req.Referer())),
+		strconv.Quote(req.UserAgent()),
+		duration.Microseconds(),
+		strconv.Quote(RequestID(req.Context())),
+	)
+	_, _ = io.WriteString(out, line)
+}
+
+func redactReferer(referer string) string {
+	if referer == "" {
+		return "-"
+	}
+
+	return RedactURL(referer)
+}
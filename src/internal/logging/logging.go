@@ -0,0 +1,183 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+)
+
+// Output formats of the logger.
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// New creates a logger writing in the given format at or above the level.
+// Attributes added to a context with WithAttrs are included in every record
+// logged with that context.
+func New(w io.Writer, format string, level slog.Level) (*slog.Logger, error) {
+	opts := &slog.HandlerOptions{Level: level}
+
+	var handler slog.Handler
+	switch format {
+	case "", FormatText:
+		handler = slog.NewTextHandler(w, opts)
+	case FormatJSON:
+		handler = slog.NewJSONHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("unknown log format %q, expected json or text", format)
+	}
+
+	return slog.New(contextHandler{Handler: handler}), nil
+}
+
+// ParseLevel reads a level such as debug, info, warn or error.
+func ParseLevel(level string) (slog.Level, error) {
+	var parsed slog.Level
+	if level == "" {
+		return slog.LevelInfo, nil
+	}
+	if err := parsed.UnmarshalText([]byte(level)); err != nil {
+		return slog.LevelInfo, fmt.Errorf("unknown log level %q, expected debug, info, warn or error", level)
+	}
+
+	return parsed, nil
+}
+
+type attrsKey struct{}
+
+// WithAttrs returns a context whose log records carry the attributes.
+func WithAttrs(ctx context.Context, attrs ...slog.Attr) context.Context {
+	existing, _ := ctx.Value(attrsKey{}).([]slog.Attr)
+
+	return context.WithValue(ctx, attrsKey{}, append(append([]slog.Attr(nil), existing...), attrs...))
+}
+
+// contextHandler adds the attributes stored in the context to each record.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if attrs, ok := ctx.Value(attrsKey{}).([]slog.Attr); ok {
+		record.AddAttrs(attrs...)
+	}
+
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{Handler: h.Handler.WithGroup(name)}
+}
+
+// RequestIDHeader carries the ID of a request from the client or proxy and
+// back in the response.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds the length of a propagated request ID.
+const maxRequestIDLength = 128
+
+type requestIDKey struct{}
+
+// RequestID returns the ID of the request the context belongs to.
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+
+	return requestID
+}
+
+// Middleware assigns every request an ID, taken from the X-Request-ID header
+// when it is valid, and returns it in the response. The ID, method, path and
+// client address are added to the records logged with the request context.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
+		requestID := req.Header.Get(RequestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = newRequestID()
+		}
+		wr.Header().Set(RequestIDHeader, requestID)
+
+		ctx := context.WithValue(req.Context(), requestIDKey{}, requestID)
+		ctx = WithAttrs(ctx,
+			slog.String("request_id", requestID),
+			slog.String("method", req.Method),
+			slog.String("path", req.URL.Path),
+			slog.String("client_ip", clientIP(req)),
+		)
+		next.ServeHTTP(wr, req.WithContext(ctx))
+	})
+}
+
+// validRequestID accepts IDs made of letters, digits and -._: only, so a
+// client cannot inject anything into the logs.
+func validRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range requestID {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '.', r == '_', r == ':':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+func newRequestID() string {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		panic(err)
+	}
+
+	return hex.EncodeToString(id)
+}
+
+// Error returns the attribute for an error.
+func Error(err error) slog.Attr {
+	return slog.Any("error", err)
+}
+
+// URL returns the attribute for a URL with everything that may carry private
+// data removed, see RedactURL.
+func URL(key, rawURL string) slog.Attr {
+	return slog.String(key, RedactURL(rawURL))
+}
+
+// RedactURL keeps the scheme and host of a URL and drops its credentials,
+// path, query and fragment, which often contain tokens or personal data.
+func RedactURL(rawURL string) string {
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil || parsedURL.Host == "" {
+		return "[redacted]"
+	}
+
+	redacted := parsedURL.Scheme + "://" + parsedURL.Host
+	if (parsedURL.Path != "" && parsedURL.Path != "/") || parsedURL.RawQuery != "" || parsedURL.Fragment != "" {
+		redacted += "/[redacted]"
+	}
+
+	return redacted
+}
+
+// clientIP returns the address the request came from, the client behind
+// trusted proxies once the forwarded middleware ran.
+func clientIP(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+
+	return host
+}
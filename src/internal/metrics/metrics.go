@@ -0,0 +1,176 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/db"
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/response"
+)
+
+// Outcomes of a redirect request.
+const (
+	RedirectHit      = "hit"
+	RedirectFallback = "fallback"
+	RedirectMiss     = "miss"
+	RedirectExpired  = "expired"
+	RedirectLocked   = "locked"
+	RedirectError    = "error"
+)
+
+// Outcomes of a shorten request.
+const (
+	ShortenCreated  = "created"
+	ShortenInvalid  = "invalid"
+	ShortenConflict = "conflict"
+	ShortenError    = "error"
+)
+
+// Results of a cache lookup.
+const (
+	CacheHit  = "hit"
+	CacheMiss = "miss"
+)
+
+// methods lists the request methods counted under their own name. Clients can
+// send any method token, so all others are counted as "other" to keep the
+// number of series bounded.
+var methods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodConnect: true,
+	http.MethodOptions: true,
+	http.MethodTrace:   true,
+}
+
+// Metrics holds the application metrics. A nil *Metrics records nothing, so
+// callers do not need to check whether metrics are enabled.
+type Metrics struct {
+	registry   *Registry
+	requests   *CounterVec
+	durations  *HistogramVec
+	redirects  *CounterVec
+	shortens   *CounterVec
+	collisions *CounterVec
+	domains    *CounterVec
+}
+
+// New creates a new Metrics instance.
+func New() *Metrics {
+	registry := NewRegistry()
+
+	return &Metrics{
+		registry: registry,
+		requests: registry.NewCounterVec("urlshortener_http_requests_total",
+			"Number of HTTP requests by route, method and status code.", "route", "method", "code"),
+		durations: registry.NewHistogramVec("urlshortener_http_request_duration_seconds",
+			"Latency of HTTP requests by route.", DefaultBuckets, "route"),
+		redirects: registry.NewCounterVec("urlshortener_redirects_total",
+			"Number of redirect requests by outcome.", "outcome"),
+		shortens: registry.NewCounterVec("urlshortener_shorten_total",
+			"Number of shorten requests by outcome.", "outcome"),
+		collisions: registry.NewCounterVec("urlshortener_short_url_collisions_total",
+			"Number of generated short URLs that already existed and were retried."),
+		domains: registry.NewCounterVec("urlshortener_domain_cache_lookups_total",
+			"Number of custom domain lookups by cache result.", "result"),
+	}
+}
+
+// Handler serves the metrics in the Prometheus text exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return http.HandlerFunc(func(wr http.ResponseWriter, _ *http.Request) {
+		wr.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		m.registry.Write(wr)
+	})
+}
+
+// Instrument counts the requests of a route and measures their latency.
+func (m *Metrics) Instrument(route string, next http.Handler) http.Handler {
+	if m == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		recorder := response.NewRecorder(wr)
+
+		next.ServeHTTP(recorder, req)
+
+		m.requests.Inc(route, methodLabel(req.Method), strconv.Itoa(recorder.Status()))
+		m.durations.Observe(time.Since(start).Seconds(), route)
+	})
+}
+
+// methodLabel returns the label value of a request method.
+func methodLabel(method string) string {
+	if methods[method] {
+		return method
+	}
+
+	return "other"
+}
+
+// Redirect counts a redirect request with its outcome.
+func (m *Metrics) Redirect(outcome string) {
+	if m != nil {
+		m.redirects.Inc(outcome)
+	}
+}
+
+// Shorten counts a shorten request with its outcome.
+func (m *Metrics) Shorten(outcome string) {
+	if m != nil {
+		m.shortens.Inc(outcome)
+	}
+}
+
+// CollisionRetry counts a generated short URL that was taken.
+func (m *Metrics) CollisionRetry() {
+	if m != nil {
+		m.collisions.Inc()
+	}
+}
+
+// DomainLookup counts a custom domain lookup with its cache result.
+func (m *Metrics) DomainLookup(result string) {
+	if m != nil {
+		m.domains.Inc(result)
+	}
+}
+
+// RegisterDBStats exposes the connection pool statistics of the database.
+func (m *Metrics) RegisterDBStats(stats func() db.PoolStats) {
+	if m == nil {
+		return
+	}
+
+	m.registry.NewGaugeFunc("urlshortener_db_connections_acquired",
+		"Number of database connections in use.",
+		func() float64 { return float64(stats().AcquiredConns) })
+	m.registry.NewGaugeFunc("urlshortener_db_connections_idle",
+		"Number of idle database connections.",
+		func() float64 { return float64(stats().IdleConns) })
+	m.registry.NewGaugeFunc("urlshortener_db_connections_total",
+		"Number of open database connections.",
+		func() float64 { return float64(stats().TotalConns) })
+	m.registry.NewGaugeFunc("urlshortener_db_connections_max",
+		"Maximum number of database connections.",
+		func() float64 { return float64(stats().MaxConns) })
+	m.registry.NewCounterFunc("urlshortener_db_acquires_total",
+		"Number of database connections acquired from the pool.",
+		func() float64 { return float64(stats().AcquireCount) })
+	m.registry.NewCounterFunc("urlshortener_db_empty_acquires_total",
+		"Number of acquires that waited because the pool had no idle connection.",
+		func() float64 { return float64(stats().EmptyAcquireCount) })
+	m.registry.NewCounterFunc("urlshortener_db_canceled_acquires_total",
+		"Number of acquires cancelled before a connection was available.",
+		func() float64 { return float64(stats().CanceledAcquireCount) })
+	m.registry.NewCounterFunc("urlshortener_db_acquire_duration_seconds_total",
+		"Total time spent acquiring database connections.",
+		func() float64 { return stats().AcquireDuration.Seconds() })
+}
@@ -0,0 +1,98 @@
+package metrics_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/db"
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/metrics"
+)
+
+func TestRegistry_Write(t *testing.T) {
+	registry := metrics.NewRegistry()
+	counter := registry.NewCounterVec("test_requests_total", "Requests.", "route")
+	counter.Inc("/a")
+	counter.Add(2, `/b"\`)
+	histogram := registry.NewHistogramVec("test_duration_seconds", "Latency.", []float64{0.1, 1}, "route")
+	histogram.Observe(0.05, "/a")
+	histogram.Observe(0.5, "/a")
+	registry.NewGaugeFunc("test_connections", "Connections.", func() float64 { return 3 })
+
+	var buf bytes.Buffer
+	registry.Write(&buf)
+
+	expected := `# HELP test_connections Connections.
+# TYPE test_connections gauge
+test_connections 3
+# HELP test_duration_seconds Latency.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{route="/a",le="0.1"} 1
+test_duration_seconds_bucket{route="/a",le="1"} 2
+test_duration_seconds_bucket{route="/a",le="+Inf"} 2
+test_duration_seconds_sum{route="/a"} 0.55
+test_duration_seconds_count{route="/a"} 2
+# HELP test_requests_total Requests.
+# TYPE test_requests_total counter
+test_requests_total{route="/a"} 1
+test_requests_total{route="/b\"\\"} 2
+`
+	if buf.String() != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, buf.String())
+	}
+}
+
+func TestMetrics_Instrument(t *testing.T) {
+	m := metrics.New()
+	m.RegisterDBStats(func() db.PoolStats { return db.PoolStats{TotalConns: 4} })
+	handler := m.Instrument("/shorten", http.HandlerFunc(func(wr http.ResponseWriter, _ *http.Request) {
+		wr.WriteHeader(http.StatusBadRequest)
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/shorten", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("FOO1", "/shorten", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("FOO2", "/shorten", nil))
+	m.Redirect(metrics.RedirectMiss)
+	m.Shorten(metrics.ShortenCreated)
+	m.CollisionRetry()
+	m.DomainLookup(metrics.CacheHit)
+	m.DomainLookup(metrics.CacheHit)
+	m.DomainLookup(metrics.CacheMiss)
+
+	recorder := httptest.NewRecorder()
+	m.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := recorder.Body.String()
+
+	for _, line := range []string{
+		`urlshortener_http_requests_total{route="/shorten",method="POST",code="400"} 1`,
+		`urlshortener_http_requests_total{route="/shorten",method="other",code="400"} 2`,
+		`urlshortener_http_request_duration_seconds_count{route="/shorten"} 3`,
+		`urlshortener_redirects_total{outcome="miss"} 1`,
+		`urlshortener_shorten_total{outcome="created"} 1`,
+		`urlshortener_short_url_collisions_total 1`,
+		`urlshortener_domain_cache_lookups_total{result="hit"} 2`,
+		`urlshortener_domain_cache_lookups_total{result="miss"} 1`,
+		`urlshortener_db_connections_total 4`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("Expected metrics to contain %q, got:\n%s", line, body)
+		}
+	}
+	if !strings.HasPrefix(recorder.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Errorf("Expected the text exposition content type, got %s", recorder.Header().Get("Content-Type"))
+	}
+}
+
+func TestMetrics_Nil(t *testing.T) {
+	var m *metrics.Metrics
+	m.Redirect(metrics.RedirectHit)
+	m.Shorten(metrics.ShortenCreated)
+	m.CollisionRetry()
+	m.DomainLookup(metrics.CacheMiss)
+
+	handler := http.NotFoundHandler()
+	if m.Instrument("/", handler) == nil {
+		t.Error("Expected the handler to be returned unchanged")
+	}
+}
@@ -0,0 +1,240 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// collector writes its samples in the Prometheus text exposition format.
+type collector interface {
+	name() string
+	write(w io.Writer)
+}
+
+// Registry holds the collectors exposed on the metrics endpoint.
+type Registry struct {
+	collectors []collector
+	lock       sync.Mutex
+}
+
+// NewRegistry creates a new Registry instance.
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (r *Registry) register(c collector) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.collectors = append(r.collectors, c)
+}
+
+// Write writes every collector in the text exposition format, ordered by name.
+func (r *Registry) Write(w io.Writer) {
+	r.lock.Lock()
+	collectors := append([]collector(nil), r.collectors...)
+	r.lock.Unlock()
+
+	sort.Slice(collectors, func(i, j int) bool { return collectors[i].name() < collectors[j].name() })
+	for _, c := range collectors {
+		c.write(w)
+	}
+}
+
+// CounterVec is a counter partitioned by label values.
+type CounterVec struct {
+	values map[string]float64
+	help   string
+	metric string
+	labels []string
+	lock   sync.Mutex
+}
+
+// NewCounterVec creates a counter and registers it.
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{values: make(map[string]float64), help: help, metric: name, labels: labels}
+	r.register(c)
+
+	return c
+}
+
+// Inc adds one to the counter with the given label values.
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds the value to the counter with the given label values.
+func (c *CounterVec) Add(value float64, labelValues ...string) {
+	key := formatLabels(c.labels, labelValues)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.values[key] += value
+}
+
+func (c *CounterVec) name() string {
+	return c.metric
+}
+
+func (c *CounterVec) write(w io.Writer) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	writeHeader(w, c.metric, c.help, "counter")
+	if len(c.labels) == 0 && len(c.values) == 0 {
+		fmt.Fprintf(w, "%s 0\n", c.metric)
+	}
+	for _, key := range sortedKeys(c.values) {
+		fmt.Fprintf(w, "%s%s %s\n", c.metric, key, formatValue(c.values[key]))
+	}
+}
+
+// DefaultBuckets are the upper bounds in seconds of latency histograms.
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// HistogramVec counts observations into buckets, partitioned by label values.
+type HistogramVec struct {
+	series  map[string]*histogram
+	help    string
+	metric  string
+	labels  []string
+	buckets []float64
+	lock    sync.Mutex
+}
+
+type histogram struct {
+	labelValues []string
+	counts      []uint64
+	sum         float64
+	count       uint64
+}
+
+// NewHistogramVec creates a histogram and registers it.
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	h := &HistogramVec{series: make(map[string]*histogram), help: help, metric: name, labels: labels, buckets: buckets}
+	r.register(h)
+
+	return h
+}
+
+// Observe records the value in the histogram with the given label values.
+func (h *HistogramVec) Observe(value float64, labelValues ...string) {
+	key := formatLabels(h.labels, labelValues)
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	series, ok := h.series[key]
+	if !ok {
+		series = &histogram{labelValues: labelValues, counts: make([]uint64, len(h.buckets))}
+		h.series[key] = series
+	}
+	for i, bound := range h.buckets {
+		if value <= bound {
+			series.counts[i]++
+		}
+	}
+	series.sum += value
+	series.count++
+}
+
+func (h *HistogramVec) name() string {
+	return h.metric
+}
+
+func (h *HistogramVec) write(w io.Writer) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	writeHeader(w, h.metric, h.help, "histogram")
+	for _, key := range sortedKeys(h.series) {
+		series := h.series[key]
+		bucketLabels := append(append([]string(nil), h.labels...), "le")
+		for i, bound := range h.buckets {
+			labelValues := append(append([]string(nil), series.labelValues...), formatValue(bound))
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.metric, formatLabels(bucketLabels, labelValues), series.counts[i])
+		}
+		labelValues := append(append([]string(nil), series.labelValues...), "+Inf")
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.metric, formatLabels(bucketLabels, labelValues), series.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.metric, key, formatValue(series.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.metric, key, series.count)
+	}
+}
+
+// valueFunc reads its value when the metrics are scraped.
+type valueFunc struct {
+	fn         func() float64
+	help       string
+	metric     string
+	metricType string
+}
+
+// NewGaugeFunc registers a gauge whose value is read on every scrape.
+func (r *Registry) NewGaugeFunc(name, help string, fn func() float64) {
+	r.register(&valueFunc{fn: fn, help: help, metric: name, metricType: "gauge"})
+}
+
+// NewCounterFunc registers a counter whose value is read on every scrape.
+func (r *Registry) NewCounterFunc(name, help string, fn func() float64) {
+	r.register(&valueFunc{fn: fn, help: help, metric: name, metricType: "counter"})
+}
+
+func (v *valueFunc) name() string {
+	return v.metric
+}
+
+func (v *valueFunc) write(w io.Writer) {
+	writeHeader(w, v.metric, v.help, v.metricType)
+	fmt.Fprintf(w, "%s %s\n", v.metric, formatValue(v.fn()))
+}
+
+func writeHeader(w io.Writer, name, help, metricType string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, metricType)
+}
+
+// formatLabels renders label pairs as {a="1",b="2"}, escaping the values.
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+
+	escaper := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		var value string
+		if i < len(values) {
+			value = values[i]
+		}
+		pairs[i] = name + `="` + escaper.Replace(value) + `"`
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	default:
+		return strconv.FormatFloat(value, 'g', -1, 64)
+	}
+}
+
+func sortedKeys[V any](values map[string]V) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,234 @@
+package qr
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/skip2/go-qrcode"
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/urlshortenererror"
+)
+
+// Constants for QR code rendering limits and defaults.
+const (
+	DefaultSize   = 256
+	MinSize       = 64
+	MaxSize       = 2048
+	DefaultMargin = 4
+	MaxMargin     = 16
+
+	FormatPNG = "png"
+	FormatSVG = "svg"
+)
+
+// Options holds the rendering options of a QR code.
+type Options struct {
+	Foreground color.RGBA
+	Background color.RGBA
+	Format     string
+	Size       int
+	Margin     int
+	Level      qrcode.RecoveryLevel
+}
+
+// DefaultOptions returns the options used when the request sets none.
+func DefaultOptions() Options {
+	return Options{
+		Foreground: color.RGBA{A: 0xff},
+		Background: color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff},
+		Format:     FormatPNG,
+		Size:       DefaultSize,
+		Margin:     DefaultMargin,
+		Level:      qrcode.Medium,
+	}
+}
+
+// ParseOptions reads the rendering options from query parameters.
+// Supported keys are format, size, margin, ec (L, M, Q or H), fg and bg.
+func ParseOptions(query url.Values) (Options, error) {
+	opts := DefaultOptions()
+
+	if format := strings.ToLower(query.Get("format")); format != "" {
+		if format != FormatPNG && format != FormatSVG {
+			return opts, invalidOption("format must be png or svg")
+		}
+		opts.Format = format
+	}
+
+	if size := query.Get("size"); size != "" {
+		value, err := strconv.Atoi(size)
+		if err != nil || value < MinSize || value > MaxSize {
+			return opts, invalidOption(fmt.Sprintf("size must be between %d and %d", MinSize, MaxSize))
+		}
+		opts.Size = value
+	}
+
+	if margin := query.Get("margin"); margin != "" {
+		value, err := strconv.Atoi(margin)
+		if err != nil || value < 0 || value > MaxMargin {
+			return opts, invalidOption(fmt.Sprintf("margin must be between 0 and %d", MaxMargin))
+		}
+		opts.Margin = value
+	}
+
+	if ec := query.Get("ec"); ec != "" {
+		level, err := parseLevel(ec)
+		if err != nil {
+			return opts, err
+		}
+		opts.Level = level
+	}
+
+	if fg := query.Get("fg"); fg != "" {
+		value, err := parseColor(fg)
+		if err != nil {
+			return opts, err
+		}
+		opts.Foreground = value
+	}
+
+	if bg := query.Get("bg"); bg != "" {
+		value, err := parseColor(bg)
+		if err != nil {
+			return opts, err
+		}
+		opts.Background = value
+	}
+
+	return opts, nil
+}
+
+// ContentType returns the MIME type of the rendered image.
+func (o Options) ContentType() string {
+	if o.Format == FormatSVG {
+		return "image/svg+xml"
+	}
+
+	return "image/png"
+}
+
+// Render encodes the content as a QR code in the requested format.
+func Render(content string, opts Options) ([]byte, error) {
+	bitmap, err := encode(content, opts.Level)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Format == FormatSVG {
+		return renderSVG(bitmap, opts), nil
+	}
+
+	return renderPNG(bitmap, opts)
+}
+
+// encode builds the QR symbol without its built-in quiet zone, so the margin
+// can be controlled by the caller.
+func encode(content string, level qrcode.RecoveryLevel) ([][]bool, error) {
+	code, err := qrcode.New(content, level)
+	if err != nil {
+		return nil, urlshortenererror.Wrap(err, "failed to encode QR code", http.StatusBadRequest, urlshortenererror.ErrInvalidInput)
+	}
+	code.DisableBorder = true
+
+	return code.Bitmap(), nil
+}
+
+func renderPNG(bitmap [][]bool, opts Options) ([]byte, error) {
+	modules := len(bitmap) + 2*opts.Margin
+	scale := max(opts.Size/modules, 1)
+	size := max(opts.Size, scale*modules)
+	offset := (size-scale*modules)/2 + opts.Margin*scale
+
+	img := image.NewPaletted(image.Rect(0, 0, size, size), color.Palette{opts.Background, opts.Foreground})
+	for y, row := range bitmap {
+		for x, dark := range row {
+			if !dark {
+				continue
+			}
+			for dy := range scale {
+				for dx := range scale {
+					img.SetColorIndex(offset+x*scale+dx, offset+y*scale+dy, 1)
+				}
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, urlshortenererror.Wrap(err, "failed to encode PNG", http.StatusInternalServerError, urlshortenererror.ErrServerError)
+	}
+
+	return buf.Bytes(), nil
+}
+
+func renderSVG(bitmap [][]bool, opts Options) []byte {
+	modules := len(bitmap) + 2*opts.Margin
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf,
+		`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
+		opts.Size, opts.Size, modules, modules)
+	fmt.Fprintf(&buf, `<rect width="%d" height="%d" fill="%s"/>`, modules, modules, hexColor(opts.Background))
+	fmt.Fprintf(&buf, `<path fill="%s" d="`, hexColor(opts.Foreground))
+
+	// Draw each horizontal run of dark modules as a single rectangle.
+	for y, row := range bitmap {
+		for x := 0; x < len(row); x++ {
+			if !row[x] {
+				continue
+			}
+			start := x
+			for x < len(row) && row[x] {
+				x++
+			}
+			fmt.Fprintf(&buf, "M%d %dh%dv1h-%dz", start+opts.Margin, y+opts.Margin, x-start, x-start)
+		}
+	}
+	buf.WriteString(`"/></svg>`)
+
+	return buf.Bytes()
+}
+
+func parseLevel(level string) (qrcode.RecoveryLevel, error) {
+	switch strings.ToUpper(level) {
+	case "L":
+		return qrcode.Low, nil
+	case "M":
+		return qrcode.Medium, nil
+	case "Q":
+		return qrcode.High, nil
+	case "H":
+		return qrcode.Highest, nil
+	default:
+		return qrcode.Medium, invalidOption("ec must be one of L, M, Q or H")
+	}
+}
+
+// parseColor accepts RRGGBB or RGB hex colours, with or without a leading #.
+func parseColor(value string) (color.RGBA, error) {
+	hex := strings.TrimPrefix(value, "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+
+	rgb, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil || len(hex) != 6 {
+		return color.RGBA{}, invalidOption("colours must be hex values such as 000000 or #fff")
+	}
+
+	return color.RGBA{R: uint8(rgb >> 16), G: uint8(rgb >> 8), B: uint8(rgb), A: 0xff}, nil
+}
+
+func hexColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+func invalidOption(message string) error {
+	return urlshortenererror.Wrap(nil, "Invalid QR code option: "+message, http.StatusBadRequest, urlshortenererror.ErrInvalidInput)
+}
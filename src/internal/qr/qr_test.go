@@ -0,0 +1,92 @@
+package qr_test
+
+import (
+	"bytes"
+	"errors"
+	"image/png"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/qr"
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/urlshortenererror"
+)
+
+func TestParseOptions_Defaults(t *testing.T) {
+	opts, err := qr.ParseOptions(url.Values{})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if opts != qr.DefaultOptions() {
+		t.Errorf("Expected default options, got %+v", opts)
+	}
+}
+
+func TestParseOptions_Invalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{name: "Unknown format", query: "format=gif"},
+		{name: "Size too small", query: "size=10"},
+		{name: "Size not a number", query: "size=big"},
+		{name: "Negative margin", query: "margin=-1"},
+		{name: "Unknown level", query: "ec=X"},
+		{name: "Bad colour", query: "fg=zzzzzz"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, _ := url.ParseQuery(tt.query)
+			_, err := qr.ParseOptions(query)
+
+			var webErr *urlshortenererror.WebError
+			if !errors.As(err, &webErr) {
+				t.Fatalf("Expected WebError, got %v", err)
+			}
+			if webErr.Code != http.StatusBadRequest {
+				t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, webErr.Code)
+			}
+		})
+	}
+}
+
+func TestRender_PNG(t *testing.T) {
+	query, _ := url.ParseQuery("size=300&margin=2&ec=H&fg=%23112233&bg=fff")
+	opts, err := qr.ParseOptions(query)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	data, err := qr.Render("http://localhost:8000/abc123", opts)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Expected a valid PNG, got %v", err)
+	}
+	if img.Bounds().Dx() != 300 || img.Bounds().Dy() != 300 {
+		t.Errorf("Expected a 300x300 image, got %v", img.Bounds())
+	}
+}
+
+func TestRender_SVG(t *testing.T) {
+	opts := qr.DefaultOptions()
+	opts.Format = qr.FormatSVG
+
+	data, err := qr.Render("http://localhost:8000/abc123", opts)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	svg := string(data)
+	if !strings.HasPrefix(svg, "<svg") || !strings.HasSuffix(svg, "</svg>") {
+		t.Errorf("Expected an SVG document, got %s", svg)
+	}
+	if opts.ContentType() != "image/svg+xml" {
+		t.Errorf("Expected SVG content type, got %s", opts.ContentType())
+	}
+}
@@ -0,0 +1,74 @@
+package reserved
+
+import (
+	"sort"
+	"strings"
+)
+
+// Registry holds the words that cannot be used as short codes because an
+// application route lives at that path.
+type Registry struct {
+	words map[string]bool
+}
+
+// New creates a new Registry instance holding the given words.
+func New(words ...string) *Registry {
+	r := &Registry{words: make(map[string]bool)}
+	r.Add(words...)
+
+	return r
+}
+
+// Add reserves the words, ignoring surrounding slashes and blanks.
+func (r *Registry) Add(words ...string) {
+	for _, word := range words {
+		if word = strings.Trim(strings.TrimSpace(word), "/"); word != "" {
+			r.words[word] = true
+		}
+	}
+}
+
+// AddPattern reserves the first path segment of a ServeMux pattern, e.g.
+// "api" for "GET /api/links/{code}". Patterns without a fixed first segment,
+// such as "/", reserve nothing.
+func (r *Registry) AddPattern(pattern string) {
+	r.Add(FirstSegment(pattern))
+}
+
+// IsReserved reports whether the code is taken by an application route.
+func (r *Registry) IsReserved(code string) bool {
+	if r == nil {
+		return false
+	}
+
+	return r.words[code]
+}
+
+// Words returns the reserved words in alphabetical order.
+func (r *Registry) Words() []string {
+	words := make([]string, 0, len(r.words))
+	for word := range r.words {
+		words = append(words, word)
+	}
+	sort.Strings(words)
+
+	return words
+}
+
+// FirstSegment returns the first fixed path segment of a ServeMux pattern.
+func FirstSegment(pattern string) string {
+	// Drop the method and host parts of patterns like "GET example.org/path".
+	if _, path, ok := strings.Cut(pattern, " "); ok {
+		pattern = strings.TrimSpace(path)
+	}
+	if i := strings.Index(pattern, "/"); i >= 0 {
+		pattern = pattern[i:]
+	}
+
+	segment, _, _ := strings.Cut(strings.TrimPrefix(pattern, "/"), "/")
+	if strings.HasPrefix(segment, "{") {
+		return ""
+	}
+
+	return segment
+}
@@ -0,0 +1,54 @@
+package reserved_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/reserved"
+)
+
+func TestFirstSegment(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		expected string
+	}{
+		{pattern: "/", expected: ""},
+		{pattern: "/home", expected: "home"},
+		{pattern: "/static/", expected: "static"},
+		{pattern: "GET /api/links/{code}", expected: "api"},
+		{pattern: "POST example.org/shorten", expected: "shorten"},
+		{pattern: "/{code}", expected: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			if segment := reserved.FirstSegment(tt.pattern); segment != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, segment)
+			}
+		})
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	registry := reserved.New("admin", " /login/ ", "")
+	registry.AddPattern("/static/")
+	registry.AddPattern("GET /api/links/{code}")
+	registry.AddPattern("/api/shorten")
+
+	if !registry.IsReserved("login") || !registry.IsReserved("api") || !registry.IsReserved("static") {
+		t.Errorf("Expected configured words and route segments to be reserved, got %v", registry.Words())
+	}
+	if registry.IsReserved("abc123") {
+		t.Error("Expected abc123 not to be reserved")
+	}
+
+	expected := []string{"admin", "api", "login", "static"}
+	if words := registry.Words(); !reflect.DeepEqual(words, expected) {
+		t.Errorf("Expected words %v, got %v", expected, words)
+	}
+
+	var empty *reserved.Registry
+	if empty.IsReserved("home") {
+		t.Error("Expected a nil registry to reserve nothing")
+	}
+}
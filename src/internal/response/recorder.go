@@ -0,0 +1,53 @@
+// Package response records what handlers write, for the middlewares that
+// report on responses: metrics, access logs and traces.
+package response
+
+import "net/http"
+
+// Recorder wraps a ResponseWriter and remembers the status code and the size
+// of the body written through it.
+type Recorder struct {
+	http.ResponseWriter
+	status      int
+	bytes       int64
+	wroteHeader bool
+}
+
+// NewRecorder wraps the writer. The status is 200 until the handler writes
+// another one.
+func NewRecorder(wr http.ResponseWriter) *Recorder {
+	return &Recorder{ResponseWriter: wr, status: http.StatusOK}
+}
+
+// Status returns the status code sent to the client.
+func (r *Recorder) Status() int {
+	return r.status
+}
+
+// Bytes returns the number of body bytes written.
+func (r *Recorder) Bytes() int64 {
+	return r.bytes
+}
+
+// WriteHeader remembers the first final status code. Informational ones, such
+// as 103 Early Hints, are passed on and followed by the final one.
+func (r *Recorder) WriteHeader(status int) {
+	if !r.wroteHeader && (status >= http.StatusOK || status == http.StatusSwitchingProtocols) {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *Recorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += int64(n)
+
+	return n, err
+}
+
+// Unwrap gives http.ResponseController access to the wrapped writer.
+func (r *Recorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
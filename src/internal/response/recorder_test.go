@@ -0,0 +1,71 @@
+package response_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/response"
+)
+
+func TestRecorder(t *testing.T) {
+	tests := []struct {
+		name   string
+		write  func(wr http.ResponseWriter)
+		status int
+		bytes  int64
+	}{
+		{
+			name:   "implicit status",
+			write:  func(wr http.ResponseWriter) { _, _ = wr.Write([]byte("hello")) },
+			status: http.StatusOK,
+			bytes:  5,
+		},
+		{
+			name: "first status wins",
+			write: func(wr http.ResponseWriter) {
+				wr.WriteHeader(http.StatusNotFound)
+				wr.WriteHeader(http.StatusInternalServerError)
+				_, _ = wr.Write([]byte("gone"))
+			},
+			status: http.StatusNotFound,
+			bytes:  4,
+		},
+		{
+			name: "status after body is ignored",
+			write: func(wr http.ResponseWriter) {
+				_, _ = wr.Write([]byte("ok"))
+				wr.WriteHeader(http.StatusBadGateway)
+			},
+			status: http.StatusOK,
+			bytes:  2,
+		},
+		{
+			name: "informational status is skipped",
+			write: func(wr http.ResponseWriter) {
+				wr.WriteHeader(http.StatusEarlyHints)
+				wr.WriteHeader(http.StatusFound)
+			},
+			status: http.StatusFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := response.NewRecorder(httptest.NewRecorder())
+			tt.write(recorder)
+
+			if recorder.Status() != tt.status || recorder.Bytes() != tt.bytes {
+				t.Errorf("Expected %d with %d bytes, got %d with %d bytes", tt.status, tt.bytes, recorder.Status(), recorder.Bytes())
+			}
+		})
+	}
+}
+
+func TestRecorder_Unwrap(t *testing.T) {
+	underlying := httptest.NewRecorder()
+
+	if got := response.NewRecorder(underlying).Unwrap(); got != underlying {
+		t.Errorf("Expected the wrapped writer, got %v", got)
+	}
+}
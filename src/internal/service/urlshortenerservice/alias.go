@@ -0,0 +1,107 @@
+package urlshortenerservice
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"regexp"
+
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/db"
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/tracing"
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/urlshortenererror"
+)
+
+// aliasPattern restricts custom short codes to characters that are safe in a
+// path and cannot be mistaken for the .qr and + suffixes.
+var aliasPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{3,32}$`)
+
+// ValidateAlias checks that a custom short code is well formed and does not
+// collide with an application route.
+func (s URLShortenerService) ValidateAlias(alias string) error {
+	if !aliasPattern.MatchString(alias) {
+		return invalidOption("Aliases must be 3 to 32 letters, digits, - or _")
+	}
+	if s.reserved.IsReserved(alias) {
+		return invalidOption("The alias " + alias + " is reserved")
+	}
+
+	return nil
+}
+
+// ShortenURLWithAlias stores the URL under a custom short code chosen by the
+// user in the namespace of the domain.
+func (s URLShortenerService) ShortenURLWithAlias(ctx context.Context, domain, originalURL, alias string, options db.LinkOptions) (_ string, err error) {
+	ctx, span := s.tracer.Start(ctx, "URLShortenerService.ShortenURLWithAlias")
+	defer tracing.End(span, &err)
+
+	if err = s.ValidateAlias(alias); err != nil {
+		return "", err
+	}
+
+	originalURL, err = normalizeDestination(originalURL)
+	if err != nil {
+		return "", err
+	}
+
+	if options, err = normalizeLinkOptions(options); err != nil {
+		return "", err
+	}
+
+	if _, err = s.db.StoreURLWithOptions(ctx, db.LinkKey(domain, alias), originalURL, options); err != nil {
+		var webErr *urlshortenererror.WebError
+		if errors.As(err, &webErr) && errors.Is(webErr.ErrType, urlshortenererror.ErrDuplicate) {
+			return "", urlshortenererror.Wrap(nil, "The alias "+alias+" is already taken", http.StatusConflict, urlshortenererror.ErrDuplicate)
+		}
+
+		return "", err
+	}
+
+	return alias, nil
+}
+
+// CheckShortURL returns a not found error unless the code is a short URL of
+// the domain that no application route shadows.
+func (s URLShortenerService) CheckShortURL(ctx context.Context, domain, shortURL string) error {
+	if s.reserved.IsReserved(shortURL) {
+		return urlshortenererror.Wrap(nil, "URL not found", http.StatusNotFound, urlshortenererror.ErrNotFound)
+	}
+
+	_, err := s.db.GetURLMap(ctx, db.LinkKey(domain, shortURL))
+
+	return err
+}
+
+// ReservedConflicts returns the existing short URLs that are shadowed by an
+// application route and can no longer be reached. The routes are served on
+// every domain, so the links of custom domains are reported as <host>/<code>.
+func (s URLShortenerService) ReservedConflicts(ctx context.Context) ([]string, error) {
+	if s.reserved == nil {
+		return []string{}, nil
+	}
+
+	domains, err := s.db.GetDomains(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+
+	words := s.reserved.Words()
+	keys := make([]string, 0, len(words)*(len(domains)+1))
+	keys = append(keys, words...)
+	for _, domain := range domains {
+		for _, word := range words {
+			keys = append(keys, db.LinkKey(domain.Host, word))
+		}
+	}
+
+	urlMaps, err := s.db.GetURLMaps(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	conflicts := make([]string, 0, len(urlMaps))
+	for _, urlMap := range urlMaps {
+		conflicts = append(conflicts, urlMap.ShortURL)
+	}
+
+	return conflicts, nil
+}
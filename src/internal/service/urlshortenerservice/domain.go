@@ -0,0 +1,143 @@
+package urlshortenerservice
+
+import (
+	"context"
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/db"
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/metrics"
+)
+
+// DomainCacheTTL is how long the custom domains are kept in memory before
+// they are loaded again, so changes made on another instance show up.
+const DomainCacheTTL = 30 * time.Second
+
+// maxAccountIDLength bounds the account IDs stored with custom domains.
+const maxAccountIDLength = 128
+
+// hostPattern matches lowercase host names of at least two labels.
+var hostPattern = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// NormalizeHost lowercases the host of a request and removes its port and
+// any trailing dot.
+func NormalizeHost(host string) string {
+	if name, _, err := net.SplitHostPort(host); err == nil {
+		host = name
+	}
+
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
+
+// ResolveDomain returns the custom domain serving the host, or nil when the
+// host serves the default namespace.
+func (s URLShortenerService) ResolveDomain(ctx context.Context, host string) (*db.Domain, error) {
+	domains, err := s.domains.get(ctx, s.db, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	return domains[NormalizeHost(host)], nil
+}
+
+// GetDomains returns the custom domains of the account.
+func (s URLShortenerService) GetDomains(ctx context.Context, accountID string) ([]db.Domain, error) {
+	if accountID == "" {
+		return nil, invalidOption("The account ID is required")
+	}
+
+	return s.db.GetDomains(ctx, accountID)
+}
+
+// SaveDomain adds a custom domain to the account or updates its settings.
+func (s URLShortenerService) SaveDomain(ctx context.Context, domain db.Domain) (*db.Domain, error) {
+	domain.Host = NormalizeHost(domain.Host)
+	if len(domain.Host) > 253 || !hostPattern.MatchString(domain.Host) {
+		return nil, invalidOption("Invalid domain name. Example: go.example.org")
+	}
+	if domain.AccountID == "" || len(domain.AccountID) > maxAccountIDLength {
+		return nil, invalidOption("The account ID must be 1 to 128 characters")
+	}
+	if domain.RedirectCode != 0 && !IsRedirectCode(domain.RedirectCode) {
+		return nil, invalidOption("Redirect code must be one of 301, 302, 307 or 308")
+	}
+	if domain.NotFoundURL != "" {
+		notFoundURL, err := normalizeDestination(domain.NotFoundURL)
+		if err != nil {
+			return nil, err
+		}
+		domain.NotFoundURL = notFoundURL
+	}
+
+	stored, err := s.db.StoreDomain(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+	s.domains.invalidate()
+
+	return stored, nil
+}
+
+// DeleteDomain removes a custom domain of the account. Its short URLs stop
+// resolving until the domain is added again.
+func (s URLShortenerService) DeleteDomain(ctx context.Context, host, accountID string) error {
+	if accountID == "" {
+		return invalidOption("The account ID is required")
+	}
+
+	if err := s.db.DeleteDomain(ctx, NormalizeHost(host), accountID); err != nil {
+		return err
+	}
+	s.domains.invalidate()
+
+	return nil
+}
+
+// domainCache keeps the custom domains by host.
+type domainCache struct {
+	loadedAt time.Time
+	domains  map[string]*db.Domain
+	metrics  *metrics.Metrics
+	lock     sync.Mutex
+}
+
+func newDomainCache(m *metrics.Metrics) *domainCache {
+	return &domainCache{metrics: m}
+}
+
+// get returns the cached domains, loading them again once they are older
+// than DomainCacheTTL.
+func (c *domainCache) get(ctx context.Context, database db.Database, now time.Time) (map[string]*db.Domain, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.domains != nil && now.Sub(c.loadedAt) < DomainCacheTTL {
+		c.metrics.DomainLookup(metrics.CacheHit)
+		return c.domains, nil
+	}
+	c.metrics.DomainLookup(metrics.CacheMiss)
+
+	list, err := database.GetDomains(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+
+	c.domains = make(map[string]*db.Domain, len(list))
+	for i := range list {
+		c.domains[list[i].Host] = &list[i]
+	}
+	c.loadedAt = now
+
+	return c.domains, nil
+}
+
+// invalidate makes the next lookup load the domains again.
+func (c *domainCache) invalidate() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.domains = nil
+}
@@ -0,0 +1,46 @@
+package urlshortenerservice
+
+import (
+	"time"
+
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/db"
+)
+
+// Health statuses reported for a destination.
+const (
+	HealthStatusUp      = "up"
+	HealthStatusDown    = "down"
+	HealthStatusUnknown = "unknown"
+)
+
+// Health holds the results of the background checks of a destination.
+type Health struct {
+	CheckedAt           *time.Time     `json:"checked_at,omitempty"`
+	Status              string         `json:"status"`
+	Checks              []db.LinkCheck `json:"checks,omitempty"`
+	ConsecutiveFailures int            `json:"consecutive_failures"`
+}
+
+// isFailing reports whether the destination failed enough checks in a row
+// for the fallback to be used.
+func (s URLShortenerService) isFailing(urlMap *db.URLMap) bool {
+	return urlMap.ConsecutiveFailures >= s.fallbackAfter
+}
+
+// health summarizes the check results stored with the link.
+func (s URLShortenerService) health(urlMap *db.URLMap) *Health {
+	health := &Health{
+		CheckedAt:           urlMap.CheckedAt,
+		Status:              HealthStatusUp,
+		ConsecutiveFailures: urlMap.ConsecutiveFailures,
+	}
+
+	switch {
+	case urlMap.CheckedAt == nil:
+		health.Status = HealthStatusUnknown
+	case s.isFailing(urlMap):
+		health.Status = HealthStatusDown
+	}
+
+	return health
+}
@@ -0,0 +1,84 @@
+package urlshortenerservice
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/db"
+)
+
+// InterstitialPolicy decides which destinations are shown behind a
+// "You are leaving" page instead of being redirected to immediately.
+type InterstitialPolicy struct {
+	// Domains lists the domains, including their subdomains, that always get
+	// the page.
+	Domains []string
+	// InternalDomains lists the domains of the deployment. When set, every
+	// other domain is external and gets the page.
+	InternalDomains []string
+	// Unsafe shows the page for destinations failing the safety checks.
+	Unsafe bool
+}
+
+// Interstitial holds why the interstitial page is shown.
+type Interstitial struct {
+	Reasons []string
+}
+
+// checkInterstitial decides whether the visitor is warned before being sent
+// to the destination. The per-link setting takes precedence over the policy.
+func (p InterstitialPolicy) checkInterstitial(location, mode string) *Interstitial {
+	switch mode {
+	case db.InterstitialNever:
+		return nil
+	case db.InterstitialAlways:
+		return &Interstitial{Reasons: CheckSafety(location).Warnings}
+	}
+
+	var host string
+	if parsedURL, err := url.Parse(location); err == nil {
+		host = strings.ToLower(parsedURL.Hostname())
+	}
+
+	var reasons []string
+	if matchDomains(host, p.Domains) {
+		reasons = append(reasons, "The destination is on a domain that requires a warning")
+	}
+	if len(p.InternalDomains) > 0 && !matchDomains(host, p.InternalDomains) {
+		reasons = append(reasons, "The destination is outside of this organization")
+	}
+	if p.Unsafe {
+		reasons = append(reasons, CheckSafety(location).Warnings...)
+	}
+
+	if len(reasons) == 0 {
+		return nil
+	}
+
+	return &Interstitial{Reasons: reasons}
+}
+
+// matchDomains reports whether the host is one of the domains or a subdomain of one.
+func matchDomains(host string, domains []string) bool {
+	for _, domain := range domains {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// NormalizeDomains lowercases the domains of a policy and drops empty entries
+// and leading dots.
+func NormalizeDomains(domains []string) []string {
+	normalized := make([]string, 0, len(domains))
+	for _, domain := range domains {
+		domain = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(domain)), ".")
+		if domain != "" {
+			normalized = append(normalized, domain)
+		}
+	}
+
+	return normalized
+}
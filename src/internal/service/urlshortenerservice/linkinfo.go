@@ -0,0 +1,230 @@
+package urlshortenerservice
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/db"
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/urlshortenererror"
+)
+
+// MaxExpandURLs is the number of short URLs that can be expanded at once.
+const MaxExpandURLs = 100
+
+// Safety statuses reported for a destination.
+const (
+	SafetyStatusSafe    = "safe"
+	SafetyStatusWarning = "warning"
+	SafetyStatusUnknown = "unknown"
+)
+
+// LinkInfo holds the public details of a short URL.
+type LinkInfo struct {
+	CreatedAt   time.Time     `json:"created_at"`
+	ConsumedAt  *time.Time    `json:"consumed_at,omitempty"`
+	ShortURL    string        `json:"short_code"`
+	OriginalURL string        `json:"original_url,omitempty"`
+	Options     PublicOptions `json:"options"`
+	Safety      Safety        `json:"safety"`
+	Variants    []VariantInfo `json:"variants,omitempty"`
+	Health      *Health       `json:"health,omitempty"`
+	Hits        int64         `json:"hits"`
+	Protected   bool          `json:"password_protected"`
+	// Pending is set while the schedule of the link has not activated it yet.
+	Pending bool `json:"pending,omitempty"`
+}
+
+// PublicOptions holds the settings of a link anyone may see. Destinations
+// besides the main one, such as the fallback, targeting and language ones,
+// are never included.
+type PublicOptions struct {
+	ActiveFrom   string `json:"active_from,omitempty"`
+	Interstitial string `json:"interstitial,omitempty"`
+	RedirectCode int    `json:"redirect_code,omitempty"`
+	OneTime      bool   `json:"one_time,omitempty"`
+}
+
+// ExpandResult holds the details of the expanded short URLs.
+type ExpandResult struct {
+	Links    []LinkInfo `json:"links"`
+	NotFound []string   `json:"not_found"`
+}
+
+// Safety holds the result of the safety checks run against a destination.
+type Safety struct {
+	Status   string   `json:"status"`
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// GetLinkInfo returns the details of a short URL of the domain at the given
+// time without counting a hit.
+func (s URLShortenerService) GetLinkInfo(ctx context.Context, domain, shortURL string, now time.Time) (*LinkInfo, error) {
+	urlMap, err := s.db.GetURLMap(ctx, db.LinkKey(domain, shortURL))
+	if err != nil {
+		return nil, err
+	}
+
+	variantHits, err := s.getVariantHits(ctx, []db.URLMap{*urlMap})
+	if err != nil {
+		return nil, err
+	}
+
+	info := s.newLinkInfo(urlMap, variantHits[urlMap.ShortURL], now)
+	if info.Health != nil {
+		if info.Health.Checks, err = s.db.GetLinkChecks(ctx, urlMap.ShortURL); err != nil {
+			return nil, err
+		}
+	}
+
+	return &info, nil
+}
+
+// ExpandURLs returns the details of several short URLs of the domain at the
+// given time without counting hits. The links keep the order in which the
+// short URLs were requested.
+func (s URLShortenerService) ExpandURLs(ctx context.Context, domain string, shortURLs []string, now time.Time) (*ExpandResult, error) {
+	unique := make([]string, 0, len(shortURLs))
+	seen := make(map[string]bool, len(shortURLs))
+	for _, shortURL := range shortURLs {
+		if shortURL != "" && !seen[shortURL] {
+			seen[shortURL] = true
+			unique = append(unique, shortURL)
+		}
+	}
+
+	if len(unique) == 0 {
+		return nil, urlshortenererror.Wrap(nil, "At least one short URL is required", http.StatusBadRequest, urlshortenererror.ErrInvalidInput)
+	}
+	if len(unique) > MaxExpandURLs {
+		return nil, urlshortenererror.Wrap(
+			nil,
+			fmt.Sprintf("At most %d short URLs can be expanded at once", MaxExpandURLs),
+			http.StatusBadRequest,
+			urlshortenererror.ErrInvalidInput,
+		)
+	}
+
+	keys := make([]string, 0, len(unique))
+	for _, shortURL := range unique {
+		keys = append(keys, db.LinkKey(domain, shortURL))
+	}
+
+	urlMaps, err := s.db.GetURLMaps(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	variantHits, err := s.getVariantHits(ctx, urlMaps)
+	if err != nil {
+		return nil, err
+	}
+
+	found := make(map[string]*db.URLMap, len(urlMaps))
+	for i := range urlMaps {
+		found[urlMaps[i].ShortURL] = &urlMaps[i]
+	}
+
+	result := &ExpandResult{
+		Links:    make([]LinkInfo, 0, len(urlMaps)),
+		NotFound: make([]string, 0),
+	}
+	for i, shortURL := range unique {
+		if urlMap, ok := found[keys[i]]; ok {
+			result.Links = append(result.Links, s.newLinkInfo(urlMap, variantHits[keys[i]], now))
+		} else {
+			result.NotFound = append(result.NotFound, shortURL)
+		}
+	}
+
+	return result, nil
+}
+
+// getVariantHits loads the variant click counts of the links that split their traffic.
+func (s URLShortenerService) getVariantHits(ctx context.Context, urlMaps []db.URLMap) (map[string]map[string]int64, error) {
+	var split []string
+	for _, urlMap := range urlMaps {
+		if len(urlMap.Options.Variants) > 0 {
+			split = append(split, urlMap.ShortURL)
+		}
+	}
+
+	if len(split) == 0 {
+		return map[string]map[string]int64{}, nil
+	}
+
+	return s.db.GetVariantHits(ctx, split)
+}
+
+// newLinkInfo builds the public details of a link. Password protected and
+// one-time links, and links their schedule has not activated yet, do not
+// reveal where they point to.
+func (s URLShortenerService) newLinkInfo(urlMap *db.URLMap, variantHits map[string]int64, now time.Time) LinkInfo {
+	_, code := db.SplitLinkKey(urlMap.ShortURL)
+	options := PublicOptions{
+		Interstitial: urlMap.Options.Interstitial,
+		RedirectCode: urlMap.Options.RedirectCode,
+		OneTime:      urlMap.Options.OneTime,
+	}
+	if urlMap.Options.Schedule != nil {
+		options.ActiveFrom = urlMap.Options.Schedule.ActiveFrom
+	}
+
+	info := LinkInfo{
+		CreatedAt:  urlMap.CreatedAt,
+		ConsumedAt: urlMap.ConsumedAt,
+		ShortURL:   code,
+		Options:    options,
+		Safety:     Safety{Status: SafetyStatusUnknown},
+		Hits:       urlMap.Hits,
+		Protected:  urlMap.Options.PasswordHash != "",
+		Pending:    checkActive(urlMap.Options.Schedule, now) != nil,
+	}
+	if info.Protected || info.Pending || urlMap.Options.OneTime {
+		return info
+	}
+
+	info.OriginalURL = urlMap.OriginalURL
+	info.Safety = CheckSafety(urlMap.OriginalURL)
+	info.Variants = variantInfo(urlMap.Options.Variants, variantHits)
+	info.Health = s.health(urlMap)
+
+	return info
+}
+
+// CheckSafety looks for common signs of a misleading destination.
+func CheckSafety(originalURL string) Safety {
+	parsedURL, err := url.Parse(originalURL)
+	if err != nil {
+		return Safety{Status: SafetyStatusWarning, Warnings: []string{"The destination is not a valid URL"}}
+	}
+
+	var warnings []string
+	host := parsedURL.Hostname()
+
+	if parsedURL.Scheme != "https" {
+		warnings = append(warnings, "The destination does not use HTTPS")
+	}
+	if parsedURL.User != nil {
+		warnings = append(warnings, "The destination contains credentials, which can hide the real host")
+	}
+	if net.ParseIP(host) != nil {
+		warnings = append(warnings, "The destination is an IP address instead of a domain name")
+	}
+	if strings.HasPrefix(host, "xn--") || strings.Contains(host, ".xn--") {
+		warnings = append(warnings, "The destination uses an internationalized domain that may imitate another one")
+	}
+	if parsedURL.Port() != "" {
+		warnings = append(warnings, "The destination uses a non-standard port")
+	}
+
+	if len(warnings) > 0 {
+		return Safety{Status: SafetyStatusWarning, Warnings: warnings}
+	}
+
+	return Safety{Status: SafetyStatusSafe}
+}
@@ -0,0 +1,152 @@
+package urlshortenerservice
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/db"
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/language"
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/urlshortenererror"
+)
+
+// cacheControlDirectives lists the Cache-Control directives a link may use and
+// whether they take a number of seconds as argument.
+var cacheControlDirectives = map[string]bool{
+	"no-store":               false,
+	"no-cache":               false,
+	"private":                false,
+	"public":                 false,
+	"must-revalidate":        false,
+	"proxy-revalidate":       false,
+	"immutable":              false,
+	"max-age":                true,
+	"s-maxage":               true,
+	"stale-while-revalidate": true,
+	"stale-if-error":         true,
+}
+
+// IsRedirectCode reports whether the status code can be used to redirect a short URL.
+func IsRedirectCode(code int) bool {
+	switch code {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	default:
+		return false
+	}
+}
+
+// ValidateCacheControl checks that the value is a list of supported Cache-Control directives.
+func ValidateCacheControl(value string) error {
+	for _, directive := range strings.Split(value, ",") {
+		name, arg, hasArg := strings.Cut(strings.TrimSpace(directive), "=")
+		name = strings.ToLower(name)
+
+		takesArg, known := cacheControlDirectives[name]
+		if !known || takesArg != hasArg {
+			return invalidOption("Unsupported Cache-Control directive: " + strings.TrimSpace(directive))
+		}
+		if seconds, err := strconv.Atoi(arg); hasArg && (err != nil || seconds < 0) {
+			return invalidOption("Cache-Control " + name + " must be a number of seconds")
+		}
+	}
+
+	return nil
+}
+
+// isCacheable reports whether a validated Cache-Control value lets browsers
+// or shared caches store the response.
+func isCacheable(value string) bool {
+	for _, directive := range strings.Split(value, ",") {
+		if strings.EqualFold(strings.TrimSpace(directive), "no-store") {
+			return false
+		}
+	}
+
+	return true
+}
+
+// normalizeLinkOptions checks the per-link settings before they are stored and
+// normalizes the destinations they contain.
+func normalizeLinkOptions(options db.LinkOptions) (db.LinkOptions, error) {
+	if options.RedirectCode != 0 && !IsRedirectCode(options.RedirectCode) {
+		return options, invalidOption("Redirect code must be one of 301, 302, 307 or 308")
+	}
+
+	if options.OneTime && (options.RedirectCode == http.StatusMovedPermanently || options.RedirectCode == http.StatusPermanentRedirect) {
+		return options, invalidOption("One-time links cannot use a permanent redirect code")
+	}
+
+	switch options.Interstitial {
+	case "", db.InterstitialAlways, db.InterstitialNever:
+	default:
+		return options, invalidOption("Interstitial must be always or never")
+	}
+
+	if options.CacheControl != "" {
+		if err := ValidateCacheControl(options.CacheControl); err != nil {
+			return options, err
+		}
+		if options.OneTime && isCacheable(options.CacheControl) {
+			return options, invalidOption("One-time links cannot use a cacheable Cache-Control")
+		}
+	}
+
+	if options.Fallback != "" {
+		fallback, err := normalizeDestination(options.Fallback)
+		if err != nil {
+			return options, err
+		}
+		options.Fallback = fallback
+	}
+
+	if options.Passthrough != nil {
+		switch options.Passthrough.QueryConflict {
+		case "", db.QueryConflictKeep, db.QueryConflictOverride, db.QueryConflictAppend:
+		default:
+			return options, invalidOption("Query conflict must be one of keep, override or append")
+		}
+	}
+
+	targeting, err := normalizeTargeting(options.Targeting)
+	if err != nil {
+		return options, err
+	}
+	if len(targeting) > 0 {
+		options.Targeting = targeting
+	}
+
+	if options.Schedule != nil {
+		if options.Schedule, err = normalizeSchedule(*options.Schedule); err != nil {
+			return options, err
+		}
+	}
+
+	if options.Variants, err = normalizeVariants(options.Variants); err != nil {
+		return options, err
+	}
+
+	if len(options.Languages) > 0 {
+		languages := make(map[string]string, len(options.Languages))
+		for tag, destination := range options.Languages {
+			if !language.IsValidTag(tag) {
+				return options, invalidOption("Invalid language tag: " + tag)
+			}
+			// Tags are matched case-insensitively, so they are stored lower-cased.
+			lower := strings.ToLower(tag)
+			if _, ok := languages[lower]; ok {
+				return options, invalidOption("Duplicate language tag: " + tag)
+			}
+			if languages[lower], err = normalizeDestination(destination); err != nil {
+				return options, err
+			}
+		}
+		options.Languages = languages
+	}
+
+	return options, nil
+}
+
+func invalidOption(message string) error {
+	return urlshortenererror.Wrap(nil, message, http.StatusBadRequest, urlshortenererror.ErrInvalidInput)
+}
@@ -0,0 +1,86 @@
+package urlshortenerservice
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/db"
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/urlshortenererror"
+)
+
+// applyPassthrough appends the extra path and merges the query of the request
+// into the destination, as far as the link allows it.
+func applyPassthrough(destination string, passthrough *db.Passthrough, extraPath string, query url.Values) (string, error) {
+	if passthrough == nil {
+		passthrough = &db.Passthrough{}
+	}
+
+	if extraPath != "" && !passthrough.Path {
+		return "", urlshortenererror.Wrap(nil, "URL not found", http.StatusNotFound, urlshortenererror.ErrNotFound)
+	}
+
+	if extraPath == "" && (!passthrough.Query || len(query) == 0) {
+		return destination, nil
+	}
+
+	parsedURL, err := url.Parse(destination)
+	if err != nil {
+		return "", urlshortenererror.Wrap(err, "invalid destination URL", http.StatusInternalServerError, urlshortenererror.ErrServerError)
+	}
+
+	if extraPath != "" {
+		parsedURL = parsedURL.JoinPath(extraPath)
+	}
+
+	if passthrough.Query && len(query) > 0 {
+		parsedURL.RawQuery = mergeQuery(parsedURL.RawQuery, query, passthrough.QueryConflict)
+	}
+
+	return parsedURL.String(), nil
+}
+
+// mergeQuery adds the request parameters to the destination query,
+// resolving parameters present in both according to the conflict rule. The
+// destination parameters that are kept are left exactly as they were written,
+// and a query that gains nothing is returned unchanged.
+func mergeQuery(rawQuery string, request url.Values, conflict string) string {
+	destination, _ := url.ParseQuery(rawQuery)
+
+	added := url.Values{}
+	replaced := make(map[string]bool)
+	for key, values := range request {
+		if _, exists := destination[key]; !exists {
+			added[key] = values
+
+			continue
+		}
+
+		switch conflict {
+		case db.QueryConflictOverride:
+			added[key] = values
+			replaced[key] = true
+		case db.QueryConflictAppend:
+			added[key] = values
+		default:
+			// db.QueryConflictKeep: the destination value wins.
+		}
+	}
+
+	if len(added) == 0 {
+		return rawQuery
+	}
+
+	var pairs []string
+	for _, pair := range strings.Split(rawQuery, "&") {
+		key, _, _ := strings.Cut(pair, "=")
+		if unescaped, err := url.QueryUnescape(key); err == nil {
+			key = unescaped
+		}
+		if pair != "" && !replaced[key] {
+			pairs = append(pairs, pair)
+		}
+	}
+
+	return strings.Join(append(pairs, added.Encode()), "&")
+}
@@ -0,0 +1,162 @@
+package urlshortenerservice
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/db"
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/language"
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/logging"
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/tracing"
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/urlshortenererror"
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/useragent"
+)
+
+// RedirectRequest holds the parts of an incoming request used to pick the destination.
+type RedirectRequest struct {
+	Now            time.Time
+	Query          url.Values
+	ShortURL       string
+	ExtraPath      string
+	UserAgent      string
+	AcceptLanguage string
+	// Domain is the host of the custom domain the request was sent to,
+	// empty for the default namespace.
+	Domain string
+	// Variant is the split test variant the visitor was assigned before.
+	Variant string
+	// UnlockToken proves the password of a protected link was entered.
+	UnlockToken string
+}
+
+// Redirect holds the destination chosen for a short URL.
+type Redirect struct {
+	URLMap   *db.URLMap
+	Location string
+	// Variant is the split test variant the visitor is assigned to, if any.
+	Variant string
+	// Vary lists the request headers the destination depends on.
+	Vary []string
+	// Fallback is set when the destination is down and the link's fallback
+	// is used instead.
+	Fallback bool
+	// Interstitial is set when the visitor is warned before continuing to
+	// the destination.
+	Interstitial *Interstitial
+	// Temporary is set when the destination changes over time, so the
+	// redirect should not be cached.
+	Temporary bool
+}
+
+// Redirect resolves the destination of a short URL and counts the hit. The
+// hit is counted first, by a single update that also checks the link can be
+// visited, so the hot path takes one round trip to the database.
+func (s URLShortenerService) Redirect(ctx context.Context, req RedirectRequest) (_ *Redirect, err error) {
+	ctx, span := s.tracer.Start(ctx, "URLShortenerService.Redirect",
+		trace.WithAttributes(attribute.String("short_url", req.ShortURL), attribute.String("domain", req.Domain)))
+	defer tracing.End(span, &err)
+
+	key := db.LinkKey(req.Domain, req.ShortURL)
+	urlMap, visited, err := s.db.VisitURL(ctx, key, db.VisitGuard{
+		Now:                 req.Now,
+		PasswordFingerprint: s.unlockedFingerprint(key, req.UnlockToken, req.Now),
+		ExtraPath:           req.ExtraPath != "",
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !visited {
+		return nil, s.refusal(urlMap, req)
+	}
+
+	// One-time links must reach the server on every visit to be consumed.
+	redirect := &Redirect{URLMap: urlMap, Temporary: urlMap.Options.OneTime}
+	destination := redirect.chooseDestination(req)
+	if destination == urlMap.OriginalURL && urlMap.Options.Fallback != "" && s.isFailing(urlMap) {
+		// Visitors return to the primary destination once it is up again.
+		destination = urlMap.Options.Fallback
+		redirect.Fallback = true
+		redirect.Temporary = true
+	}
+
+	redirect.Location, err = applyPassthrough(destination, urlMap.Options.Passthrough, req.ExtraPath, req.Query)
+	if err != nil {
+		return nil, err
+	}
+	redirect.Interstitial = s.interstitial.checkInterstitial(redirect.Location, urlMap.Options.Interstitial)
+
+	span.SetAttributes(attribute.Bool("redirect.fallback", redirect.Fallback), attribute.String("redirect.variant", redirect.Variant))
+	if redirect.Variant != "" {
+		// The visit is counted already, a lost variant hit only skews the split test stats.
+		if countErr := s.db.CountVariantHit(ctx, key, redirect.Variant); countErr != nil {
+			s.logger.WarnContext(ctx, "Failed to count variant hit", "short_url", key, "variant", redirect.Variant, logging.Error(countErr))
+		}
+	}
+
+	return redirect, nil
+}
+
+// refusal explains why the visit of an existing link was not counted.
+func (s URLShortenerService) refusal(urlMap *db.URLMap, req RedirectRequest) error {
+	if urlMap.ConsumedAt != nil {
+		return urlshortenererror.Wrap(nil, "This link has already been used", http.StatusGone, urlshortenererror.ErrGone)
+	}
+
+	if err := checkActive(urlMap.Options.Schedule, req.Now); err != nil {
+		return err
+	}
+
+	if err := s.checkUnlocked(urlMap, req.UnlockToken, req.Now); err != nil {
+		return err
+	}
+
+	// What is left is an extra path the link does not pass through.
+	return urlshortenererror.Wrap(nil, "URL not found", http.StatusNotFound, urlshortenererror.ErrNotFound)
+}
+
+// chooseDestination picks the destination for the client. A schedule window
+// takes precedence over device targeting, which takes precedence over the
+// language, and the split test variants, or else the original URL, are the fallback.
+func (r *Redirect) chooseDestination(req RedirectRequest) string {
+	options := r.URLMap.Options
+
+	if options.Schedule != nil {
+		r.Temporary = true
+		if target, ok := matchSchedule(options.Schedule, req.Now); ok {
+			return target
+		}
+	}
+
+	if len(options.Targeting) > 0 {
+		r.Vary = append(r.Vary, "User-Agent")
+		if target, ok := matchTargeting(options.Targeting, useragent.Parse(req.UserAgent)); ok {
+			return target
+		}
+	}
+
+	if len(options.Languages) > 0 {
+		r.Vary = append(r.Vary, "Accept-Language")
+		tags := make([]string, 0, len(options.Languages))
+		for tag := range options.Languages {
+			tags = append(tags, tag)
+		}
+		if tag, ok := language.Match(req.AcceptLanguage, tags); ok {
+			return options.Languages[tag]
+		}
+	}
+
+	if len(options.Variants) > 0 {
+		r.Vary = append(r.Vary, "Cookie")
+		variant := pickVariant(options.Variants, req.Variant)
+		r.Variant = variant.Name
+
+		return variant.Destination
+	}
+
+	return r.URLMap.OriginalURL
+}
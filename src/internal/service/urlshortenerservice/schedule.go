@@ -0,0 +1,124 @@
+package urlshortenerservice
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/db"
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/urlshortenererror"
+)
+
+// scheduleLayouts are the accepted formats of schedule times without an
+// explicit offset, read in the time zone of the schedule.
+var scheduleLayouts = []string{"2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02 15:04", "2006-01-02"}
+
+// checkActive returns an error for links whose schedule has not started yet.
+func checkActive(schedule *db.Schedule, now time.Time) error {
+	if schedule == nil || schedule.ActiveFrom == "" {
+		return nil
+	}
+
+	activeFrom, err := time.Parse(time.RFC3339, schedule.ActiveFrom)
+	if err != nil || !now.Before(activeFrom) {
+		return nil
+	}
+
+	location, err := time.LoadLocation(schedule.TimeZone)
+	if err != nil {
+		location = time.UTC
+	}
+
+	return urlshortenererror.Wrap(
+		nil,
+		"This link will be available from "+activeFrom.In(location).Format("Jan 2, 2006 15:04 MST"),
+		http.StatusNotFound,
+		urlshortenererror.ErrNotActive,
+	)
+}
+
+// matchSchedule returns the destination of the first time window containing now.
+// Windows include their start and exclude their end, and an empty bound is open.
+func matchSchedule(schedule *db.Schedule, now time.Time) (string, bool) {
+	if schedule == nil {
+		return "", false
+	}
+
+	for _, window := range schedule.Windows {
+		if start, err := time.Parse(time.RFC3339, window.Start); err == nil && now.Before(start) {
+			continue
+		}
+		if end, err := time.Parse(time.RFC3339, window.End); err == nil && !now.Before(end) {
+			continue
+		}
+
+		return window.Destination, true
+	}
+
+	return "", false
+}
+
+// normalizeSchedule validates the schedule and stores its times as RFC 3339
+// with the offset of the schedule's time zone.
+func normalizeSchedule(schedule db.Schedule) (*db.Schedule, error) {
+	timeZone := schedule.TimeZone
+	if timeZone == "" {
+		timeZone = "UTC"
+	}
+	location, err := time.LoadLocation(timeZone)
+	if err != nil {
+		return nil, invalidOption("Unknown time zone: " + timeZone)
+	}
+
+	normalized := &db.Schedule{
+		TimeZone: timeZone,
+		Windows:  make([]db.ScheduleWindow, 0, len(schedule.Windows)),
+	}
+
+	if normalized.ActiveFrom, err = normalizeScheduleTime(schedule.ActiveFrom, location); err != nil {
+		return nil, err
+	}
+
+	for _, window := range schedule.Windows {
+		if window.Start, err = normalizeScheduleTime(window.Start, location); err != nil {
+			return nil, err
+		}
+		if window.End, err = normalizeScheduleTime(window.End, location); err != nil {
+			return nil, err
+		}
+		if window.Start != "" && window.End != "" && !endsAfter(window.Start, window.End) {
+			return nil, invalidOption("Schedule windows must end after they start")
+		}
+		if window.Destination, err = normalizeDestination(window.Destination); err != nil {
+			return nil, err
+		}
+		normalized.Windows = append(normalized.Windows, window)
+	}
+
+	return normalized, nil
+}
+
+func normalizeScheduleTime(value string, location *time.Location) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+
+	if parsed, err := time.Parse(time.RFC3339, value); err == nil {
+		return parsed.In(location).Format(time.RFC3339), nil
+	}
+
+	for _, layout := range scheduleLayouts {
+		if parsed, err := time.ParseInLocation(layout, value, location); err == nil {
+			return parsed.Format(time.RFC3339), nil
+		}
+	}
+
+	return "", invalidOption("Invalid schedule time: " + value + ". Example: 2025-01-31T09:00")
+}
+
+// endsAfter compares two normalized schedule times.
+func endsAfter(start, end string) bool {
+	startTime, _ := time.Parse(time.RFC3339, start)
+	endTime, _ := time.Parse(time.RFC3339, end)
+
+	return endTime.After(startTime)
+}
@@ -0,0 +1,154 @@
+package urlshortenerservice
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/db"
+)
+
+// Limits of the suggestions offered for unknown short URLs.
+const (
+	MaxSuggestions = 3
+	// maxSuggestLength skips codes too long to be a mistyped short URL.
+	maxSuggestLength = 32
+	// maxConfusableVariants bounds the lookalike spellings tried per code.
+	maxConfusableVariants = 256
+)
+
+// confusables groups characters that are easily mistaken for each other.
+var confusables = []string{"0Oo", "1lI"}
+
+// SuggestShortURLs returns existing short URLs of the domain that the unknown
+// code was likely meant to be: codes that differ only in lookalike characters
+// such as 0 and O come first, then codes one edit away, most visited first.
+// Links a stranger should not stumble upon are never suggested.
+func (s URLShortenerService) SuggestShortURLs(ctx context.Context, domain, code string, now time.Time) ([]string, error) {
+	if code == "" || len(code) > maxSuggestLength {
+		return []string{}, nil
+	}
+
+	lookalikes := confusableVariants(code)
+	candidates := editsOf(code)
+	for candidate := range lookalikes {
+		candidates[candidate] = true
+	}
+	delete(candidates, code)
+
+	shortURLs := make([]string, 0, len(candidates))
+	for candidate := range candidates {
+		shortURLs = append(shortURLs, db.LinkKey(domain, candidate))
+	}
+
+	found, err := s.db.GetURLMaps(ctx, shortURLs)
+	if err != nil {
+		return nil, err
+	}
+
+	urlMaps := found[:0]
+	for _, urlMap := range found {
+		if isSuggestable(&urlMap, now) {
+			urlMaps = append(urlMaps, urlMap)
+		}
+	}
+
+	sort.Slice(urlMaps, func(i, j int) bool {
+		_, iCode := db.SplitLinkKey(urlMaps[i].ShortURL)
+		_, jCode := db.SplitLinkKey(urlMaps[j].ShortURL)
+		iLookalike, jLookalike := lookalikes[iCode], lookalikes[jCode]
+		if iLookalike != jLookalike {
+			return iLookalike
+		}
+		if urlMaps[i].Hits != urlMaps[j].Hits {
+			return urlMaps[i].Hits > urlMaps[j].Hits
+		}
+
+		return iCode < jCode
+	})
+
+	suggestions := make([]string, 0, MaxSuggestions)
+	for _, urlMap := range urlMaps[:min(len(urlMaps), MaxSuggestions)] {
+		_, suggestion := db.SplitLinkKey(urlMap.ShortURL)
+		suggestions = append(suggestions, suggestion)
+	}
+
+	return suggestions, nil
+}
+
+// isSuggestable reports whether the link may be offered to anyone who
+// mistypes a code. Password-protected and one-time links are private to
+// whoever was given them, and links outside of their schedule do not redirect.
+func isSuggestable(urlMap *db.URLMap, now time.Time) bool {
+	options := urlMap.Options
+
+	return options.PasswordHash == "" && !options.OneTime && urlMap.ConsumedAt == nil &&
+		checkActive(options.Schedule, now) == nil
+}
+
+// editsOf returns the codes one substitution, insertion, deletion or swap of
+// neighbouring characters away from the code.
+func editsOf(code string) map[string]bool {
+	edits := make(map[string]bool)
+
+	for i := range len(code) + 1 {
+		for _, c := range charset {
+			edits[code[:i]+string(c)+code[i:]] = true
+		}
+	}
+
+	for i := range len(code) {
+		edits[code[:i]+code[i+1:]] = true
+		for _, c := range charset {
+			edits[code[:i]+string(c)+code[i+1:]] = true
+		}
+		if i+1 < len(code) {
+			edits[code[:i]+string(code[i+1])+string(code[i])+code[i+2:]] = true
+		}
+	}
+
+	return edits
+}
+
+// confusableVariants returns the spellings of the code with any of its
+// lookalike characters swapped for another of the same group.
+func confusableVariants(code string) map[string]bool {
+	spellings := []string{code}
+
+	for i := range len(code) {
+		group := confusableGroup(code[i])
+		if group == "" {
+			continue
+		}
+
+		var next []string
+		for _, spelling := range spellings {
+			for _, c := range group {
+				if len(next) < maxConfusableVariants {
+					next = append(next, spelling[:i]+string(c)+spelling[i+1:])
+				}
+			}
+		}
+		spellings = next
+	}
+
+	variants := make(map[string]bool, len(spellings))
+	for _, spelling := range spellings {
+		if spelling != code {
+			variants[spelling] = true
+		}
+	}
+
+	return variants
+}
+
+func confusableGroup(c byte) string {
+	for _, group := range confusables {
+		if strings.IndexByte(group, c) >= 0 {
+			return group
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,50 @@
+package urlshortenerservice
+
+import (
+	"slices"
+
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/db"
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/useragent"
+)
+
+// matchTargeting returns the destination of the first rule matching the client.
+func matchTargeting(rules []db.TargetingRule, client useragent.UserAgent) (string, bool) {
+	for _, rule := range rules {
+		if (rule.OS == "" || rule.OS == client.OS) &&
+			(rule.Device == "" || rule.Device == client.Device) &&
+			(rule.Browser == "" || rule.Browser == client.Browser) {
+			return rule.Destination, true
+		}
+	}
+
+	return "", false
+}
+
+// normalizeTargeting validates the targeting rules and normalizes their destinations.
+func normalizeTargeting(rules []db.TargetingRule) ([]db.TargetingRule, error) {
+	normalized := make([]db.TargetingRule, 0, len(rules))
+
+	for _, rule := range rules {
+		if rule.OS == "" && rule.Device == "" && rule.Browser == "" {
+			return nil, invalidOption("Targeting rules must match at least one of os, device or browser")
+		}
+		if rule.OS != "" && !slices.Contains(useragent.OSes, rule.OS) {
+			return nil, invalidOption("Unknown targeting os: " + rule.OS)
+		}
+		if rule.Device != "" && !slices.Contains(useragent.Devices, rule.Device) {
+			return nil, invalidOption("Unknown targeting device: " + rule.Device)
+		}
+		if rule.Browser != "" && !slices.Contains(useragent.Browsers, rule.Browser) {
+			return nil, invalidOption("Unknown targeting browser: " + rule.Browser)
+		}
+
+		destination, err := normalizeDestination(rule.Destination)
+		if err != nil {
+			return nil, err
+		}
+		rule.Destination = destination
+		normalized = append(normalized, rule)
+	}
+
+	return normalized, nil
+}
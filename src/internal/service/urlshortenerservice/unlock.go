@@ -0,0 +1,197 @@
+package urlshortenerservice
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/db"
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/urlshortenererror"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Settings of password protected links.
+const (
+	MinPasswordLength   = 4
+	MaxPasswordLength   = 72 // bcrypt ignores anything longer
+	MaxUnlockAttempts   = 5
+	UnlockAttemptWindow = 15 * time.Minute
+	UnlockTokenTTL      = time.Hour
+)
+
+// HashPassword validates a link password and hashes it for storage.
+func HashPassword(password string) (string, error) {
+	if len(password) < MinPasswordLength || len(password) > MaxPasswordLength {
+		return "", invalidOption("Passwords must be between 4 and 72 characters")
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", urlshortenererror.Wrap(err, "failed to hash password", http.StatusInternalServerError, urlshortenererror.ErrServerError)
+	}
+
+	return string(hash), nil
+}
+
+// Unlock checks the password of a protected link of the domain and returns a
+// token proving it was entered, valid until the returned expiry. Attempts are
+// throttled per link and client.
+func (s URLShortenerService) Unlock(ctx context.Context, domain, shortURL, password, client string, now time.Time) (string, time.Time, error) {
+	shortURL = db.LinkKey(domain, shortURL)
+	key := shortURL + "|" + client
+	// The attempt is counted before the password is compared, so guesses sent
+	// in parallel cannot all pass the check before any of them failed.
+	if !s.unlockAttempts.reserve(key, now) {
+		return "", time.Time{}, urlshortenererror.Wrap(
+			nil,
+			"Too many incorrect passwords. Please try again later.",
+			http.StatusTooManyRequests,
+			urlshortenererror.ErrTooManyRequests,
+		)
+	}
+
+	urlMap, err := s.db.GetURLMap(ctx, shortURL)
+	if err != nil {
+		s.unlockAttempts.release(key)
+
+		return "", time.Time{}, err
+	}
+
+	if urlMap.Options.PasswordHash == "" {
+		s.unlockAttempts.release(key)
+
+		return "", time.Time{}, urlshortenererror.Wrap(nil, "This link is not password protected", http.StatusBadRequest, urlshortenererror.ErrInvalidInput)
+	}
+
+	if err = bcrypt.CompareHashAndPassword([]byte(urlMap.Options.PasswordHash), []byte(password)); err != nil {
+		if !errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			s.unlockAttempts.release(key)
+
+			return "", time.Time{}, urlshortenererror.Wrap(err, "failed to check password", http.StatusInternalServerError, urlshortenererror.ErrServerError)
+		}
+
+		return "", time.Time{}, urlshortenererror.Wrap(nil, "Incorrect password", http.StatusUnauthorized, urlshortenererror.ErrLocked)
+	}
+	s.unlockAttempts.reset(key)
+
+	expires := now.Add(UnlockTokenTTL)
+
+	return s.signUnlockToken(urlMap.ShortURL, db.PasswordFingerprint(urlMap.Options.PasswordHash), expires), expires, nil
+}
+
+// checkUnlocked returns an error for protected links unless the token is valid.
+func (s URLShortenerService) checkUnlocked(urlMap *db.URLMap, token string, now time.Time) error {
+	if urlMap.Options.PasswordHash == "" {
+		return nil
+	}
+
+	fingerprint := s.unlockedFingerprint(urlMap.ShortURL, token, now)
+	if fingerprint != "" && fingerprint == db.PasswordFingerprint(urlMap.Options.PasswordHash) {
+		return nil
+	}
+
+	return urlshortenererror.Wrap(nil, "This link is password protected", http.StatusUnauthorized, urlshortenererror.ErrLocked)
+}
+
+// unlockedFingerprint returns the password fingerprint carried by a valid
+// unlock token of the short URL, or an empty string. The database compares
+// it with the password of the link when the visit is counted.
+func (s URLShortenerService) unlockedFingerprint(shortURL, token string, now time.Time) string {
+	expiry, rest, _ := strings.Cut(token, ".")
+	fingerprint, _, found := strings.Cut(rest, ".")
+	expires, err := strconv.ParseInt(expiry, 10, 64)
+	if found && err == nil && now.Unix() < expires &&
+		hmac.Equal([]byte(token), []byte(s.signUnlockToken(shortURL, fingerprint, time.Unix(expires, 0)))) {
+		return fingerprint
+	}
+
+	return ""
+}
+
+// signUnlockToken binds the token to the link, the fingerprint of its
+// password and the expiry, so changing the password invalidates earlier tokens.
+func (s URLShortenerService) signUnlockToken(shortURL, fingerprint string, expires time.Time) string {
+	expiry := strconv.FormatInt(expires.Unix(), 10)
+
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(shortURL + "|" + expiry + "|" + fingerprint))
+
+	return expiry + "." + fingerprint + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// attemptLimiter counts attempts per key within a fixed window.
+type attemptLimiter struct {
+	attempts  map[string]*attempts
+	lastPrune time.Time
+	lock      sync.Mutex
+}
+
+type attempts struct {
+	windowStart time.Time
+	count       int
+}
+
+func newAttemptLimiter() *attemptLimiter {
+	return &attemptLimiter{attempts: make(map[string]*attempts)}
+}
+
+// reserve counts an attempt for the key, it reports false without counting
+// once the key used up its attempts in the current window.
+func (l *attemptLimiter) reserve(key string, now time.Time) bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.prune(now)
+
+	entry, ok := l.attempts[key]
+	if !ok || now.Sub(entry.windowStart) >= UnlockAttemptWindow {
+		entry = &attempts{windowStart: now}
+		l.attempts[key] = entry
+	}
+	if entry.count >= MaxUnlockAttempts {
+		return false
+	}
+	entry.count++
+
+	return true
+}
+
+// release gives back an attempt that could not check the password.
+func (l *attemptLimiter) release(key string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if entry, ok := l.attempts[key]; ok && entry.count > 0 {
+		entry.count--
+	}
+}
+
+// reset forgets the attempts of the key after a correct password.
+func (l *attemptLimiter) reset(key string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	delete(l.attempts, key)
+}
+
+// prune drops expired windows so the map does not grow without bound. It
+// scans the map at most once per window.
+func (l *attemptLimiter) prune(now time.Time) {
+	if now.Sub(l.lastPrune) < UnlockAttemptWindow {
+		return
+	}
+	l.lastPrune = now
+
+	for key, entry := range l.attempts {
+		if now.Sub(entry.windowStart) >= UnlockAttemptWindow {
+			delete(l.attempts, key)
+		}
+	}
+}
@@ -1,8 +1,11 @@
 package urlshortenerservice
 
 import (
+	"context"
+	cryptorand "crypto/rand"
+	"crypto/sha256"
 	"errors"
-	"log"
+	"log/slog"
 	"math/rand"
 	"net/http"
 	"net/url"
@@ -10,13 +13,19 @@ import (
 	"sync"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/config"
 	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/db"
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/logging"
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/metrics"
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/reserved"
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/tracing"
 	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/urlshortenererror"
 )
 
 const (
-	shortURLLength = 6 // Length of generated short URLs
-
 	httpsPrefix = "https://"
 
 	httpPrefix = "http://"
@@ -26,11 +35,85 @@ const (
 
 // URLShortenerService handles the business logic for URL shortening.
 type URLShortenerService struct {
-	db db.Database
+	db             db.Database
+	unlockAttempts *attemptLimiter
+	domains        *domainCache
+	interstitial   InterstitialPolicy
+	reserved       *reserved.Registry
+	metrics        *metrics.Metrics
+	logger         *slog.Logger
+	tracer         trace.Tracer
+	secret         []byte
+	fallbackAfter  int
+	codeLength     int
+}
+
+// Option type for functional options.
+type Option func(*URLShortenerService)
+
+// WithSecret sets the key signing the tokens of unlocked password protected links.
+func WithSecret(secret []byte) Option {
+	return func(s *URLShortenerService) {
+		s.secret = secret
+	}
+}
+
+// WithInterstitialPolicy sets which destinations are shown behind a warning page.
+func WithInterstitialPolicy(policy InterstitialPolicy) Option {
+	return func(s *URLShortenerService) {
+		s.interstitial = InterstitialPolicy{
+			Domains:         NormalizeDomains(policy.Domains),
+			InternalDomains: NormalizeDomains(policy.InternalDomains),
+			Unsafe:          policy.Unsafe,
+		}
+	}
+}
+
+// WithFallbackAfter sets the number of failed checks in a row after which
+// links redirect to their fallback.
+func WithFallbackAfter(failures int) Option {
+	return func(s *URLShortenerService) {
+		s.fallbackAfter = failures
+	}
+}
+
+// WithCodeLength sets the length of generated short URLs.
+func WithCodeLength(length int) Option {
+	return func(s *URLShortenerService) {
+		s.codeLength = length
+	}
+}
+
+// WithReserved sets the words that cannot be used as short codes.
+func WithReserved(registry *reserved.Registry) Option {
+	return func(s *URLShortenerService) {
+		s.reserved = registry
+	}
+}
+
+// WithMetrics sets the metrics counting short URL collisions and domain cache lookups.
+func WithMetrics(m *metrics.Metrics) Option {
+	return func(s *URLShortenerService) {
+		s.metrics = m
+	}
+}
+
+// WithLogger sets the logger of the service.
+func WithLogger(logger *slog.Logger) Option {
+	return func(s *URLShortenerService) {
+		s.logger = logger
+	}
+}
+
+// WithTracer sets the tracer recording the spans of the service.
+func WithTracer(tracer trace.Tracer) Option {
+	return func(s *URLShortenerService) {
+		s.tracer = tracer
+	}
 }
 
 // New creates a new URLShortenerService instance.
-func New(database db.Database) (*URLShortenerService, error) {
+func New(database db.Database, opts ...Option) (*URLShortenerService, error) {
 	if database == nil {
 		return nil, urlshortenererror.Wrap(
 
@@ -44,48 +127,79 @@ func New(database db.Database) (*URLShortenerService, error) {
 		)
 	}
 
-	return &URLShortenerService{db: database}, nil
-}
-
-// ShortenURL takes a URL and returns a shortened version.
-func (s URLShortenerService) ShortenURL(originalURL string) (string, error) {
-	if originalURL == "" {
-		return "", urlshortenererror.Wrap(
+	service := &URLShortenerService{
+		db:             database,
+		unlockAttempts: newAttemptLimiter(),
+		logger:         slog.Default(),
+		tracer:         tracing.NoopTracer(),
+		fallbackAfter:  config.DefaultHealthCheckFailures,
+		codeLength:     config.DefaultShortCodeLength,
+	}
+	for _, opt := range opts {
+		opt(service)
+	}
+	service.domains = newDomainCache(service.metrics)
 
-			nil,
+	if len(service.secret) == 0 {
+		// Without a configured secret, unlocked links must be unlocked again after a restart.
+		service.secret = make([]byte, sha256.Size)
+		if _, err := cryptorand.Read(service.secret); err != nil {
+			return nil, urlshortenererror.Wrap(err, "failed to generate secret", http.StatusInternalServerError, urlshortenererror.ErrServerError)
+		}
+	}
 
-			"URL cannot be empty",
+	return service, nil
+}
 
-			http.StatusBadRequest,
+// ShortenURL takes a URL and returns a shortened version in the namespace of
+// the domain, empty for the default namespace.
+func (s URLShortenerService) ShortenURL(ctx context.Context, domain, originalURL string) (_ string, err error) {
+	ctx, span := s.tracer.Start(ctx, "URLShortenerService.ShortenURL")
+	defer tracing.End(span, &err)
 
-			urlshortenererror.ErrInvalidInput,
-		)
-	}
+	return s.shortenURL(ctx, domain, originalURL, db.LinkOptions{})
+}
 
-	// Normalize URL
+// ShortenURLWithOptions takes a URL and per-link settings and returns a shortened version.
+// Links with settings always get a new short URL.
+func (s URLShortenerService) ShortenURLWithOptions(ctx context.Context, domain, originalURL string, options db.LinkOptions) (_ string, err error) {
+	ctx, span := s.tracer.Start(ctx, "URLShortenerService.ShortenURLWithOptions")
+	defer tracing.End(span, &err)
 
-	originalURL = normalizeURL(originalURL)
+	return s.shortenURL(ctx, domain, originalURL, options)
+}
 
-	// Validate URL
+func (s URLShortenerService) shortenURL(ctx context.Context, domain, originalURL string, options db.LinkOptions) (string, error) {
+	originalURL, err := normalizeDestination(originalURL)
+	if err != nil {
+		return "", err
+	}
 
-	if err := validateURL(originalURL); err != nil {
+	if options, err = normalizeLinkOptions(options); err != nil {
 		return "", err
 	}
 
 	// Generate short URL with collision handling
 
-	return s.generateUniqueShortURL(originalURL)
+	return s.generateUniqueShortURL(ctx, domain, originalURL, options)
 }
 
-func (s URLShortenerService) generateUniqueShortURL(originalURL string) (string, error) {
+func (s URLShortenerService) generateUniqueShortURL(ctx context.Context, domain, originalURL string, options db.LinkOptions) (string, error) {
 	var result string
 
 	var err error
 
 	for {
-		shortURL := generateHash()
+		shortURL := generateHash(s.codeLength)
+		if s.reserved.IsReserved(shortURL) {
+			continue
+		}
 
-		result, err = s.db.StoreURLs(shortURL, originalURL)
+		if options.IsZero() {
+			result, err = s.db.StoreURLs(ctx, db.LinkKey(domain, shortURL), originalURL)
+		} else {
+			result, err = s.db.StoreURLWithOptions(ctx, db.LinkKey(domain, shortURL), originalURL, options)
+		}
 
 		if err == nil {
 			break
@@ -101,23 +215,28 @@ func (s URLShortenerService) generateUniqueShortURL(originalURL string) (string,
 			return "", webErr
 		}
 
-		log.Printf("Collision detected! Short URL %s already exists for original URL %s, trying again...", shortURL, originalURL)
+		s.metrics.CollisionRetry()
+		trace.SpanFromContext(ctx).SetAttributes(attribute.Bool("collision", true))
+		s.logger.WarnContext(ctx, "Short URL collision, trying again", "short_url", shortURL, logging.URL("original_url", originalURL))
 	}
 
-	return result, nil
+	// An existing link of the namespace may be returned for the same URL.
+	_, code := db.SplitLinkKey(result)
+
+	return code, nil
 }
 
 var rng = rand.New(rand.NewSource(time.Now().UnixNano()))
 
 var lock sync.Mutex
 
-// generateHash creates a hash of the original URL with an attempt counter.
-func generateHash() string {
+// generateHash creates a random short URL of the given length.
+func generateHash(length int) string {
 	lock.Lock()
 
 	defer lock.Unlock()
 
-	result := make([]byte, shortURLLength)
+	result := make([]byte, length)
 
 	for i := range result {
 		result[i] = charset[rng.Intn(len(charset))]
@@ -126,6 +245,34 @@ func generateHash() string {
 	return string(result)
 }
 
+// normalizeDestination checks that a destination is set, then normalizes and validates it.
+func normalizeDestination(originalURL string) (string, error) {
+	if originalURL == "" {
+		return "", urlshortenererror.Wrap(
+
+			nil,
+
+			"URL cannot be empty",
+
+			http.StatusBadRequest,
+
+			urlshortenererror.ErrInvalidInput,
+		)
+	}
+
+	// Normalize URL
+
+	originalURL = normalizeURL(originalURL)
+
+	// Validate URL
+
+	if err := validateURL(originalURL); err != nil {
+		return "", err
+	}
+
+	return originalURL, nil
+}
+
 // normalizeURL ensures the URL has a proper protocol prefix.
 func normalizeURL(urlStr string) string {
 	if !strings.HasPrefix(urlStr, httpPrefix) && !strings.HasPrefix(urlStr, httpsPrefix) {
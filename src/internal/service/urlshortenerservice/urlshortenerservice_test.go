@@ -1,28 +1,134 @@
 package urlshortenerservice_test
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/config"
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/db"
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/metrics"
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/reserved"
 	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/service/urlshortenerservice"
 	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/urlshortenererror"
 )
 
 // MockDB implements the Database interface for testing
 type MockDB struct {
-	storeURLsFunc func(shortURL, originalURL string) (string, error)
-	getURLFunc    func(shortURL string) (string, error)
+	storeURLsFunc           func(shortURL, originalURL string) (string, error)
+	storeURLWithOptionsFunc func(shortURL, originalURL string, options db.LinkOptions) (string, error)
+	getURLFunc              func(shortURL string) (string, error)
+	visitedFunc             func(shortURL string)
+	countVariantHitFunc     func(shortURL, variant string) error
+	getURLMapFunc           func(shortURL string) (*db.URLMap, error)
+	getURLMapsFunc          func(shortURLs []string) ([]db.URLMap, error)
+	getVariantHitsFunc      func(shortURLs []string) (map[string]map[string]int64, error)
+	getLinkChecksFunc       func(shortURL string) ([]db.LinkCheck, error)
+	getDomainsFunc          func(accountID string) ([]db.Domain, error)
+	storeDomainFunc         func(domain db.Domain) (*db.Domain, error)
 }
 
-func (m *MockDB) StoreURLs(shortURL, originalURL string) (string, error) {
+func (m *MockDB) StoreURLs(_ context.Context, shortURL, originalURL string) (string, error) {
 	return m.storeURLsFunc(shortURL, originalURL)
 }
 
-func (m *MockDB) GetOriginalURL(shortURL string) (string, error) {
+func (m *MockDB) StoreURLWithOptions(_ context.Context, shortURL, originalURL string, options db.LinkOptions) (string, error) {
+	return m.storeURLWithOptionsFunc(shortURL, originalURL, options)
+}
+
+func (m *MockDB) GetOriginalURL(_ context.Context, shortURL string) (string, error) {
 	return m.getURLFunc(shortURL)
 }
 
+// VisitURL applies the guard of the visit query to the URL map of getURLMapFunc.
+func (m *MockDB) VisitURL(_ context.Context, shortURL string, guard db.VisitGuard) (*db.URLMap, bool, error) {
+	urlMap, err := m.getURLMapFunc(shortURL)
+	if err != nil {
+		return nil, false, err
+	}
+
+	options := urlMap.Options
+	notActive := false
+	if options.Schedule != nil {
+		activeFrom, parseErr := time.Parse(time.RFC3339, options.Schedule.ActiveFrom)
+		notActive = parseErr == nil && guard.Now.Before(activeFrom)
+	}
+	locked := options.PasswordHash != "" && guard.PasswordFingerprint != db.PasswordFingerprint(options.PasswordHash)
+	extraPath := guard.ExtraPath && (options.Passthrough == nil || !options.Passthrough.Path)
+	if urlMap.ConsumedAt != nil || notActive || locked || extraPath {
+		return urlMap, false, nil
+	}
+
+	if m.visitedFunc != nil {
+		m.visitedFunc(shortURL)
+	}
+
+	return urlMap, true, nil
+}
+
+func (m *MockDB) CountVariantHit(_ context.Context, shortURL, variant string) error {
+	if m.countVariantHitFunc == nil {
+		return nil
+	}
+
+	return m.countVariantHitFunc(shortURL, variant)
+}
+
+func (m *MockDB) GetURLMap(_ context.Context, shortURL string) (*db.URLMap, error) {
+	return m.getURLMapFunc(shortURL)
+}
+
+func (m *MockDB) GetURLMaps(_ context.Context, shortURLs []string) ([]db.URLMap, error) {
+	return m.getURLMapsFunc(shortURLs)
+}
+
+func (m *MockDB) GetVariantHits(_ context.Context, shortURLs []string) (map[string]map[string]int64, error) {
+	return m.getVariantHitsFunc(shortURLs)
+}
+
+func (m *MockDB) GetLinksToCheck(_ context.Context, _ time.Time, _ int) ([]db.URLMap, error) {
+	return nil, nil
+}
+
+func (m *MockDB) RecordLinkCheck(_ context.Context, _ db.LinkCheck) error {
+	return nil
+}
+
+func (m *MockDB) GetLinkChecks(_ context.Context, shortURL string) ([]db.LinkCheck, error) {
+	if m.getLinkChecksFunc == nil {
+		return []db.LinkCheck{}, nil
+	}
+
+	return m.getLinkChecksFunc(shortURL)
+}
+
+func (m *MockDB) GetDomains(_ context.Context, accountID string) ([]db.Domain, error) {
+	if m.getDomainsFunc == nil {
+		return []db.Domain{}, nil
+	}
+
+	return m.getDomainsFunc(accountID)
+}
+
+func (m *MockDB) StoreDomain(_ context.Context, domain db.Domain) (*db.Domain, error) {
+	return m.storeDomainFunc(domain)
+}
+
+func (m *MockDB) DeleteDomain(_ context.Context, _, _ string) error {
+	return nil
+}
+
 func (m *MockDB) Close() {}
 
 func TestNew_Success(t *testing.T) {
@@ -51,7 +157,7 @@ func TestShortenURL_Success(t *testing.T) {
 	}
 
 	service, _ := urlshortenerservice.New(mockDB)
-	result, err := service.ShortenURL(originalURL)
+	result, err := service.ShortenURL(context.Background(), "", originalURL)
 
 	if err != nil {
 		t.Errorf("Expected no error, got %v", err)
@@ -61,12 +167,37 @@ func TestShortenURL_Success(t *testing.T) {
 	}
 }
 
+func TestShortenURL_CodeLength(t *testing.T) {
+	for _, length := range []int{0, 10} {
+		want := length
+		opts := []urlshortenerservice.Option{urlshortenerservice.WithCodeLength(length)}
+		if length == 0 {
+			want, opts = config.DefaultShortCodeLength, nil
+		}
+
+		mockDB := &MockDB{
+			storeURLsFunc: func(shortURL, _ string) (string, error) {
+				return shortURL, nil
+			},
+		}
+
+		service, _ := urlshortenerservice.New(mockDB, opts...)
+		result, err := service.ShortenURL(context.Background(), "", "https://example.org")
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if len(result) != want {
+			t.Errorf("Expected a short URL of %d characters, got %q", want, result)
+		}
+	}
+}
+
 func TestShortenURL_InvalidURLFormat(t *testing.T) {
 	mockDB := &MockDB{}
 	service, _ := urlshortenerservice.New(mockDB)
 
 	invalidURL := "://example"
-	_, err := service.ShortenURL(invalidURL)
+	_, err := service.ShortenURL(context.Background(), "", invalidURL)
 
 	if err == nil {
 		t.Error("Expected error for invalid URL, got nil")
@@ -108,7 +239,7 @@ func TestShortenURL_DuplicateShortURL(t *testing.T) {
 	}
 
 	service, _ := urlshortenerservice.New(mockDB)
-	result, err := service.ShortenURL(originalURL)
+	result, err := service.ShortenURL(context.Background(), "", originalURL)
 
 	if err != nil {
 		t.Errorf("Expected no error, got %v", err)
@@ -157,7 +288,7 @@ func TestShortenURL_RandomCollision(t *testing.T) {
 
 	service, _ := urlshortenerservice.New(mockDB)
 
-	result, err := service.ShortenURL(originalURL)
+	result, err := service.ShortenURL(context.Background(), "", originalURL)
 	if err != nil {
 		t.Errorf("Expected no error, got %v", err)
 	}
@@ -168,3 +299,1296 @@ func TestShortenURL_RandomCollision(t *testing.T) {
 		t.Errorf("Expected final unique key %s, got %s", uniqueKey, result)
 	}
 }
+
+func TestGetLinkInfo_Success(t *testing.T) {
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	mockDB := &MockDB{
+		getURLMapFunc: func(shortURL string) (*db.URLMap, error) {
+			return &db.URLMap{
+				CreatedAt:   createdAt,
+				ShortURL:    shortURL,
+				OriginalURL: "https://example.org",
+				Hits:        42,
+			}, nil
+		},
+	}
+
+	service, _ := urlshortenerservice.New(mockDB)
+	info, err := service.GetLinkInfo(context.Background(), "", "abc123", time.Now())
+
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if info.OriginalURL != "https://example.org" || info.Hits != 42 || !info.CreatedAt.Equal(createdAt) {
+		t.Errorf("Unexpected link info %+v", info)
+	}
+	if info.Safety.Status != urlshortenerservice.SafetyStatusSafe {
+		t.Errorf("Expected safety status %s, got %s", urlshortenerservice.SafetyStatusSafe, info.Safety.Status)
+	}
+}
+
+func TestGetLinkInfo_NotFound(t *testing.T) {
+	mockDB := &MockDB{
+		getURLMapFunc: func(_ string) (*db.URLMap, error) {
+			return nil, urlshortenererror.Wrap(nil, "URL not found", http.StatusNotFound, urlshortenererror.ErrNotFound)
+		},
+	}
+
+	service, _ := urlshortenerservice.New(mockDB)
+	_, err := service.GetLinkInfo(context.Background(), "", "missing", time.Now())
+
+	var webErr *urlshortenererror.WebError
+	if !errors.As(err, &webErr) || webErr.Code != http.StatusNotFound {
+		t.Errorf("Expected not found WebError, got %v", err)
+	}
+}
+
+func TestCheckSafety(t *testing.T) {
+	tests := []struct {
+		name           string
+		url            string
+		expectedStatus string
+	}{
+		{name: "HTTPS domain", url: "https://example.org/path", expectedStatus: urlshortenerservice.SafetyStatusSafe},
+		{name: "Plain HTTP", url: "http://example.org", expectedStatus: urlshortenerservice.SafetyStatusWarning},
+		{name: "Credentials", url: "https://example.org@evil.com", expectedStatus: urlshortenerservice.SafetyStatusWarning},
+		{name: "IP address", url: "https://192.168.0.1", expectedStatus: urlshortenerservice.SafetyStatusWarning},
+		{name: "Punycode", url: "https://xn--pple-43d.com", expectedStatus: urlshortenerservice.SafetyStatusWarning},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			safety := urlshortenerservice.CheckSafety(tt.url)
+			if safety.Status != tt.expectedStatus {
+				t.Errorf("Expected status %s, got %s (%v)", tt.expectedStatus, safety.Status, safety.Warnings)
+			}
+		})
+	}
+}
+
+func TestExpandURLs_Success(t *testing.T) {
+	mockDB := &MockDB{
+		getURLMapsFunc: func(shortURLs []string) ([]db.URLMap, error) {
+			if len(shortURLs) != 3 {
+				t.Errorf("Expected 3 unique short URLs, got %v", shortURLs)
+			}
+			return []db.URLMap{
+				{ShortURL: "def456", OriginalURL: "https://example.com", Hits: 2},
+				{ShortURL: "abc123", OriginalURL: "https://example.org", Hits: 1},
+			}, nil
+		},
+	}
+
+	service, _ := urlshortenerservice.New(mockDB)
+	result, err := service.ExpandURLs(context.Background(), "", []string{"abc123", "missing", "abc123", "def456"}, time.Now())
+
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(result.Links) != 2 || result.Links[0].ShortURL != "abc123" || result.Links[1].ShortURL != "def456" {
+		t.Errorf("Expected links in request order, got %+v", result.Links)
+	}
+	if len(result.NotFound) != 1 || result.NotFound[0] != "missing" {
+		t.Errorf("Expected missing short URL to be reported, got %v", result.NotFound)
+	}
+}
+
+func TestExpandURLs_InvalidInput(t *testing.T) {
+	service, _ := urlshortenerservice.New(&MockDB{})
+
+	tooMany := make([]string, urlshortenerservice.MaxExpandURLs+1)
+	for i := range tooMany {
+		tooMany[i] = fmt.Sprintf("code%d", i)
+	}
+
+	for _, shortURLs := range [][]string{nil, {""}, tooMany} {
+		_, err := service.ExpandURLs(context.Background(), "", shortURLs, time.Now())
+
+		var webErr *urlshortenererror.WebError
+		if !errors.As(err, &webErr) || webErr.Code != http.StatusBadRequest {
+			t.Errorf("Expected bad request WebError for %d short URLs, got %v", len(shortURLs), err)
+		}
+	}
+}
+
+func TestShortenURLWithOptions_Success(t *testing.T) {
+	options := db.LinkOptions{RedirectCode: http.StatusFound, CacheControl: "private, max-age=60"}
+
+	mockDB := &MockDB{
+		storeURLsFunc: func(_, _ string) (string, error) {
+			t.Error("Expected links with options to skip StoreURLs")
+			return "", nil
+		},
+		storeURLWithOptionsFunc: func(shortURL, _ string, opts db.LinkOptions) (string, error) {
+			if !reflect.DeepEqual(opts, options) {
+				t.Errorf("Expected options %+v, got %+v", options, opts)
+			}
+			return shortURL, nil
+		},
+	}
+
+	service, _ := urlshortenerservice.New(mockDB)
+	if _, err := service.ShortenURLWithOptions(context.Background(), "", "https://example.org", options); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+}
+
+func TestShortenURLWithOptions_InvalidOptions(t *testing.T) {
+	service, _ := urlshortenerservice.New(&MockDB{})
+
+	for _, options := range []db.LinkOptions{
+		{RedirectCode: http.StatusOK},
+		{CacheControl: "max-age"},
+		{CacheControl: "max-age=-1"},
+		{CacheControl: "no-store, bogus"},
+		{Languages: map[string]string{"pt-BR": "https://example.org/br", "pt-br": "https://example.org/br"}},
+		{Schedule: &db.Schedule{TimeZone: "Mars/Olympus_Mons"}},
+		{Schedule: &db.Schedule{ActiveFrom: "next tuesday"}},
+		{Schedule: &db.Schedule{Windows: []db.ScheduleWindow{
+			{Start: "2025-03-10", End: "2025-03-01", Destination: "https://example.org"},
+		}}},
+	} {
+		_, err := service.ShortenURLWithOptions(context.Background(), "", "https://example.org", options)
+
+		var webErr *urlshortenererror.WebError
+		if !errors.As(err, &webErr) || webErr.Code != http.StatusBadRequest {
+			t.Errorf("Expected bad request WebError for %+v, got %v", options, err)
+		}
+	}
+}
+
+func TestRedirect_Passthrough(t *testing.T) {
+	tests := []struct {
+		passthrough      *db.Passthrough
+		name             string
+		destination      string
+		extraPath        string
+		query            string
+		expectedLocation string
+		expectedError    bool
+	}{
+		{
+			name:             "No passthrough drops the query",
+			query:            "ref=x",
+			expectedLocation: "https://example.org/base?utm=a",
+		},
+		{
+			name:          "No passthrough rejects extra path",
+			extraPath:     "docs/page",
+			expectedError: true,
+		},
+		{
+			name:             "Path passthrough",
+			passthrough:      &db.Passthrough{Path: true},
+			extraPath:        "docs/page",
+			expectedLocation: "https://example.org/base/docs/page?utm=a",
+		},
+		{
+			name:             "Query passthrough keeps destination values",
+			passthrough:      &db.Passthrough{Query: true},
+			query:            "utm=b&ref=x",
+			expectedLocation: "https://example.org/base?utm=a&ref=x",
+		},
+		{
+			name:             "Query passthrough overrides destination values",
+			passthrough:      &db.Passthrough{Query: true, QueryConflict: db.QueryConflictOverride},
+			query:            "utm=b",
+			expectedLocation: "https://example.org/base?utm=b",
+		},
+		{
+			name:             "Query passthrough appends values",
+			passthrough:      &db.Passthrough{Path: true, Query: true, QueryConflict: db.QueryConflictAppend},
+			extraPath:        "docs",
+			query:            "utm=b",
+			expectedLocation: "https://example.org/base/docs?utm=a&utm=b",
+		},
+		{
+			name:             "Query passthrough leaves a kept destination query as written",
+			passthrough:      &db.Passthrough{Query: true},
+			destination:      "https://example.org/base?z=1&a=%7E&utm=a",
+			query:            "utm=b",
+			expectedLocation: "https://example.org/base?z=1&a=%7E&utm=a",
+		},
+		{
+			name:             "Query passthrough only appends new parameters",
+			passthrough:      &db.Passthrough{Query: true, QueryConflict: db.QueryConflictOverride},
+			destination:      "https://example.org/base?z=1&a=%7E&utm=a",
+			query:            "utm=b&ref=x",
+			expectedLocation: "https://example.org/base?z=1&a=%7E&ref=x&utm=b",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			destination := tt.destination
+			if destination == "" {
+				destination = "https://example.org/base?utm=a"
+			}
+			visited := false
+			mockDB := &MockDB{
+				getURLMapFunc: func(shortURL string) (*db.URLMap, error) {
+					return &db.URLMap{
+						ShortURL:    shortURL,
+						OriginalURL: destination,
+						Options:     db.LinkOptions{Passthrough: tt.passthrough},
+					}, nil
+				},
+				visitedFunc: func(_ string) {
+					visited = true
+				},
+			}
+
+			query, _ := url.ParseQuery(tt.query)
+			service, _ := urlshortenerservice.New(mockDB)
+			redirect, err := service.Redirect(context.Background(), urlshortenerservice.RedirectRequest{
+				Query:     query,
+				ShortURL:  "abc123",
+				ExtraPath: tt.extraPath,
+			})
+
+			if tt.expectedError {
+				if err == nil {
+					t.Error("Expected error but got none")
+				}
+				if visited {
+					t.Error("Expected no hit to be counted")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if redirect.Location != tt.expectedLocation {
+				t.Errorf("Expected location %s, got %s", tt.expectedLocation, redirect.Location)
+			}
+			if !visited {
+				t.Error("Expected the hit to be counted")
+			}
+		})
+	}
+}
+
+func TestRedirect_Targeting(t *testing.T) {
+	options := db.LinkOptions{Targeting: []db.TargetingRule{
+		{OS: "ios", Destination: "https://apps.apple.com/app/id1"},
+		{OS: "android", Device: "mobile", Destination: "https://play.google.com/store/apps/details?id=app"},
+	}}
+
+	mockDB := &MockDB{
+		getURLMapFunc: func(shortURL string) (*db.URLMap, error) {
+			return &db.URLMap{ShortURL: shortURL, OriginalURL: "https://example.org", Options: options}, nil
+		},
+	}
+	service, _ := urlshortenerservice.New(mockDB)
+
+	tests := []struct {
+		userAgent        string
+		expectedLocation string
+	}{
+		{
+			userAgent:        "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) Mobile/15E148 Safari/604.1",
+			expectedLocation: "https://apps.apple.com/app/id1",
+		},
+		{
+			userAgent:        "Mozilla/5.0 (Linux; Android 13; Pixel 7) Chrome/118.0.0.0 Mobile Safari/537.36",
+			expectedLocation: "https://play.google.com/store/apps/details?id=app",
+		},
+		{
+			userAgent:        "Mozilla/5.0 (Linux; Android 13; SM-X700) Chrome/118.0.0.0 Safari/537.36",
+			expectedLocation: "https://example.org",
+		},
+		{
+			userAgent:        "Mozilla/5.0 (Windows NT 10.0; Win64; x64) Chrome/118.0.0.0 Safari/537.36",
+			expectedLocation: "https://example.org",
+		},
+	}
+
+	for _, tt := range tests {
+		redirect, err := service.Redirect(context.Background(), urlshortenerservice.RedirectRequest{ShortURL: "abc123", UserAgent: tt.userAgent})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if redirect.Location != tt.expectedLocation {
+			t.Errorf("Expected location %s for %q, got %s", tt.expectedLocation, tt.userAgent, redirect.Location)
+		}
+		if len(redirect.Vary) != 1 || redirect.Vary[0] != "User-Agent" {
+			t.Errorf("Expected Vary: User-Agent, got %v", redirect.Vary)
+		}
+	}
+}
+
+func TestShortenURLWithOptions_InvalidTargeting(t *testing.T) {
+	service, _ := urlshortenerservice.New(&MockDB{})
+
+	for _, rule := range []db.TargetingRule{
+		{Destination: "https://example.com"},
+		{OS: "beos", Destination: "https://example.com"},
+		{Device: "fridge", Destination: "https://example.com"},
+		{OS: "ios", Destination: "not a url"},
+	} {
+		_, err := service.ShortenURLWithOptions(context.Background(), "", "https://example.org", db.LinkOptions{Targeting: []db.TargetingRule{rule}})
+
+		var webErr *urlshortenererror.WebError
+		if !errors.As(err, &webErr) || webErr.Code != http.StatusBadRequest {
+			t.Errorf("Expected bad request WebError for %+v, got %v", rule, err)
+		}
+	}
+}
+
+func TestShortenURLWithOptions_LanguagesLowerCased(t *testing.T) {
+	mockDB := &MockDB{
+		storeURLWithOptionsFunc: func(shortURL, _ string, opts db.LinkOptions) (string, error) {
+			expected := map[string]string{"pt-br": "https://example.org/br", "de": "https://example.org/de"}
+			if !reflect.DeepEqual(opts.Languages, expected) {
+				t.Errorf("Expected languages %v, got %v", expected, opts.Languages)
+			}
+			return shortURL, nil
+		},
+	}
+
+	service, _ := urlshortenerservice.New(mockDB)
+	options := db.LinkOptions{Languages: map[string]string{"pt-BR": "https://example.org/br", "DE": "https://example.org/de"}}
+	if _, err := service.ShortenURLWithOptions(context.Background(), "", "https://example.org", options); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+}
+
+func TestRedirect_Languages(t *testing.T) {
+	options := db.LinkOptions{Languages: map[string]string{
+		"de":    "https://example.org/de",
+		"pt-BR": "https://example.org/pt-br",
+	}}
+
+	mockDB := &MockDB{
+		getURLMapFunc: func(shortURL string) (*db.URLMap, error) {
+			return &db.URLMap{ShortURL: shortURL, OriginalURL: "https://example.org/en", Options: options}, nil
+		},
+	}
+	service, _ := urlshortenerservice.New(mockDB)
+
+	tests := []struct {
+		acceptLanguage   string
+		expectedLocation string
+	}{
+		{acceptLanguage: "de-AT, en;q=0.8", expectedLocation: "https://example.org/de"},
+		{acceptLanguage: "fr, pt;q=0.5", expectedLocation: "https://example.org/pt-br"},
+		{acceptLanguage: "fr, de;q=0", expectedLocation: "https://example.org/en"},
+		{acceptLanguage: "fr, *;q=0.9, de;q=0.5", expectedLocation: "https://example.org/en"},
+		{acceptLanguage: "PT-br;level=1;q=0.9", expectedLocation: "https://example.org/pt-br"},
+		{acceptLanguage: "", expectedLocation: "https://example.org/en"},
+	}
+
+	for _, tt := range tests {
+		redirect, err := service.Redirect(context.Background(), urlshortenerservice.RedirectRequest{ShortURL: "abc123", AcceptLanguage: tt.acceptLanguage})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if redirect.Location != tt.expectedLocation {
+			t.Errorf("Expected location %s for %q, got %s", tt.expectedLocation, tt.acceptLanguage, redirect.Location)
+		}
+	}
+}
+
+func TestRedirect_Variants(t *testing.T) {
+	options := db.LinkOptions{Variants: []db.Variant{
+		{Name: "a", Destination: "https://example.org/a", Weight: 3},
+		{Name: "b", Destination: "https://example.org/b", Weight: 1},
+	}}
+
+	visits := map[string]int{}
+	mockDB := &MockDB{
+		getURLMapFunc: func(shortURL string) (*db.URLMap, error) {
+			return &db.URLMap{ShortURL: shortURL, OriginalURL: "https://example.org/a", Options: options}, nil
+		},
+		countVariantHitFunc: func(_, variant string) error {
+			visits[variant]++
+			return nil
+		},
+	}
+	service, _ := urlshortenerservice.New(mockDB)
+
+	// A visitor with a cookie stays on their variant.
+	for range 20 {
+		redirect, err := service.Redirect(context.Background(), urlshortenerservice.RedirectRequest{ShortURL: "abc123", Variant: "b"})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if redirect.Variant != "b" || redirect.Location != "https://example.org/b" {
+			t.Errorf("Expected sticky variant b, got %s (%s)", redirect.Variant, redirect.Location)
+		}
+	}
+
+	// New visitors and unknown variants are split by weight.
+	visits = map[string]int{}
+	for range 400 {
+		redirect, err := service.Redirect(context.Background(), urlshortenerservice.RedirectRequest{ShortURL: "abc123", Variant: "gone"})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if redirect.Location != "https://example.org/"+redirect.Variant {
+			t.Errorf("Expected location of variant %s, got %s", redirect.Variant, redirect.Location)
+		}
+	}
+	if visits["a"] <= visits["b"] || visits["b"] == 0 {
+		t.Errorf("Expected a 3:1 split between a and b, got %v", visits)
+	}
+}
+
+func TestGetLinkInfo_Variants(t *testing.T) {
+	mockDB := &MockDB{
+		getURLMapFunc: func(shortURL string) (*db.URLMap, error) {
+			return &db.URLMap{ShortURL: shortURL, OriginalURL: "https://example.org/a", Hits: 5, Options: db.LinkOptions{
+				Variants: []db.Variant{
+					{Name: "a", Destination: "https://example.org/a", Weight: 1},
+					{Name: "b", Destination: "https://example.org/b", Weight: 1},
+				},
+			}}, nil
+		},
+		getVariantHitsFunc: func(_ []string) (map[string]map[string]int64, error) {
+			return map[string]map[string]int64{"abc123": {"a": 2, "b": 3}}, nil
+		},
+	}
+
+	service, _ := urlshortenerservice.New(mockDB)
+	info, err := service.GetLinkInfo(context.Background(), "", "abc123", time.Now())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(info.Variants) != 2 || info.Variants[0].Hits != 2 || info.Variants[1].Hits != 3 {
+		t.Errorf("Expected variant hits 2 and 3, got %+v", info.Variants)
+	}
+}
+
+func TestRedirect_Schedule(t *testing.T) {
+	var options db.LinkOptions
+	storeDB := &MockDB{
+		storeURLWithOptionsFunc: func(shortURL, _ string, opts db.LinkOptions) (string, error) {
+			options = opts
+			return shortURL, nil
+		},
+	}
+
+	service, _ := urlshortenerservice.New(storeDB)
+	_, err := service.ShortenURLWithOptions(context.Background(), "", "https://example.org/product", db.LinkOptions{Schedule: &db.Schedule{
+		TimeZone:   "Europe/Berlin",
+		ActiveFrom: "2025-03-01T00:00",
+		Windows: []db.ScheduleWindow{
+			{End: "2025-03-10T09:00", Destination: "https://example.org/teaser"},
+			{Start: "2025-03-20", Destination: "https://example.org/sale-ended"},
+		},
+	}})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if options.Schedule.ActiveFrom != "2025-03-01T00:00:00+01:00" {
+		t.Errorf("Expected activation time in Berlin time, got %s", options.Schedule.ActiveFrom)
+	}
+
+	mockDB := &MockDB{
+		getURLMapFunc: func(shortURL string) (*db.URLMap, error) {
+			return &db.URLMap{ShortURL: shortURL, OriginalURL: "https://example.org/product", Options: options}, nil
+		},
+	}
+	service, _ = urlshortenerservice.New(mockDB)
+
+	tests := []struct {
+		now              string
+		expectedLocation string
+	}{
+		{now: "2025-02-28T23:30:00Z", expectedLocation: "https://example.org/teaser"},
+		{now: "2025-03-10T07:59:00Z", expectedLocation: "https://example.org/teaser"},
+		{now: "2025-03-10T08:00:00Z", expectedLocation: "https://example.org/product"},
+		{now: "2025-03-25T12:00:00Z", expectedLocation: "https://example.org/sale-ended"},
+	}
+
+	for _, tt := range tests {
+		now, _ := time.Parse(time.RFC3339, tt.now)
+		redirect, redirectErr := service.Redirect(context.Background(), urlshortenerservice.RedirectRequest{ShortURL: "abc123", Now: now})
+		if redirectErr != nil {
+			t.Fatalf("Expected no error at %s, got %v", tt.now, redirectErr)
+		}
+		if redirect.Location != tt.expectedLocation {
+			t.Errorf("Expected location %s at %s, got %s", tt.expectedLocation, tt.now, redirect.Location)
+		}
+		if !redirect.Temporary {
+			t.Error("Expected scheduled redirects to be temporary")
+		}
+	}
+
+	before, _ := time.Parse(time.RFC3339, "2025-02-28T22:59:00Z")
+	_, err = service.Redirect(context.Background(), urlshortenerservice.RedirectRequest{ShortURL: "abc123", Now: before})
+
+	var webErr *urlshortenererror.WebError
+	if !errors.As(err, &webErr) || !errors.Is(webErr.ErrType, urlshortenererror.ErrNotActive) {
+		t.Errorf("Expected not active error before activation, got %v", err)
+	}
+}
+
+func TestHashPassword_InvalidLength(t *testing.T) {
+	for _, password := range []string{"abc", string(make([]byte, 73))} {
+		_, err := urlshortenerservice.HashPassword(password)
+
+		var webErr *urlshortenererror.WebError
+		if !errors.As(err, &webErr) || webErr.Code != http.StatusBadRequest {
+			t.Errorf("Expected bad request for a password of %d characters, got %v", len(password), err)
+		}
+	}
+}
+
+func TestRedirect_Password(t *testing.T) {
+	hash, err := urlshortenerservice.HashPassword("s3cret")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	visits := 0
+	mockDB := &MockDB{
+		getURLMapFunc: func(shortURL string) (*db.URLMap, error) {
+			return &db.URLMap{
+				ShortURL:    shortURL,
+				OriginalURL: "https://example.org/private",
+				Options:     db.LinkOptions{PasswordHash: hash},
+			}, nil
+		},
+		visitedFunc: func(_ string) {
+			visits++
+		},
+	}
+	service, _ := urlshortenerservice.New(mockDB, urlshortenerservice.WithSecret([]byte("test-secret")))
+	now := time.Now()
+
+	_, err = service.Redirect(context.Background(), urlshortenerservice.RedirectRequest{ShortURL: "abc123", Now: now, UnlockToken: "1.forged"})
+	var webErr *urlshortenererror.WebError
+	if !errors.As(err, &webErr) || webErr.Code != http.StatusUnauthorized {
+		t.Fatalf("Expected unauthorized without a valid token, got %v", err)
+	}
+	if visits != 0 {
+		t.Errorf("Expected locked visits not to be counted, got %d", visits)
+	}
+
+	_, _, err = service.Unlock(context.Background(), "", "abc123", "wrong", "192.0.2.1", now)
+	if !errors.As(err, &webErr) || !errors.Is(webErr.ErrType, urlshortenererror.ErrLocked) {
+		t.Errorf("Expected locked error for a wrong password, got %v", err)
+	}
+
+	token, expires, err := service.Unlock(context.Background(), "", "abc123", "s3cret", "192.0.2.1", now)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !expires.Equal(now.Add(urlshortenerservice.UnlockTokenTTL)) {
+		t.Errorf("Expected token to expire after %v, got %v", urlshortenerservice.UnlockTokenTTL, expires)
+	}
+
+	redirect, err := service.Redirect(context.Background(), urlshortenerservice.RedirectRequest{ShortURL: "abc123", Now: now, UnlockToken: token})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if redirect.Location != "https://example.org/private" {
+		t.Errorf("Expected the destination, got %s", redirect.Location)
+	}
+
+	_, err = service.Redirect(context.Background(), urlshortenerservice.RedirectRequest{ShortURL: "abc123", Now: expires, UnlockToken: token})
+	if !errors.As(err, &webErr) || webErr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected unauthorized with an expired token, got %v", err)
+	}
+}
+
+func TestUnlock_TooManyAttempts(t *testing.T) {
+	hash, _ := urlshortenerservice.HashPassword("s3cret")
+	mockDB := &MockDB{
+		getURLMapFunc: func(shortURL string) (*db.URLMap, error) {
+			return &db.URLMap{ShortURL: shortURL, Options: db.LinkOptions{PasswordHash: hash}}, nil
+		},
+	}
+	service, _ := urlshortenerservice.New(mockDB)
+	now := time.Now()
+
+	for range urlshortenerservice.MaxUnlockAttempts {
+		_, _, _ = service.Unlock(context.Background(), "", "abc123", "wrong", "192.0.2.1", now)
+	}
+
+	_, _, err := service.Unlock(context.Background(), "", "abc123", "s3cret", "192.0.2.1", now)
+	var webErr *urlshortenererror.WebError
+	if !errors.As(err, &webErr) || webErr.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected too many requests, got %v", err)
+	}
+
+	if _, _, err = service.Unlock(context.Background(), "", "abc123", "s3cret", "192.0.2.2", now); err != nil {
+		t.Errorf("Expected other clients not to be throttled, got %v", err)
+	}
+
+	later := now.Add(urlshortenerservice.UnlockAttemptWindow)
+	if _, _, err = service.Unlock(context.Background(), "", "abc123", "s3cret", "192.0.2.1", later); err != nil {
+		t.Errorf("Expected attempts to be allowed after the window, got %v", err)
+	}
+}
+
+func TestUnlock_ParallelAttempts(t *testing.T) {
+	hash, _ := urlshortenerservice.HashPassword("s3cret")
+	mockDB := &MockDB{
+		getURLMapFunc: func(shortURL string) (*db.URLMap, error) {
+			return &db.URLMap{ShortURL: shortURL, Options: db.LinkOptions{PasswordHash: hash}}, nil
+		},
+	}
+	service, _ := urlshortenerservice.New(mockDB)
+	now := time.Now()
+
+	const guesses = 4 * urlshortenerservice.MaxUnlockAttempts
+	codes := make(chan int, guesses)
+	for range guesses {
+		go func() {
+			_, _, err := service.Unlock(context.Background(), "", "abc123", "wrong", "192.0.2.1", now)
+			var webErr *urlshortenererror.WebError
+			if errors.As(err, &webErr) {
+				codes <- webErr.Code
+			} else {
+				codes <- 0
+			}
+		}()
+	}
+
+	checked := 0
+	for range guesses {
+		switch code := <-codes; code {
+		case http.StatusUnauthorized:
+			checked++
+		case http.StatusTooManyRequests:
+		default:
+			t.Errorf("Expected unauthorized or too many requests, got %d", code)
+		}
+	}
+	if checked != urlshortenerservice.MaxUnlockAttempts {
+		t.Errorf("Expected %d passwords to be checked, got %d", urlshortenerservice.MaxUnlockAttempts, checked)
+	}
+}
+
+func TestGetLinkInfo_Protected(t *testing.T) {
+	mockDB := &MockDB{
+		getURLMapFunc: func(shortURL string) (*db.URLMap, error) {
+			return &db.URLMap{
+				ShortURL:    shortURL,
+				OriginalURL: "https://example.org/private",
+				Options:     db.LinkOptions{PasswordHash: "hash", CacheControl: "no-store"},
+				Hits:        3,
+			}, nil
+		},
+	}
+	service, _ := urlshortenerservice.New(mockDB)
+
+	info, err := service.GetLinkInfo(context.Background(), "", "abc123", time.Now())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !info.Protected || info.OriginalURL != "" || info.Options != (urlshortenerservice.PublicOptions{}) {
+		t.Errorf("Expected the destination to be hidden, got %+v", info)
+	}
+	if info.Safety.Status != urlshortenerservice.SafetyStatusUnknown {
+		t.Errorf("Expected unknown safety status, got %s", info.Safety.Status)
+	}
+}
+
+func TestGetLinkInfo_Pending(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	options := db.LinkOptions{
+		Schedule:  &db.Schedule{ActiveFrom: "2025-03-02T09:00:00Z"},
+		Fallback:  "https://example.org/fallback",
+		Languages: map[string]string{"de": "https://example.org/de"},
+		Variants: []db.Variant{
+			{Name: "a", Destination: "https://example.org/a", Weight: 1},
+			{Name: "b", Destination: "https://example.org/b", Weight: 1},
+		},
+	}
+	mockDB := &MockDB{
+		getURLMapFunc: func(shortURL string) (*db.URLMap, error) {
+			return &db.URLMap{ShortURL: shortURL, OriginalURL: "https://example.org/launch", Options: options}, nil
+		},
+		getVariantHitsFunc: func([]string) (map[string]map[string]int64, error) {
+			return map[string]map[string]int64{}, nil
+		},
+	}
+	service, _ := urlshortenerservice.New(mockDB)
+
+	info, err := service.GetLinkInfo(context.Background(), "", "abc123", now)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !info.Pending || info.OriginalURL != "" || info.Variants != nil || info.Health != nil {
+		t.Errorf("Expected the destinations to be hidden before the launch, got %+v", info)
+	}
+	if info.Options != (urlshortenerservice.PublicOptions{ActiveFrom: "2025-03-02T09:00:00Z"}) {
+		t.Errorf("Expected only public options, got %+v", info.Options)
+	}
+
+	info, err = service.GetLinkInfo(context.Background(), "", "abc123", now.Add(24*time.Hour))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if info.Pending || info.OriginalURL != "https://example.org/launch" || len(info.Variants) != 2 {
+		t.Errorf("Expected the details once the link is active, got %+v", info)
+	}
+}
+
+func TestRedirect_OneTime(t *testing.T) {
+	var consumedAt *time.Time
+	mockDB := &MockDB{
+		getURLMapFunc: func(shortURL string) (*db.URLMap, error) {
+			return &db.URLMap{
+				ShortURL:    shortURL,
+				OriginalURL: "https://example.org/secret",
+				Options:     db.LinkOptions{OneTime: true},
+				ConsumedAt:  consumedAt,
+			}, nil
+		},
+		visitedFunc: func(_ string) {
+			now := time.Now()
+			consumedAt = &now
+		},
+	}
+	service, _ := urlshortenerservice.New(mockDB)
+
+	redirect, err := service.Redirect(context.Background(), urlshortenerservice.RedirectRequest{ShortURL: "abc123", Now: time.Now()})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if redirect.Location != "https://example.org/secret" || !redirect.Temporary {
+		t.Errorf("Expected a temporary redirect to the destination, got %+v", redirect)
+	}
+
+	_, err = service.Redirect(context.Background(), urlshortenerservice.RedirectRequest{ShortURL: "abc123", Now: time.Now()})
+	var webErr *urlshortenererror.WebError
+	if !errors.As(err, &webErr) || webErr.Code != http.StatusGone {
+		t.Errorf("Expected gone on the second visit, got %v", err)
+	}
+}
+
+func TestShortenURLWithOptions_OneTimePermanentCode(t *testing.T) {
+	service, _ := urlshortenerservice.New(&MockDB{})
+
+	_, err := service.ShortenURLWithOptions(context.Background(), "", "https://example.org", db.LinkOptions{OneTime: true, RedirectCode: http.StatusMovedPermanently})
+
+	var webErr *urlshortenererror.WebError
+	if !errors.As(err, &webErr) || webErr.Code != http.StatusBadRequest {
+		t.Errorf("Expected bad request, got %v", err)
+	}
+}
+
+func TestShortenURLWithOptions_OneTimeCacheControl(t *testing.T) {
+	service, _ := urlshortenerservice.New(&MockDB{
+		storeURLWithOptionsFunc: func(shortURL, _ string, _ db.LinkOptions) (string, error) {
+			return shortURL, nil
+		},
+	})
+
+	tests := []struct {
+		cacheControl string
+		valid        bool
+	}{
+		{cacheControl: "public, max-age=86400", valid: false},
+		{cacheControl: "no-cache", valid: false},
+		{cacheControl: "private, no-store", valid: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.cacheControl, func(t *testing.T) {
+			_, err := service.ShortenURLWithOptions(context.Background(), "", "https://example.org", db.LinkOptions{OneTime: true, CacheControl: tt.cacheControl})
+
+			var webErr *urlshortenererror.WebError
+			if tt.valid && err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+			if !tt.valid && (!errors.As(err, &webErr) || webErr.Code != http.StatusBadRequest) {
+				t.Errorf("Expected bad request, got %v", err)
+			}
+		})
+	}
+}
+
+func TestRedirect_Interstitial(t *testing.T) {
+	tests := []struct {
+		name        string
+		policy      urlshortenerservice.InterstitialPolicy
+		destination string
+		mode        string
+		expected    bool
+	}{
+		{name: "No policy", destination: "https://example.org", expected: false},
+		{name: "Per-link always", destination: "https://example.org", mode: db.InterstitialAlways, expected: true},
+		{
+			name:        "Domain rule",
+			policy:      urlshortenerservice.InterstitialPolicy{Domains: []string{"Example.org"}},
+			destination: "https://docs.example.org/page",
+			expected:    true,
+		},
+		{
+			name:        "Domain rule does not match suffix",
+			policy:      urlshortenerservice.InterstitialPolicy{Domains: []string{"example.org"}},
+			destination: "https://notexample.org",
+			expected:    false,
+		},
+		{
+			name:        "Per-link never overrides domain rule",
+			policy:      urlshortenerservice.InterstitialPolicy{Domains: []string{"example.org"}},
+			destination: "https://example.org",
+			mode:        db.InterstitialNever,
+			expected:    false,
+		},
+		{
+			name:        "External destination",
+			policy:      urlshortenerservice.InterstitialPolicy{InternalDomains: []string{"corp.example"}},
+			destination: "https://example.org",
+			expected:    true,
+		},
+		{
+			name:        "Internal destination",
+			policy:      urlshortenerservice.InterstitialPolicy{InternalDomains: []string{"corp.example"}},
+			destination: "https://wiki.corp.example",
+			expected:    false,
+		},
+		{
+			name:        "Unsafe destination",
+			policy:      urlshortenerservice.InterstitialPolicy{Unsafe: true},
+			destination: "http://192.0.2.1/login",
+			expected:    true,
+		},
+		{
+			name:        "Safe destination",
+			policy:      urlshortenerservice.InterstitialPolicy{Unsafe: true},
+			destination: "https://example.org",
+			expected:    false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := &MockDB{
+				getURLMapFunc: func(shortURL string) (*db.URLMap, error) {
+					return &db.URLMap{ShortURL: shortURL, OriginalURL: tt.destination, Options: db.LinkOptions{Interstitial: tt.mode}}, nil
+				},
+			}
+			service, _ := urlshortenerservice.New(mockDB, urlshortenerservice.WithInterstitialPolicy(tt.policy))
+
+			redirect, err := service.Redirect(context.Background(), urlshortenerservice.RedirectRequest{ShortURL: "abc123", Now: time.Now()})
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if (redirect.Interstitial != nil) != tt.expected {
+				t.Errorf("Expected interstitial %v, got %+v", tt.expected, redirect.Interstitial)
+			}
+		})
+	}
+}
+
+func TestRedirect_Fallback(t *testing.T) {
+	tests := []struct {
+		name             string
+		fallback         string
+		failures         int
+		expectedLocation string
+		expectedFallback bool
+	}{
+		{name: "Healthy", fallback: "https://status.example.org", failures: 0, expectedLocation: "https://example.org/app"},
+		{name: "Below threshold", fallback: "https://status.example.org", failures: 1, expectedLocation: "https://example.org/app"},
+		{
+			name:             "Failing",
+			fallback:         "https://status.example.org",
+			failures:         2,
+			expectedLocation: "https://status.example.org",
+			expectedFallback: true,
+		},
+		{name: "Failing without fallback", failures: 5, expectedLocation: "https://example.org/app"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := &MockDB{
+				getURLMapFunc: func(shortURL string) (*db.URLMap, error) {
+					return &db.URLMap{
+						ShortURL:            shortURL,
+						OriginalURL:         "https://example.org/app",
+						Options:             db.LinkOptions{Fallback: tt.fallback},
+						ConsecutiveFailures: tt.failures,
+					}, nil
+				},
+			}
+			service, _ := urlshortenerservice.New(mockDB, urlshortenerservice.WithFallbackAfter(2))
+
+			redirect, err := service.Redirect(context.Background(), urlshortenerservice.RedirectRequest{ShortURL: "abc123", Now: time.Now()})
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if redirect.Location != tt.expectedLocation || redirect.Fallback != tt.expectedFallback {
+				t.Errorf("Expected location %s and fallback %v, got %s and %v",
+					tt.expectedLocation, tt.expectedFallback, redirect.Location, redirect.Fallback)
+			}
+			if redirect.Fallback && !redirect.Temporary {
+				t.Error("Expected fallback redirects to be temporary")
+			}
+		})
+	}
+}
+
+func TestGetLinkInfo_Health(t *testing.T) {
+	checkedAt := time.Now()
+	mockDB := &MockDB{
+		getURLMapFunc: func(shortURL string) (*db.URLMap, error) {
+			return &db.URLMap{ShortURL: shortURL, OriginalURL: "https://example.org", CheckedAt: &checkedAt, ConsecutiveFailures: 3}, nil
+		},
+		getLinkChecksFunc: func(_ string) ([]db.LinkCheck, error) {
+			return []db.LinkCheck{{CheckedAt: checkedAt, StatusCode: http.StatusBadGateway}}, nil
+		},
+	}
+	service, _ := urlshortenerservice.New(mockDB)
+
+	info, err := service.GetLinkInfo(context.Background(), "", "abc123", time.Now())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if info.Health == nil || info.Health.Status != urlshortenerservice.HealthStatusDown || len(info.Health.Checks) != 1 {
+		t.Errorf("Expected a down status with its history, got %+v", info.Health)
+	}
+}
+
+func TestSuggestShortURLs(t *testing.T) {
+	existing := map[string]int64{
+		"abc123": 5,
+		"abd123": 50,
+		"xyz789": 100,
+		"O1lab0": 1,
+		"0Ilabo": 2,
+	}
+	mockDB := &MockDB{
+		getURLMapsFunc: func(shortURLs []string) ([]db.URLMap, error) {
+			var urlMaps []db.URLMap
+			for _, shortURL := range shortURLs {
+				if hits, ok := existing[shortURL]; ok {
+					urlMaps = append(urlMaps, db.URLMap{ShortURL: shortURL, Hits: hits})
+				}
+			}
+
+			return urlMaps, nil
+		},
+	}
+	service, _ := urlshortenerservice.New(mockDB)
+
+	tests := []struct {
+		name     string
+		code     string
+		expected []string
+	}{
+		{name: "Substitution, most visited first", code: "abc12x", expected: []string{"abc123"}},
+		{name: "Two matches", code: "ab123", expected: []string{"abd123", "abc123"}},
+		{name: "Swapped characters", code: "bac123", expected: []string{"abc123"}},
+		{name: "Lookalikes first", code: "0lIab0", expected: []string{"0Ilabo", "O1lab0"}},
+		{name: "No match", code: "qqqqqq", expected: []string{}},
+		{name: "Too long", code: "abc123abc123abc123abc123abc123abc", expected: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			suggestions, err := service.SuggestShortURLs(context.Background(), "", tt.code, time.Now())
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if !reflect.DeepEqual(suggestions, tt.expected) {
+				t.Errorf("Expected suggestions %v, got %v", tt.expected, suggestions)
+			}
+		})
+	}
+}
+
+func TestSuggestShortURLs_HiddenLinks(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	consumedAt := now.Add(-time.Hour)
+	existing := map[string]db.URLMap{
+		"abc124": {ShortURL: "abc124", Options: db.LinkOptions{PasswordHash: "$2a$10$hash"}},
+		"abc125": {ShortURL: "abc125", Options: db.LinkOptions{OneTime: true}},
+		"abc126": {ShortURL: "abc126", Options: db.LinkOptions{OneTime: true}, ConsumedAt: &consumedAt},
+		"abc127": {ShortURL: "abc127", Options: db.LinkOptions{Schedule: &db.Schedule{
+			ActiveFrom: now.Add(time.Hour).Format(time.RFC3339),
+		}}},
+		"abc128": {ShortURL: "abc128", Hits: 1},
+	}
+	mockDB := &MockDB{
+		getURLMapsFunc: func(shortURLs []string) ([]db.URLMap, error) {
+			var urlMaps []db.URLMap
+			for _, shortURL := range shortURLs {
+				if urlMap, ok := existing[shortURL]; ok {
+					urlMaps = append(urlMaps, urlMap)
+				}
+			}
+
+			return urlMaps, nil
+		},
+	}
+	service, _ := urlshortenerservice.New(mockDB)
+
+	suggestions, err := service.SuggestShortURLs(context.Background(), "", "abc120", now)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !reflect.DeepEqual(suggestions, []string{"abc128"}) {
+		t.Errorf("Expected only the public link to be suggested, got %v", suggestions)
+	}
+}
+
+func TestShortenURLWithAlias(t *testing.T) {
+	taken := map[string]bool{"taken": true}
+	mockDB := &MockDB{
+		storeURLWithOptionsFunc: func(shortURL, _ string, _ db.LinkOptions) (string, error) {
+			if taken[shortURL] {
+				return "", urlshortenererror.Wrap(nil, "URL hash collision", http.StatusConflict, urlshortenererror.ErrDuplicate)
+			}
+
+			return shortURL, nil
+		},
+	}
+	service, _ := urlshortenerservice.New(mockDB, urlshortenerservice.WithReserved(reserved.New("home", "api")))
+
+	tests := []struct {
+		name         string
+		alias        string
+		expectedCode int
+	}{
+		{name: "Valid alias", alias: "launch-2025", expectedCode: 0},
+		{name: "Reserved alias", alias: "home", expectedCode: http.StatusBadRequest},
+		{name: "Too short", alias: "ab", expectedCode: http.StatusBadRequest},
+		{name: "Invalid characters", alias: "a/b.qr", expectedCode: http.StatusBadRequest},
+		{name: "Taken alias", alias: "taken", expectedCode: http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shortURL, err := service.ShortenURLWithAlias(context.Background(), "", "https://example.org", tt.alias, db.LinkOptions{})
+			if tt.expectedCode == 0 {
+				if err != nil || shortURL != tt.alias {
+					t.Errorf("Expected short URL %s, got %s and %v", tt.alias, shortURL, err)
+				}
+
+				return
+			}
+
+			var webErr *urlshortenererror.WebError
+			if !errors.As(err, &webErr) || webErr.Code != tt.expectedCode {
+				t.Errorf("Expected status code %d, got %v", tt.expectedCode, err)
+			}
+		})
+	}
+}
+
+func TestCheckShortURL(t *testing.T) {
+	var looked []string
+	mockDB := &MockDB{
+		getURLMapFunc: func(shortURL string) (*db.URLMap, error) {
+			looked = append(looked, shortURL)
+			if shortURL != "a.co/abc123" {
+				return nil, urlshortenererror.Wrap(nil, "URL not found", http.StatusNotFound, urlshortenererror.ErrNotFound)
+			}
+			return &db.URLMap{ShortURL: shortURL}, nil
+		},
+	}
+	registry := reserved.New("api")
+	service, _ := urlshortenerservice.New(mockDB, urlshortenerservice.WithReserved(registry))
+
+	if err := service.CheckShortURL(context.Background(), "a.co", "abc123"); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	for _, shortURL := range []string{"missing", "api"} {
+		err := service.CheckShortURL(context.Background(), "a.co", shortURL)
+		var webErr *urlshortenererror.WebError
+		if !errors.As(err, &webErr) || webErr.Code != http.StatusNotFound {
+			t.Errorf("Expected not found for %s, got %v", shortURL, err)
+		}
+	}
+
+	if !reflect.DeepEqual(looked, []string{"a.co/abc123", "a.co/missing"}) {
+		t.Errorf("Expected only unreserved codes to be looked up in the domain, got %v", looked)
+	}
+}
+
+func TestReservedConflicts(t *testing.T) {
+	mockDB := &MockDB{
+		getURLMapsFunc: func(shortURLs []string) ([]db.URLMap, error) {
+			var urlMaps []db.URLMap
+			for _, shortURL := range shortURLs {
+				if shortURL == "home" {
+					urlMaps = append(urlMaps, db.URLMap{ShortURL: shortURL})
+				}
+			}
+
+			return urlMaps, nil
+		},
+	}
+	service, _ := urlshortenerservice.New(mockDB, urlshortenerservice.WithReserved(reserved.New("home", "static")))
+
+	conflicts, err := service.ReservedConflicts(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !reflect.DeepEqual(conflicts, []string{"home"}) {
+		t.Errorf("Expected home to conflict, got %v", conflicts)
+	}
+}
+
+func TestShortenURL_Tracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+
+	mockDB := &MockDB{
+		storeURLsFunc: func(_, _ string) (string, error) {
+			return "abc123", nil
+		},
+	}
+	service, _ := urlshortenerservice.New(mockDB, urlshortenerservice.WithTracer(tracer))
+
+	ctx, parent := tracer.Start(context.Background(), "POST /api/shorten")
+	if _, err := service.ShortenURL(ctx, "", "https://example.org"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := service.ShortenURL(ctx, "", "not a url"); err == nil {
+		t.Fatal("Expected an error for an invalid URL")
+	}
+	parent.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("Expected 3 spans, got %d", len(spans))
+	}
+	for _, span := range spans[:2] {
+		if span.Name() != "URLShortenerService.ShortenURL" || span.Parent().SpanID() != parent.SpanContext().SpanID() {
+			t.Errorf("Expected a service span below the request span, got %s", span.Name())
+		}
+	}
+	if spans[0].Status().Code != codes.Unset || spans[1].Status().Code != codes.Error {
+		t.Error("Expected only the failed call to be marked as an error")
+	}
+}
+
+func TestShortenURL_Domain(t *testing.T) {
+	var stored []string
+	mockDB := &MockDB{
+		storeURLsFunc: func(shortURL, _ string) (string, error) {
+			stored = append(stored, shortURL)
+
+			return shortURL, nil
+		},
+		getURLMapFunc: func(shortURL string) (*db.URLMap, error) {
+			if shortURL != "a.co/abc123" {
+				return nil, urlshortenererror.Wrap(nil, "URL not found", http.StatusNotFound, urlshortenererror.ErrNotFound)
+			}
+
+			return &db.URLMap{ShortURL: shortURL, OriginalURL: "https://a.example"}, nil
+		},
+	}
+	service, _ := urlshortenerservice.New(mockDB)
+
+	code, err := service.ShortenURL(context.Background(), "a.co", "https://a.example")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(stored) != 1 || stored[0] != "a.co/"+code {
+		t.Errorf("Expected the link to be stored as a.co/%s, got %v", code, stored)
+	}
+
+	redirect, err := service.Redirect(context.Background(), urlshortenerservice.RedirectRequest{Domain: "a.co", ShortURL: "abc123"})
+	if err != nil || redirect.Location != "https://a.example" {
+		t.Errorf("Expected the link of a.co, got %v, %v", redirect, err)
+	}
+
+	if _, err = service.Redirect(context.Background(), urlshortenerservice.RedirectRequest{Domain: "b.co", ShortURL: "abc123"}); err == nil {
+		t.Error("Expected the code to be unknown on b.co")
+	}
+
+	info, err := service.GetLinkInfo(context.Background(), "a.co", "abc123", time.Now())
+	if err != nil || info.ShortURL != "abc123" {
+		t.Errorf("Expected the info to report the bare code, got %v, %v", info, err)
+	}
+}
+
+func TestResolveDomain(t *testing.T) {
+	loads := 0
+	mockDB := &MockDB{
+		getDomainsFunc: func(accountID string) ([]db.Domain, error) {
+			loads++
+			if accountID != "" {
+				t.Errorf("Expected every domain to be loaded, got account %s", accountID)
+			}
+
+			return []db.Domain{{Host: "a.co", AccountID: "acct-1", RedirectCode: http.StatusFound}}, nil
+		},
+		storeDomainFunc: func(domain db.Domain) (*db.Domain, error) {
+			return &domain, nil
+		},
+	}
+	appMetrics := metrics.New()
+	service, _ := urlshortenerservice.New(mockDB, urlshortenerservice.WithMetrics(appMetrics))
+
+	for _, host := range []string{"a.co", "A.CO:8443", "a.co."} {
+		domain, err := service.ResolveDomain(context.Background(), host)
+		if err != nil || domain == nil || domain.RedirectCode != http.StatusFound {
+			t.Errorf("Expected %s to resolve to a.co, got %v, %v", host, domain, err)
+		}
+	}
+
+	if domain, err := service.ResolveDomain(context.Background(), "sho.rt"); err != nil || domain != nil {
+		t.Errorf("Expected the default namespace, got %v, %v", domain, err)
+	}
+	if loads != 1 {
+		t.Errorf("Expected the domains to be loaded once, got %d", loads)
+	}
+
+	if _, err := service.SaveDomain(context.Background(), db.Domain{Host: "b.co", AccountID: "acct-1"}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := service.ResolveDomain(context.Background(), "a.co"); err != nil || loads != 2 {
+		t.Errorf("Expected saving a domain to reload the domains, got %d loads, %v", loads, err)
+	}
+
+	recorder := httptest.NewRecorder()
+	appMetrics.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	for _, line := range []string{
+		`urlshortener_domain_cache_lookups_total{result="hit"} 3`,
+		`urlshortener_domain_cache_lookups_total{result="miss"} 2`,
+	} {
+		if !strings.Contains(recorder.Body.String(), line+"\n") {
+			t.Errorf("Expected metrics to contain %q, got:\n%s", line, recorder.Body.String())
+		}
+	}
+}
+
+func TestSaveDomain_Invalid(t *testing.T) {
+	mockDB := &MockDB{
+		storeDomainFunc: func(domain db.Domain) (*db.Domain, error) {
+			return &domain, nil
+		},
+	}
+	service, _ := urlshortenerservice.New(mockDB)
+
+	tests := []struct {
+		name   string
+		domain db.Domain
+	}{
+		{name: "Single label", domain: db.Domain{Host: "localhost", AccountID: "acct-1"}},
+		{name: "Path", domain: db.Domain{Host: "a.co/x", AccountID: "acct-1"}},
+		{name: "No account", domain: db.Domain{Host: "a.co"}},
+		{name: "Redirect code", domain: db.Domain{Host: "a.co", AccountID: "acct-1", RedirectCode: http.StatusOK}},
+		{name: "Not found URL", domain: db.Domain{Host: "a.co", AccountID: "acct-1", NotFoundURL: "://a"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := service.SaveDomain(context.Background(), tt.domain)
+
+			var webErr *urlshortenererror.WebError
+			if !errors.As(err, &webErr) || webErr.Code != http.StatusBadRequest {
+				t.Errorf("Expected a bad request, got %v", err)
+			}
+		})
+	}
+
+	domain, err := service.SaveDomain(context.Background(), db.Domain{Host: "Go.Example.org", AccountID: "acct-1", NotFoundURL: "example.org/404"})
+	if err != nil || domain.Host != "go.example.org" || domain.NotFoundURL != "https://example.org/404" {
+		t.Errorf("Expected a normalized domain, got %v, %v", domain, err)
+	}
+}
@@ -0,0 +1,100 @@
+package urlshortenerservice
+
+import (
+	"regexp"
+
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/db"
+)
+
+// MaxVariantWeight is the highest weight a variant can have.
+const MaxVariantWeight = 1000
+
+var variantNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+
+// VariantInfo holds a variant of a link with its click count.
+type VariantInfo struct {
+	Name        string `json:"name"`
+	Destination string `json:"destination"`
+	Weight      int    `json:"weight"`
+	Hits        int64  `json:"hits"`
+}
+
+// pickVariant keeps the visitor on the variant they were assigned before, if it
+// still exists, and otherwise draws one at random by weight.
+func pickVariant(variants []db.Variant, assigned string) db.Variant {
+	total := 0
+	for _, variant := range variants {
+		if variant.Name == assigned {
+			return variant
+		}
+		total += variant.Weight
+	}
+
+	lock.Lock()
+	draw := rng.Intn(total)
+	lock.Unlock()
+
+	for _, variant := range variants {
+		if draw < variant.Weight {
+			return variant
+		}
+		draw -= variant.Weight
+	}
+
+	return variants[len(variants)-1]
+}
+
+// normalizeVariants validates the variants and normalizes their destinations.
+func normalizeVariants(variants []db.Variant) ([]db.Variant, error) {
+	if len(variants) == 0 {
+		return nil, nil
+	}
+	if len(variants) < 2 {
+		return nil, invalidOption("A split link needs at least two variants")
+	}
+
+	normalized := make([]db.Variant, 0, len(variants))
+	names := make(map[string]bool, len(variants))
+
+	for _, variant := range variants {
+		if !variantNamePattern.MatchString(variant.Name) {
+			return nil, invalidOption("Variant names must be 1-32 letters, digits, dashes or underscores")
+		}
+		if names[variant.Name] {
+			return nil, invalidOption("Duplicate variant name: " + variant.Name)
+		}
+		names[variant.Name] = true
+
+		if variant.Weight < 1 || variant.Weight > MaxVariantWeight {
+			return nil, invalidOption("Variant weights must be between 1 and 1000")
+		}
+
+		destination, err := normalizeDestination(variant.Destination)
+		if err != nil {
+			return nil, err
+		}
+		variant.Destination = destination
+		normalized = append(normalized, variant)
+	}
+
+	return normalized, nil
+}
+
+// variantInfo combines the variants of a link with their click counts.
+func variantInfo(variants []db.Variant, hits map[string]int64) []VariantInfo {
+	if len(variants) == 0 {
+		return nil
+	}
+
+	info := make([]VariantInfo, 0, len(variants))
+	for _, variant := range variants {
+		info = append(info, VariantInfo{
+			Name:        variant.Name,
+			Destination: variant.Destination,
+			Weight:      variant.Weight,
+			Hits:        hits[variant.Name],
+		})
+	}
+
+	return info
+}
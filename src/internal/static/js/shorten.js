@@ -9,7 +9,7 @@ function copy_function() {
 
     // Create a temporary input element to hold the full URL
     const tempInput = document.createElement('input');
-    tempInput.value = "http://localhost:8000/" + url; // Prefix with your domain
+    tempInput.value = url; // The server renders the full short URL
     document.body.appendChild(tempInput);
 
     // Select the text inside the temporary input element
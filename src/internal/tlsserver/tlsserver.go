@@ -0,0 +1,251 @@
+// Package tlsserver serves HTTPS with a certificate that is reloaded when its
+// files change, and the HTTP redirect and HSTS header that go with it.
+package tlsserver
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/forwarded"
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/logging"
+)
+
+// DefaultInterval is how often the certificate files are checked for changes.
+const DefaultInterval = time.Minute
+
+// Reloader holds the certificate served over HTTPS and loads it again from
+// its files when they change, so a rotated certificate is picked up without
+// a restart.
+type Reloader struct {
+	cert     *tls.Certificate
+	logger   *slog.Logger
+	certFile string
+	keyFile  string
+	// stamp identifies the version of the files the certificate was loaded from.
+	stamp    string
+	interval time.Duration
+	lock     sync.RWMutex
+}
+
+// Option type for functional options.
+type Option func(*Reloader)
+
+// WithInterval sets how often the files are checked for changes.
+func WithInterval(interval time.Duration) Option {
+	return func(r *Reloader) {
+		r.interval = interval
+	}
+}
+
+// WithLogger sets the logger reporting reloads.
+func WithLogger(logger *slog.Logger) Option {
+	return func(r *Reloader) {
+		r.logger = logger
+	}
+}
+
+// New loads the certificate and key pair from the PEM files.
+func New(certFile, keyFile string, opts ...Option) (*Reloader, error) {
+	r := &Reloader{
+		logger:   slog.Default(),
+		certFile: certFile,
+		keyFile:  keyFile,
+		interval: DefaultInterval,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	if _, err := r.Reload(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// GetCertificate returns the current certificate, to be used as
+// tls.Config.GetCertificate.
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	return r.cert, nil
+}
+
+// Reload loads the certificate again when its files changed and reports
+// whether it did. A pair that cannot be loaded, such as a certificate
+// replaced before its key, leaves the current certificate in place and is
+// tried again on the next call.
+func (r *Reloader) Reload() (bool, error) {
+	stamp, err := r.fileStamp()
+	if err != nil {
+		return false, err
+	}
+
+	r.lock.RLock()
+	unchanged := stamp == r.stamp
+	r.lock.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return false, fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+
+	r.lock.Lock()
+	r.cert, r.stamp = &cert, stamp
+	r.lock.Unlock()
+
+	return true, nil
+}
+
+// Run checks the files for changes until the context is cancelled. An
+// interval of 0 disables the checks.
+func (r *Reloader) Run(ctx context.Context) {
+	if r.interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		reloaded, err := r.Reload()
+		if err != nil {
+			r.logger.ErrorContext(ctx, "Failed to reload the TLS certificate, keeping the current one", logging.Error(err))
+
+			continue
+		}
+		if reloaded {
+			r.logger.InfoContext(ctx, "Reloaded the TLS certificate", "cert_file", r.certFile)
+		}
+	}
+}
+
+// fileStamp combines the size and modification time of both files.
+func (r *Reloader) fileStamp() (string, error) {
+	var stamp string
+	for _, name := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(name)
+		if err != nil {
+			return "", fmt.Errorf("failed to read TLS file: %w", err)
+		}
+		stamp += strconv.FormatInt(info.Size(), 10) + "@" + info.ModTime().String() + ";"
+	}
+
+	return stamp, nil
+}
+
+// LoadCertPool reads the PEM certificates of a file into a pool, such as the
+// CAs trusted to issue client certificates.
+func LoadCertPool(file string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificates: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no PEM certificates found in %s", file)
+	}
+
+	return pool, nil
+}
+
+// HasClientCert reports whether the client presented a certificate that
+// was verified against the client CAs.
+func HasClientCert(req *http.Request) bool {
+	return req.TLS != nil && len(req.TLS.VerifiedChains) > 0
+}
+
+// ClientCommonName returns the common name of the verified client
+// certificate, empty without one.
+func ClientCommonName(req *http.Request) string {
+	if !HasClientCert(req) {
+		return ""
+	}
+
+	return req.TLS.VerifiedChains[0][0].Subject.CommonName
+}
+
+// HSTS sets the Strict-Transport-Security header on HTTPS responses.
+type HSTS struct {
+	MaxAge            time.Duration
+	IncludeSubdomains bool
+}
+
+// Middleware adds the header to responses to requests made over HTTPS,
+// directly or through a trusted proxy. Browsers ignore it over HTTP. A zero
+// MaxAge adds nothing.
+func (h HSTS) Middleware(next http.Handler) http.Handler {
+	if h.MaxAge <= 0 {
+		return next
+	}
+
+	value := "max-age=" + strconv.FormatInt(int64(h.MaxAge.Seconds()), 10)
+	if h.IncludeSubdomains {
+		value += "; includeSubDomains"
+	}
+
+	return http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
+		if forwarded.Scheme(req) == "https" {
+			wr.Header().Set("Strict-Transport-Security", value)
+		}
+
+		next.ServeHTTP(wr, req)
+	})
+}
+
+// RedirectHandler sends every request to the same host and path over HTTPS,
+// on the port of the HTTPS listen address unless it is the default 443.
+// GET and HEAD requests get a 301, other methods a 308 keeping the method
+// and body.
+func RedirectHandler(httpsAddr string) http.Handler {
+	var port string
+	if _, p, err := net.SplitHostPort(httpsAddr); err == nil && p != "443" {
+		port = p
+	}
+
+	return http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
+		host := req.Host
+		if name, _, err := net.SplitHostPort(host); err == nil {
+			host = name
+		}
+		host = strings.Trim(host, "[]")
+		if host == "" {
+			http.Error(wr, "Host not provided", http.StatusBadRequest)
+
+			return
+		}
+		if port != "" {
+			host = net.JoinHostPort(host, port)
+		} else if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+
+		code := http.StatusPermanentRedirect
+		if req.Method == http.MethodGet || req.Method == http.MethodHead {
+			code = http.StatusMovedPermanently
+		}
+
+		http.Redirect(wr, req, "https://"+host+req.URL.RequestURI(), code)
+	})
+}
@@ -0,0 +1,193 @@
+package tlsserver_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/tlsserver"
+)
+
+// writePair writes a self-signed certificate for the name and its key.
+func writePair(t *testing.T, certFile, keyFile, name string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if certFile != "" {
+		writePEM(t, certFile, "CERTIFICATE", der)
+	}
+	if keyFile != "" {
+		writePEM(t, keyFile, "EC PRIVATE KEY", keyDER)
+	}
+}
+
+// writePEM writes the block and moves its modification time forward, so
+// the change is seen on file systems with a coarse clock.
+func writePEM(t *testing.T, file, blockType string, der []byte) {
+	t.Helper()
+
+	if err := os.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	later := info.ModTime().Add(time.Second)
+	if err = os.Chtimes(file, later, later); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func commonName(t *testing.T, reloader *tlsserver.Reloader) string {
+	t.Helper()
+
+	cert, err := reloader.GetCertificate(&tls.ClientHelloInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return leaf.Subject.CommonName
+}
+
+func TestReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+
+	if _, err := tlsserver.New(certFile, keyFile); err == nil {
+		t.Error("Expected an error for missing files")
+	}
+
+	writePair(t, certFile, keyFile, "old.example")
+	reloader, err := tlsserver.New(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if name := commonName(t, reloader); name != "old.example" {
+		t.Errorf("Expected old.example, got %s", name)
+	}
+
+	if reloaded, reloadErr := reloader.Reload(); reloaded || reloadErr != nil {
+		t.Errorf("Expected unchanged files to be skipped, got %v, %v", reloaded, reloadErr)
+	}
+
+	// A certificate written before its key does not match the old key.
+	writePair(t, certFile, "", "new.example")
+	if _, err = reloader.Reload(); err == nil {
+		t.Error("Expected an error for a mismatched pair")
+	}
+	if name := commonName(t, reloader); name != "old.example" {
+		t.Errorf("Expected the old certificate to be kept, got %s", name)
+	}
+
+	writePair(t, certFile, keyFile, "new.example")
+	if reloaded, reloadErr := reloader.Reload(); !reloaded || reloadErr != nil {
+		t.Errorf("Expected the certificate to be reloaded, got %v, %v", reloaded, reloadErr)
+	}
+	if name := commonName(t, reloader); name != "new.example" {
+		t.Errorf("Expected new.example, got %s", name)
+	}
+}
+
+func TestHSTS(t *testing.T) {
+	tests := []struct {
+		name  string
+		hsts  tlsserver.HSTS
+		https bool
+		want  string
+	}{
+		{name: "https", hsts: tlsserver.HSTS{MaxAge: 365 * 24 * time.Hour}, https: true, want: "max-age=31536000"},
+		{name: "subdomains", hsts: tlsserver.HSTS{MaxAge: time.Hour, IncludeSubdomains: true}, https: true, want: "max-age=3600; includeSubDomains"},
+		{name: "http", hsts: tlsserver.HSTS{MaxAge: time.Hour}, want: ""},
+		{name: "disabled", hsts: tlsserver.HSTS{}, https: true, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "http://sho.rt/abc", nil)
+			if tt.https {
+				req.TLS = &tls.ConnectionState{}
+			}
+			rec := httptest.NewRecorder()
+			tt.hsts.Middleware(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})).ServeHTTP(rec, req)
+
+			if got := rec.Header().Get("Strict-Transport-Security"); got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestRedirectHandler(t *testing.T) {
+	tests := []struct {
+		name      string
+		httpsAddr string
+		method    string
+		target    string
+		code      int
+		location  string
+	}{
+		{name: "default port", httpsAddr: ":443", method: http.MethodGet, target: "http://sho.rt:80/abc?x=1", code: http.StatusMovedPermanently, location: "https://sho.rt/abc?x=1"},
+		{name: "custom port", httpsAddr: ":8443", method: http.MethodGet, target: "http://sho.rt:8080/abc", code: http.StatusMovedPermanently, location: "https://sho.rt:8443/abc"},
+		{name: "post keeps the method", httpsAddr: ":443", method: http.MethodPost, target: "http://sho.rt/api/shorten", code: http.StatusPermanentRedirect, location: "https://sho.rt/api/shorten"},
+		{name: "ipv6", httpsAddr: ":443", method: http.MethodGet, target: "http://[::1]:8080/abc", code: http.StatusMovedPermanently, location: "https://[::1]/abc"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			tlsserver.RedirectHandler(tt.httpsAddr).ServeHTTP(rec, httptest.NewRequest(tt.method, tt.target, nil))
+
+			if rec.Code != tt.code || rec.Header().Get("Location") != tt.location {
+				t.Errorf("Expected %d to %s, got %d to %s", tt.code, tt.location, rec.Code, rec.Header().Get("Location"))
+			}
+		})
+	}
+}
+
+func TestClientCommonName(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "https://sho.rt/api/domains", nil)
+	if name := tlsserver.ClientCommonName(req); name != "" {
+		t.Errorf("Expected no name without a certificate, got %q", name)
+	}
+
+	leaf := &x509.Certificate{Subject: pkix.Name{CommonName: "acct-1"}}
+	req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{leaf}}}
+	if name := tlsserver.ClientCommonName(req); name != "acct-1" {
+		t.Errorf("Expected acct-1, got %q", name)
+	}
+}
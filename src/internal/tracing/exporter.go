@@ -0,0 +1,48 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Exporters selectable by configuration.
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+)
+
+// otlpTracesPath is the path of the OTLP/HTTP traces endpoint.
+const otlpTracesPath = "/v1/traces"
+
+// NewExporter creates the exporter of the given kind. The stdout exporter
+// writes a JSON line per span to w. The otlp exporter sends protobuf encoded
+// spans to the OTLP/HTTP collector at the endpoint, e.g.
+// http://localhost:4318, adding the traces path when the endpoint has no
+// path. It returns nil for ExporterNone.
+func NewExporter(ctx context.Context, kind, endpoint string, w io.Writer) (sdktrace.SpanExporter, error) {
+	switch kind {
+	case "", ExporterNone:
+		return nil, nil
+	case ExporterStdout:
+		return stdouttrace.New(stdouttrace.WithWriter(w))
+	case ExporterOTLP:
+		parsedURL, err := url.Parse(endpoint)
+		if err != nil || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") || parsedURL.Host == "" {
+			return nil, fmt.Errorf("invalid OTLP endpoint %q", endpoint)
+		}
+		if parsedURL.Path == "" || parsedURL.Path == "/" {
+			parsedURL.Path = otlpTracesPath
+		}
+
+		return otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(parsedURL.String()))
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", kind)
+	}
+}
@@ -0,0 +1,51 @@
+package tracing
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/logging"
+)
+
+// Middleware continues the trace of the traceparent header, or starts a new
+// one, with a server span per request named after the matched route. The
+// trace and span IDs are added to the records logged with the request context.
+func (p *Provider) Middleware(next http.Handler) http.Handler {
+	if p == nil {
+		return next
+	}
+
+	named := http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
+		span := trace.SpanFromContext(req.Context())
+		spanContext := span.SpanContext()
+		req = req.WithContext(logging.WithAttrs(req.Context(),
+			slog.String("trace_id", spanContext.TraceID().String()),
+			slog.String("span_id", spanContext.SpanID().String()),
+		))
+
+		next.ServeHTTP(wr, req)
+
+		// The mux sets the pattern of the matched route on the request.
+		if req.Pattern != "" {
+			_, route, found := strings.Cut(req.Pattern, " ")
+			if !found {
+				route = req.Pattern
+			}
+			span.SetName(req.Method + " " + route)
+			span.SetAttributes(semconv.HTTPRoute(route))
+		}
+	})
+
+	return otelhttp.NewHandler(named, "",
+		otelhttp.WithTracerProvider(p.provider),
+		otelhttp.WithPropagators(propagator),
+		otelhttp.WithSpanNameFormatter(func(_ string, req *http.Request) string {
+			return "HTTP " + req.Method
+		}),
+	)
+}
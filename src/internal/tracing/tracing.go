@@ -0,0 +1,125 @@
+// Package tracing sets up OpenTelemetry tracing: the tracer provider with its
+// exporter and sampler, and the middleware tracing incoming requests.
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// DefaultServiceName is the service name of the spans.
+const DefaultServiceName = "url-shortener"
+
+// instrumentationName names the tracer of the application's own spans.
+const instrumentationName = "github.com/tberk-s/learning-url-shortener-with-go"
+
+// propagator reads and writes the W3C traceparent and tracestate headers.
+var propagator = propagation.TraceContext{}
+
+// Provider owns the tracer provider of the server. A nil *Provider traces
+// nothing, so callers do not need to check whether tracing is enabled.
+type Provider struct {
+	provider *sdktrace.TracerProvider
+}
+
+// Option type for functional options.
+type Option func(*settings)
+
+type settings struct {
+	exporter    sdktrace.SpanExporter
+	serviceName string
+	sampleRatio float64
+}
+
+// WithExporter sets where the spans are sent, see NewExporter.
+func WithExporter(exporter sdktrace.SpanExporter) Option {
+	return func(s *settings) {
+		s.exporter = exporter
+	}
+}
+
+// WithServiceName sets the service.name resource attribute of the spans.
+func WithServiceName(name string) Option {
+	return func(s *settings) {
+		s.serviceName = name
+	}
+}
+
+// WithSampleRatio sets the share of new traces that are recorded, between 0
+// and 1. Traces started by a caller follow the caller's decision.
+func WithSampleRatio(ratio float64) Option {
+	return func(s *settings) {
+		s.sampleRatio = ratio
+	}
+}
+
+// New creates a Provider exporting spans in batches. Resource attributes set
+// in OTEL_RESOURCE_ATTRIBUTES are added to the service name. Shutdown must be
+// called to export the remaining spans.
+func New(ctx context.Context, opts ...Option) (*Provider, error) {
+	s := settings{serviceName: DefaultServiceName, sampleRatio: 1}
+	for _, opt := range opts {
+		opt(&s)
+	}
+
+	res, err := resource.New(ctx,
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+		resource.WithAttributes(semconv.ServiceName(s.serviceName)),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tracing resource: %w", err)
+	}
+
+	providerOpts := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(s.sampleRatio))),
+	}
+	if s.exporter != nil {
+		providerOpts = append(providerOpts, sdktrace.WithBatcher(s.exporter))
+	}
+
+	return &Provider{provider: sdktrace.NewTracerProvider(providerOpts...)}, nil
+}
+
+// Tracer returns the tracer of the application's spans.
+func (p *Provider) Tracer() trace.Tracer {
+	if p == nil {
+		return NoopTracer()
+	}
+
+	return p.provider.Tracer(instrumentationName)
+}
+
+// Shutdown exports the remaining spans and stops the exporter.
+func (p *Provider) Shutdown(ctx context.Context) error {
+	if p == nil {
+		return nil
+	}
+
+	return p.provider.Shutdown(ctx)
+}
+
+// NoopTracer returns a tracer recording nothing, the default of the layers
+// tracing their operations.
+func NoopTracer() trace.Tracer {
+	return noop.NewTracerProvider().Tracer(instrumentationName)
+}
+
+// End records the error err points to, if any, and ends the span. It is
+// meant to be deferred by functions with a named error result.
+func End(span trace.Span, err *error) {
+	if err != nil && *err != nil {
+		span.RecordError(*err)
+		span.SetStatus(codes.Error, (*err).Error())
+	}
+	span.End()
+}
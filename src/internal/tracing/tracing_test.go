@@ -0,0 +1,183 @@
+package tracing_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/tracing"
+)
+
+// memoryExporter keeps the exported spans after the provider shut down.
+type memoryExporter struct {
+	*tracetest.InMemoryExporter
+}
+
+func (memoryExporter) Shutdown(context.Context) error { return nil }
+
+func newProvider(t *testing.T, opts ...tracing.Option) (*tracing.Provider, memoryExporter) {
+	t.Helper()
+
+	exporter := memoryExporter{tracetest.NewInMemoryExporter()}
+	provider, err := tracing.New(context.Background(), append([]tracing.Option{tracing.WithExporter(exporter)}, opts...)...)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	return provider, exporter
+}
+
+func TestEnd(t *testing.T) {
+	provider, exporter := newProvider(t)
+	tracer := provider.Tracer()
+
+	ctx, parent := tracer.Start(context.Background(), "parent")
+	_, child := tracer.Start(ctx, "child")
+	err := errors.New("query failed")
+	tracing.End(child, &err)
+	tracing.End(parent, nil)
+
+	if err = provider.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("Expected 2 spans, got %d", len(spans))
+	}
+	if spans[0].Parent.SpanID() != spans[1].SpanContext.SpanID() {
+		t.Error("Expected the child to be a child of the parent span")
+	}
+	if spans[0].Status.Code != codes.Error || spans[0].Status.Description != "query failed" || spans[1].Status.Code != codes.Unset {
+		t.Errorf("Expected only the child to record the error, got %v and %v", spans[0].Status, spans[1].Status)
+	}
+	if service, _ := spans[0].Resource.Set().Value("service.name"); service.AsString() != tracing.DefaultServiceName {
+		t.Errorf("Expected the default service name, got %s", service.AsString())
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	provider, exporter := newProvider(t, tracing.WithSampleRatio(0))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/links/{code}", func(wr http.ResponseWriter, req *http.Request) {
+		_, span := provider.Tracer().Start(req.Context(), "service")
+		span.End()
+		wr.WriteHeader(http.StatusInternalServerError)
+	})
+	handler := provider.Middleware(mux)
+
+	// New traces are dropped at a ratio of 0, a sampled caller overrides it.
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/links/abc123", nil))
+	req := httptest.NewRequest(http.MethodGet, "/api/links/abc123", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	_ = provider.Shutdown(context.Background())
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("Expected the 2 spans of the sampled request, got %d", len(spans))
+	}
+	service, server := spans[0], spans[1]
+	if server.SpanContext.TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" || server.Parent.SpanID().String() != "00f067aa0ba902b7" {
+		t.Errorf("Expected the server span to continue the incoming trace, got %v", server.SpanContext)
+	}
+	if server.Name != "GET /api/links/{code}" || server.SpanKind != trace.SpanKindServer {
+		t.Errorf("Expected a server span named after the route, got %s", server.Name)
+	}
+	if server.Status.Code != codes.Error {
+		t.Errorf("Expected the failed request to be marked as an error, got %v", server.Status)
+	}
+	if service.Parent.SpanID() != server.SpanContext.SpanID() {
+		t.Error("Expected the service span to be a child of the server span")
+	}
+}
+
+func TestNilProvider(t *testing.T) {
+	var provider *tracing.Provider
+
+	_, span := provider.Tracer().Start(context.Background(), "ignored")
+	if span.IsRecording() {
+		t.Error("Expected the span not to be recorded")
+	}
+	tracing.End(span, nil)
+
+	if err := provider.Shutdown(context.Background()); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+}
+
+func TestNewExporter(t *testing.T) {
+	exporter, err := tracing.NewExporter(context.Background(), tracing.ExporterNone, "", nil)
+	if exporter != nil || err != nil {
+		t.Errorf("Expected no exporter, got %v, %v", exporter, err)
+	}
+
+	for _, endpoint := range []string{"localhost:4318", "ftp://collector", "http://"} {
+		if _, err = tracing.NewExporter(context.Background(), tracing.ExporterOTLP, endpoint, nil); err == nil {
+			t.Errorf("Expected an error for %s", endpoint)
+		}
+	}
+
+	if _, err = tracing.NewExporter(context.Background(), "zipkin", "", nil); err == nil {
+		t.Error("Expected an error for an unknown exporter")
+	}
+}
+
+func TestStdoutExporter(t *testing.T) {
+	var buf bytes.Buffer
+	exporter, err := tracing.NewExporter(context.Background(), tracing.ExporterStdout, "", &buf)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	provider, _ := tracing.New(context.Background(), tracing.WithExporter(exporter), tracing.WithServiceName("test-service"))
+
+	_, span := provider.Tracer().Start(context.Background(), "stored")
+	span.End()
+	_ = provider.Shutdown(context.Background())
+
+	for _, expected := range []string{`"Name":"stored"`, `"Value":"test-service"`, span.SpanContext().TraceID().String()} {
+		if !strings.Contains(buf.String(), expected) {
+			t.Errorf("Expected %s in %s", expected, buf.String())
+		}
+	}
+}
+
+func TestOTLPExporter(t *testing.T) {
+	var paths []string
+	var mu sync.Mutex
+	collector := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		paths = append(paths, req.URL.Path+" "+req.Header.Get("Content-Type"))
+	}))
+	defer collector.Close()
+
+	for _, endpoint := range []string{collector.URL, collector.URL + "/custom/traces"} {
+		exporter, err := tracing.NewExporter(context.Background(), tracing.ExporterOTLP, endpoint, nil)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		provider, _ := tracing.New(context.Background(), tracing.WithExporter(exporter))
+
+		_, span := provider.Tracer().Start(context.Background(), "exported")
+		span.End()
+		if err = provider.Shutdown(context.Background()); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+
+	expected := []string{"/v1/traces application/x-protobuf", "/custom/traces application/x-protobuf"}
+	if strings.Join(paths, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected exports to %v, got %v", expected, paths)
+	}
+}
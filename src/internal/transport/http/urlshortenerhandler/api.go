@@ -0,0 +1,209 @@
+package urlshortenerhandler
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/db"
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/logging"
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/qr"
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/service/urlshortenerservice"
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/urlshortenererror"
+)
+
+// MaxRequestBodySize is the largest request body the API reads, larger
+// ones are refused with 413.
+const MaxRequestBodySize = 1 << 20
+
+// ShortenRequest is the body accepted by the shorten API.
+type ShortenRequest struct {
+	Passthrough  *db.Passthrough    `json:"passthrough"`
+	Schedule     *db.Schedule       `json:"schedule"`
+	Languages    map[string]string  `json:"languages"`
+	URL          string             `json:"url"`
+	Alias        string             `json:"alias"`
+	CacheControl string             `json:"cache_control"`
+	Fallback     string             `json:"fallback"`
+	Password     string             `json:"password"`
+	Interstitial string             `json:"interstitial"`
+	Targeting    []db.TargetingRule `json:"targeting"`
+	Variants     []db.Variant       `json:"variants"`
+	RedirectCode int                `json:"redirect_code"`
+	OneTime      bool               `json:"one_time"`
+}
+
+// ShortenResponse is the body returned by the shorten API.
+type ShortenResponse struct {
+	QRCode      QRCodeLinks `json:"qr_code"`
+	ShortCode   string      `json:"short_code"`
+	ShortURL    string      `json:"short_url"`
+	OriginalURL string      `json:"original_url"`
+}
+
+// QRCodeLinks points to the QR code images of a short URL.
+type QRCodeLinks struct {
+	PNG     string `json:"png"`
+	SVG     string `json:"svg"`
+	DataURI string `json:"data_uri"`
+}
+
+// ErrorResponse is the body returned by the API when a request fails.
+// The request ID matches the X-Request-ID header and the server logs.
+type ErrorResponse struct {
+	Error     string `json:"error"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// ShortenAPI handles the JSON API request to shorten a URL.
+func (h *Handler) ShortenAPI() http.HandlerFunc {
+	return func(wr http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			h.writeErrorResponse(wr, req, http.StatusMethodNotAllowed, "Method not allowed")
+
+			return
+		}
+
+		req.Body = http.MaxBytesReader(wr, req.Body, MaxRequestBodySize)
+		var body ShortenRequest
+		if strings.HasPrefix(req.Header.Get("Content-Type"), "application/json") {
+			if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+				h.writeBodyError(wr, req, err, "Invalid JSON body")
+
+				return
+			}
+		} else {
+			if err := req.ParseForm(); err != nil {
+				h.writeBodyError(wr, req, err, "Invalid form body")
+
+				return
+			}
+			body.URL = req.FormValue("url")
+			body.Alias = req.FormValue("alias")
+			body.Password = req.FormValue("password")
+			body.OneTime = req.FormValue("one_time") == "true"
+		}
+
+		if body.URL == "" {
+			h.writeErrorResponse(wr, req, http.StatusBadRequest, "URL is required")
+
+			return
+		}
+
+		var passwordHash string
+		if body.Password != "" {
+			hash, err := urlshortenerservice.HashPassword(body.Password)
+			if err != nil {
+				h.metrics.Shorten(shortenOutcome(err))
+				h.writeJSONError(wr, req, err)
+
+				return
+			}
+			passwordHash = hash
+		}
+
+		options := db.LinkOptions{
+			Passthrough:  body.Passthrough,
+			Schedule:     body.Schedule,
+			Languages:    body.Languages,
+			CacheControl: body.CacheControl,
+			Fallback:     body.Fallback,
+			Targeting:    body.Targeting,
+			Variants:     body.Variants,
+			PasswordHash: passwordHash,
+			Interstitial: body.Interstitial,
+			RedirectCode: body.RedirectCode,
+			OneTime:      body.OneTime,
+		}
+
+		domain, err := h.service.ResolveDomain(req.Context(), req.Host)
+		if err != nil {
+			h.writeJSONError(wr, req, err)
+
+			return
+		}
+
+		var shortURL string
+		if body.Alias != "" {
+			shortURL, err = h.service.ShortenURLWithAlias(req.Context(), namespace(domain), body.URL, body.Alias, options)
+		} else {
+			shortURL, err = h.service.ShortenURLWithOptions(req.Context(), namespace(domain), body.URL, options)
+		}
+		h.metrics.Shorten(shortenOutcome(err))
+		if err != nil {
+			h.writeJSONError(wr, req, err)
+
+			return
+		}
+
+		h.writeJSON(wr, req, http.StatusCreated, ShortenResponse{
+			QRCode:      h.qrCodeLinks(req, domain, shortURL),
+			ShortCode:   shortURL,
+			ShortURL:    h.shortLinkURL(req, domain, shortURL),
+			OriginalURL: body.URL,
+		})
+	}
+}
+
+// qrCodeLinks builds the QR code URLs of a short URL and inlines the default
+// PNG as a data URI.
+func (h *Handler) qrCodeLinks(req *http.Request, domain *db.Domain, shortURL string) QRCodeLinks {
+	link := h.shortLinkURL(req, domain, shortURL)
+	links := QRCodeLinks{
+		PNG: link + QRCodeSuffix,
+		SVG: link + QRCodeSuffix + "?format=" + qr.FormatSVG,
+	}
+
+	image, err := qr.Render(link, qr.DefaultOptions())
+	if err != nil {
+		h.logger.ErrorContext(req.Context(), "Failed to render QR code", logging.Error(err))
+
+		return links
+	}
+	links.DataURI = "data:image/png;base64," + base64.StdEncoding.EncodeToString(image)
+
+	return links
+}
+
+// writeJSON writes the value as a JSON response with the given status code.
+func (h *Handler) writeJSON(wr http.ResponseWriter, req *http.Request, code int, value any) {
+	wr.Header().Set("Content-Type", "application/json; charset=utf-8")
+	wr.WriteHeader(code)
+	if err := json.NewEncoder(wr).Encode(value); err != nil {
+		h.logger.ErrorContext(req.Context(), "Failed to write JSON response", logging.Error(err))
+	}
+}
+
+// writeErrorResponse writes an API error with the ID of the request.
+func (h *Handler) writeErrorResponse(wr http.ResponseWriter, req *http.Request, code int, message string) {
+	h.writeJSON(wr, req, code, ErrorResponse{
+		Error:     message,
+		RequestID: logging.RequestID(req.Context()),
+	})
+}
+
+// writeBodyError answers a request whose body could not be read, with 413
+// when it is larger than MaxRequestBodySize and 400 with the message otherwise.
+func (h *Handler) writeBodyError(wr http.ResponseWriter, req *http.Request, err error, message string) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		h.writeErrorResponse(wr, req, http.StatusRequestEntityTooLarge, "Request body too large")
+
+		return
+	}
+
+	h.writeErrorResponse(wr, req, http.StatusBadRequest, message)
+}
+
+// writeJSONError writes the message and status code of a WebError as JSON.
+func (h *Handler) writeJSONError(wr http.ResponseWriter, req *http.Request, err error) {
+	var webErr *urlshortenererror.WebError
+	if !errors.As(err, &webErr) {
+		webErr = urlshortenererror.Wrap(err, "Internal server error", http.StatusInternalServerError, urlshortenererror.ErrServerError)
+	}
+
+	h.logWebError(req, webErr)
+	h.writeErrorResponse(wr, req, webErr.Code, webErr.Message)
+}
@@ -0,0 +1,132 @@
+package urlshortenerhandler
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/db"
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/tlsserver"
+)
+
+// DomainRequest is the body accepted by the domain API.
+type DomainRequest struct {
+	AccountID    string `json:"account_id"`
+	NotFoundURL  string `json:"not_found_url"`
+	RedirectCode int    `json:"redirect_code"`
+}
+
+// RequireAdmin only lets requests carrying the admin token as a bearer token
+// through, and with client certificates required, only over connections
+// with a verified one. Without a token every request is refused.
+func (h *Handler) RequireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
+		if h.adminClientCert && !tlsserver.HasClientCert(req) {
+			h.writeErrorResponse(wr, req, http.StatusForbidden, "A client certificate is required")
+
+			return
+		}
+
+		token, found := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+		if !found || h.adminToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(h.adminToken)) != 1 {
+			wr.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			h.writeErrorResponse(wr, req, http.StatusUnauthorized, "Unauthorized")
+
+			return
+		}
+
+		next.ServeHTTP(wr, req)
+	})
+}
+
+// accountID returns the account a domain request acts for. With client
+// certificates required, it is the common name of the client's certificate
+// and naming any other account is refused. With the admin token alone, the
+// caller is the operator of every account and names one in account_id.
+func (h *Handler) accountID(wr http.ResponseWriter, req *http.Request, requested string) (string, bool) {
+	if !h.adminClientCert {
+		return requested, true
+	}
+
+	account := tlsserver.ClientCommonName(req)
+	if account == "" || (requested != "" && requested != account) {
+		h.writeErrorResponse(wr, req, http.StatusForbidden, "The client certificate does not belong to the account")
+
+		return "", false
+	}
+
+	return account, true
+}
+
+// ListDomainsAPI handles the JSON API request to list the custom domains of
+// the account of the request.
+func (h *Handler) ListDomainsAPI() http.HandlerFunc {
+	return func(wr http.ResponseWriter, req *http.Request) {
+		account, ok := h.accountID(wr, req, req.URL.Query().Get("account_id"))
+		if !ok {
+			return
+		}
+
+		domains, err := h.service.GetDomains(req.Context(), account)
+		if err != nil {
+			h.writeJSONError(wr, req, err)
+
+			return
+		}
+
+		h.writeJSON(wr, req, http.StatusOK, domains)
+	}
+}
+
+// SaveDomainAPI handles the JSON API request to add a custom domain to an
+// account or change its settings. A domain of another account is refused.
+func (h *Handler) SaveDomainAPI() http.HandlerFunc {
+	return func(wr http.ResponseWriter, req *http.Request) {
+		var body DomainRequest
+		req.Body = http.MaxBytesReader(wr, req.Body, MaxRequestBodySize)
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			h.writeBodyError(wr, req, err, "Invalid JSON body")
+
+			return
+		}
+
+		account, ok := h.accountID(wr, req, body.AccountID)
+		if !ok {
+			return
+		}
+
+		domain, err := h.service.SaveDomain(req.Context(), db.Domain{
+			Host:         req.PathValue("host"),
+			AccountID:    account,
+			NotFoundURL:  body.NotFoundURL,
+			RedirectCode: body.RedirectCode,
+		})
+		if err != nil {
+			h.writeJSONError(wr, req, err)
+
+			return
+		}
+
+		h.writeJSON(wr, req, http.StatusOK, domain)
+	}
+}
+
+// DeleteDomainAPI handles the JSON API request to remove a custom domain of
+// the account of the request.
+func (h *Handler) DeleteDomainAPI() http.HandlerFunc {
+	return func(wr http.ResponseWriter, req *http.Request) {
+		account, ok := h.accountID(wr, req, req.URL.Query().Get("account_id"))
+		if !ok {
+			return
+		}
+
+		if err := h.service.DeleteDomain(req.Context(), req.PathValue("host"), account); err != nil {
+			h.writeJSONError(wr, req, err)
+
+			return
+		}
+
+		wr.WriteHeader(http.StatusNoContent)
+	}
+}
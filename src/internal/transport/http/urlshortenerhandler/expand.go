@@ -0,0 +1,62 @@
+package urlshortenerhandler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ExpandRequest is the body accepted by the expand API.
+type ExpandRequest struct {
+	Codes []string `json:"codes"`
+}
+
+// ExpandAPI handles the JSON API request to resolve one or many short URLs
+// without counting hits. GET accepts repeated or comma separated code query
+// parameters, POST accepts an ExpandRequest body.
+func (h *Handler) ExpandAPI() http.HandlerFunc {
+	return func(wr http.ResponseWriter, req *http.Request) {
+		var codes []string
+
+		switch req.Method {
+		case http.MethodGet:
+			for _, value := range req.URL.Query()["code"] {
+				codes = append(codes, strings.Split(value, ",")...)
+			}
+		case http.MethodPost:
+			var body ExpandRequest
+			req.Body = http.MaxBytesReader(wr, req.Body, MaxRequestBodySize)
+			if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+				h.writeBodyError(wr, req, err, "Invalid JSON body")
+
+				return
+			}
+			codes = body.Codes
+		default:
+			h.writeErrorResponse(wr, req, http.StatusMethodNotAllowed, "Method not allowed")
+
+			return
+		}
+
+		for i := range codes {
+			codes[i] = strings.TrimSpace(codes[i])
+		}
+
+		domain, err := h.service.ResolveDomain(req.Context(), req.Host)
+		if err != nil {
+			h.writeJSONError(wr, req, err)
+
+			return
+		}
+
+		result, err := h.service.ExpandURLs(req.Context(), namespace(domain), codes, time.Now())
+		if err != nil {
+			h.writeJSONError(wr, req, err)
+
+			return
+		}
+
+		h.writeJSON(wr, req, http.StatusOK, result)
+	}
+}
@@ -2,16 +2,19 @@ package urlshortenerhandler
 
 import (
 	"html/template"
-	"log"
 	"net/http"
+
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/logging"
 )
 
 var indexTemplate = template.Must(template.ParseFiles("src/internal/views/index.html"))
 
 // ShowHomePage handles the request to show the home page.
-func ShowHomePage(wr http.ResponseWriter, _ *http.Request) {
-	if err := indexTemplate.Execute(wr, nil); err != nil {
-		log.Println("Template execution error:", err)
-		http.Error(wr, err.Error(), http.StatusInternalServerError)
+func (h *Handler) ShowHomePage() http.HandlerFunc {
+	return func(wr http.ResponseWriter, req *http.Request) {
+		if err := indexTemplate.Execute(wr, nil); err != nil {
+			h.logger.ErrorContext(req.Context(), "Failed to execute template", logging.Error(err))
+			http.Error(wr, err.Error(), http.StatusInternalServerError)
+		}
 	}
 }
@@ -0,0 +1,79 @@
+package urlshortenerhandler
+
+import (
+	"errors"
+	"html/template"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/logging"
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/urlshortenererror"
+)
+
+// PreviewSuffix marks a request for the preview page of a short URL, e.g. /abc123+.
+const PreviewSuffix = "+"
+
+var previewTemplate = template.Must(template.ParseFiles("src/internal/views/preview.html"))
+
+// ShowPreviewPage handles the request to show where a short URL points to
+// without redirecting or counting a hit.
+func (h *Handler) ShowPreviewPage() http.HandlerFunc {
+	return func(wr http.ResponseWriter, req *http.Request) {
+		shortPath := strings.TrimSuffix(req.URL.Path[1:], PreviewSuffix)
+		if shortPath == "" {
+			http.Error(wr, "URL not provided", http.StatusBadRequest)
+
+			return
+		}
+
+		domain, err := h.service.ResolveDomain(req.Context(), req.Host)
+		if err != nil {
+			h.writeWebError(wr, req, err)
+
+			return
+		}
+
+		info, err := h.service.GetLinkInfo(req.Context(), namespace(domain), shortPath, time.Now())
+		if err != nil {
+			var webErr *urlshortenererror.WebError
+			if errors.As(err, &webErr) && errors.Is(webErr.ErrType, urlshortenererror.ErrNotFound) {
+				h.showNotFoundPage(wr, req, domain, shortPath, PreviewSuffix)
+
+				return
+			}
+			h.writeWebError(wr, req, err)
+
+			return
+		}
+
+		if err = previewTemplate.Execute(wr, map[string]any{
+			"Link":         info,
+			"ShortLinkURL": h.shortLinkURL(req, domain, info.ShortURL),
+		}); err != nil {
+			h.logger.ErrorContext(req.Context(), "Failed to execute template", logging.Error(err))
+			http.Error(wr, "Internal server error", http.StatusInternalServerError)
+		}
+	}
+}
+
+// PreviewAPI handles the JSON API request to preview a short URL.
+func (h *Handler) PreviewAPI() http.HandlerFunc {
+	return func(wr http.ResponseWriter, req *http.Request) {
+		domain, err := h.service.ResolveDomain(req.Context(), req.Host)
+		if err != nil {
+			h.writeJSONError(wr, req, err)
+
+			return
+		}
+
+		info, err := h.service.GetLinkInfo(req.Context(), namespace(domain), req.PathValue("code"), time.Now())
+		if err != nil {
+			h.writeJSONError(wr, req, err)
+
+			return
+		}
+
+		h.writeJSON(wr, req, http.StatusOK, info)
+	}
+}
@@ -0,0 +1,117 @@
+package urlshortenerhandler
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/db"
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/forwarded"
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/logging"
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/qr"
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/urlshortenererror"
+)
+
+// QRCodeSuffix marks a request for the QR code of a short URL, e.g. /abc123.qr.
+const QRCodeSuffix = ".qr"
+
+// ShowQRCode handles the request to render the QR code of an existing short URL.
+func (h *Handler) ShowQRCode() http.HandlerFunc {
+	return func(wr http.ResponseWriter, req *http.Request) {
+		shortPath := strings.TrimSuffix(req.URL.Path[1:], QRCodeSuffix)
+		if shortPath == "" {
+			http.Error(wr, "URL not provided", http.StatusBadRequest)
+
+			return
+		}
+
+		domain, err := h.service.ResolveDomain(req.Context(), req.Host)
+		if err != nil {
+			h.writeWebError(wr, req, err)
+
+			return
+		}
+
+		// Unknown codes get no QR code, it would be cached for a link that
+		// does not exist.
+		if err = h.service.CheckShortURL(req.Context(), namespace(domain), shortPath); err != nil {
+			h.writeWebError(wr, req, err)
+
+			return
+		}
+
+		opts, err := qr.ParseOptions(req.URL.Query())
+		if err != nil {
+			h.writeWebError(wr, req, err)
+
+			return
+		}
+
+		image, err := qr.Render(h.shortLinkURL(req, domain, shortPath), opts)
+		if err != nil {
+			h.writeWebError(wr, req, err)
+
+			return
+		}
+
+		wr.Header().Set("Content-Type", opts.ContentType())
+		wr.Header().Set("Cache-Control", "public, max-age=86400")
+		if _, err = wr.Write(image); err != nil {
+			h.logger.ErrorContext(req.Context(), "Failed to write QR code", logging.Error(err))
+		}
+	}
+}
+
+// shortLinkURL builds the absolute short URL for the given code from the
+// configured base URL, or from the incoming request without one. Links of a
+// custom domain use its host with the scheme of the base URL.
+func (h *Handler) shortLinkURL(req *http.Request, domain *db.Domain, shortURL string) string {
+	base := h.baseURL
+	if base == "" {
+		base = forwarded.Scheme(req) + "://" + req.Host
+	}
+	if domain != nil {
+		scheme, _, _ := strings.Cut(base, "://")
+		base = scheme + "://" + domain.Host
+	}
+
+	return base + "/" + shortURL
+}
+
+// namespace returns the host of a custom domain, empty for the default namespace.
+func namespace(domain *db.Domain) string {
+	if domain == nil {
+		return ""
+	}
+
+	return domain.Host
+}
+
+// writeWebError writes the message and status code of a WebError as plain text.
+func (h *Handler) writeWebError(wr http.ResponseWriter, req *http.Request, err error) {
+	var webErr *urlshortenererror.WebError
+	if !errors.As(err, &webErr) {
+		webErr = urlshortenererror.Wrap(err, "Internal server error", http.StatusInternalServerError, urlshortenererror.ErrServerError)
+	}
+
+	h.logWebError(req, webErr)
+	message := webErr.Message
+	if webErr.Code >= http.StatusInternalServerError {
+		// The ID lets support find the failed request in the logs.
+		message += " (request ID " + logging.RequestID(req.Context()) + ")"
+	}
+	http.Error(wr, message, webErr.Code)
+}
+
+// logWebError logs the error answered to a request. Errors caused by the
+// request itself are logged at debug level and without the inner error, which
+// may quote the submitted URL.
+func (h *Handler) logWebError(req *http.Request, webErr *urlshortenererror.WebError) {
+	if webErr.Code < http.StatusInternalServerError {
+		h.logger.DebugContext(req.Context(), webErr.Message, "status", webErr.Code)
+
+		return
+	}
+
+	h.logger.ErrorContext(req.Context(), webErr.Message, "status", webErr.Code, logging.Error(webErr.InnerErr))
+}
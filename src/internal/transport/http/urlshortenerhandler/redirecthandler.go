@@ -1,15 +1,38 @@
 package urlshortenerhandler
 
 import (
+	"errors"
+	"html/template"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/db"
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/logging"
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/metrics"
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/service/urlshortenerservice"
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/urlshortenererror"
+)
+
+// Settings of the cookie keeping a visitor on the same split test variant.
+const (
+	variantCookiePrefix = "ab_"
+	variantCookieMaxAge = 30 * 24 * time.Hour
+)
+
+var (
+	statusTemplate       = template.Must(template.ParseFiles("src/internal/views/status.html"))
+	interstitialTemplate = template.Must(template.ParseFiles("src/internal/views/interstitial.html"))
+	notFoundTemplate     = template.Must(template.ParseFiles("src/internal/views/notfound.html"))
 )
 
 // RedirectHandler handles the request to redirect to the original URL.
-func RedirectHandler(database *db.DB) http.HandlerFunc {
+// Anything after the short URL, e.g. /abc123/docs?ref=x, is forwarded to the
+// destination when the link allows it. Requests to a custom domain resolve the
+// codes of its namespace and use its settings.
+func (h *Handler) RedirectHandler() http.HandlerFunc {
 	return func(wr http.ResponseWriter, req *http.Request) {
-		shortPath := req.URL.Path[1:]
+		shortPath, extraPath, _ := strings.Cut(req.URL.Path[1:], "/")
 
 		if shortPath == "" {
 			http.Error(wr, "URL not provided", http.StatusBadRequest)
@@ -17,13 +40,185 @@ func RedirectHandler(database *db.DB) http.HandlerFunc {
 			return
 		}
 
-		originalURL, err := database.GetOriginalURL(shortPath)
+		domain, err := h.service.ResolveDomain(req.Context(), req.Host)
+		if err != nil {
+			h.writeWebError(wr, req, err)
+
+			return
+		}
+
+		if req.Method == http.MethodPost {
+			h.unlock(wr, req, domain, shortPath)
+
+			return
+		}
+
+		var variant string
+		if cookie, cookieErr := req.Cookie(variantCookiePrefix + shortPath); cookieErr == nil {
+			variant = cookie.Value
+		}
+
+		var unlockToken string
+		if cookie, cookieErr := req.Cookie(unlockCookiePrefix + shortPath); cookieErr == nil {
+			unlockToken = cookie.Value
+		}
+
+		redirect, err := h.service.Redirect(req.Context(), urlshortenerservice.RedirectRequest{
+			Now:            time.Now(),
+			Query:          req.URL.Query(),
+			Domain:         namespace(domain),
+			ShortURL:       shortPath,
+			ExtraPath:      extraPath,
+			UserAgent:      req.UserAgent(),
+			AcceptLanguage: req.Header.Get("Accept-Language"),
+			Variant:        variant,
+			UnlockToken:    unlockToken,
+		})
+		h.metrics.Redirect(redirectOutcome(redirect, err))
 		if err != nil {
-			http.Error(wr, err.Error(), http.StatusNotFound)
+			var webErr *urlshortenererror.WebError
+			if errors.As(err, &webErr) && errors.Is(webErr.ErrType, urlshortenererror.ErrNotActive) {
+				h.showStatusPage(wr, req, webErr.Code, "Coming soon", webErr.Message)
+
+				return
+			}
+			if errors.As(err, &webErr) && errors.Is(webErr.ErrType, urlshortenererror.ErrGone) {
+				h.showStatusPage(wr, req, webErr.Code, "Link expired", webErr.Message)
+
+				return
+			}
+			if errors.As(err, &webErr) && errors.Is(webErr.ErrType, urlshortenererror.ErrLocked) {
+				h.showUnlockPage(wr, req, webErr.Code, webErr.Message)
+
+				return
+			}
+			if errors.As(err, &webErr) && errors.Is(webErr.ErrType, urlshortenererror.ErrNotFound) && domain != nil && domain.NotFoundURL != "" {
+				http.Redirect(wr, req, domain.NotFoundURL, http.StatusFound)
+
+				return
+			}
+			if errors.As(err, &webErr) && errors.Is(webErr.ErrType, urlshortenererror.ErrNotFound) && extraPath == "" {
+				h.showNotFoundPage(wr, req, domain, shortPath, "")
+
+				return
+			}
+			h.writeWebError(wr, req, err)
 
 			return
 		}
 
-		http.Redirect(wr, req, originalURL, http.StatusPermanentRedirect)
+		redirectCode := h.redirectCode
+		if domain != nil && domain.RedirectCode != 0 {
+			redirectCode = domain.RedirectCode
+		}
+		cacheControl := h.redirectCacheControl
+		if redirect.URLMap.Options.RedirectCode != 0 {
+			redirectCode = redirect.URLMap.Options.RedirectCode
+		}
+		if redirect.URLMap.Options.CacheControl != "" {
+			cacheControl = redirect.URLMap.Options.CacheControl
+		}
+		if redirect.Temporary {
+			// Time based destinations must not stick in browser caches, not
+			// even when the link asks for a permanent redirect.
+			redirectCode = http.StatusTemporaryRedirect
+			cacheControl = "no-store"
+		}
+		if cacheControl != "" {
+			wr.Header().Set("Cache-Control", cacheControl)
+		}
+		for _, header := range redirect.Vary {
+			wr.Header().Add("Vary", header)
+		}
+		if redirect.Variant != "" {
+			http.SetCookie(wr, &http.Cookie{
+				Name:     variantCookiePrefix + shortPath,
+				Value:    redirect.Variant,
+				Path:     "/" + shortPath,
+				MaxAge:   int(variantCookieMaxAge.Seconds()),
+				HttpOnly: true,
+				SameSite: http.SameSiteLaxMode,
+			})
+		}
+
+		if redirect.Interstitial != nil {
+			h.showInterstitialPage(wr, req, redirect)
+
+			return
+		}
+
+		http.Redirect(wr, req, redirect.Location, redirectCode)
+	}
+}
+
+// redirectOutcome classifies the result of a redirect request for the metrics.
+func redirectOutcome(redirect *urlshortenerservice.Redirect, err error) string {
+	var webErr *urlshortenererror.WebError
+	switch {
+	case err == nil && redirect.Fallback:
+		return metrics.RedirectFallback
+	case err == nil:
+		return metrics.RedirectHit
+	case !errors.As(err, &webErr):
+		return metrics.RedirectError
+	case errors.Is(webErr.ErrType, urlshortenererror.ErrNotFound):
+		return metrics.RedirectMiss
+	case errors.Is(webErr.ErrType, urlshortenererror.ErrGone), errors.Is(webErr.ErrType, urlshortenererror.ErrNotActive):
+		return metrics.RedirectExpired
+	case errors.Is(webErr.ErrType, urlshortenererror.ErrLocked):
+		return metrics.RedirectLocked
+	default:
+		return metrics.RedirectError
+	}
+}
+
+// showInterstitialPage warns the visitor about the destination instead of
+// redirecting, continuing after the countdown or a click.
+func (h *Handler) showInterstitialPage(wr http.ResponseWriter, req *http.Request, redirect *urlshortenerservice.Redirect) {
+	wr.Header().Set("Content-Type", "text/html; charset=utf-8")
+	wr.Header().Set("Cache-Control", "no-store")
+	wr.Header().Set("Referrer-Policy", "no-referrer")
+
+	if err := interstitialTemplate.Execute(wr, map[string]any{
+		"Destination": redirect.Location,
+		"Reasons":     redirect.Interstitial.Reasons,
+		"Countdown":   h.interstitialCountdown,
+	}); err != nil {
+		h.logger.ErrorContext(req.Context(), "Failed to execute template", logging.Error(err))
+	}
+}
+
+// showNotFoundPage renders the page for an unknown short URL, suggesting
+// existing ones it may be a typo of. The suffix is kept on the suggested links.
+func (h *Handler) showNotFoundPage(wr http.ResponseWriter, req *http.Request, domain *db.Domain, code, suffix string) {
+	suggestions, err := h.service.SuggestShortURLs(req.Context(), namespace(domain), code, time.Now())
+	if err != nil {
+		h.logger.ErrorContext(req.Context(), "Failed to suggest short URLs", logging.Error(err))
+	}
+
+	wr.Header().Set("Content-Type", "text/html; charset=utf-8")
+	wr.Header().Set("Cache-Control", "no-store")
+	wr.WriteHeader(http.StatusNotFound)
+
+	if err = notFoundTemplate.Execute(wr, map[string]any{
+		"Code":        code,
+		"Suggestions": suggestions,
+		"Suffix":      suffix,
+	}); err != nil {
+		h.logger.ErrorContext(req.Context(), "Failed to execute template", logging.Error(err))
+	}
+}
+
+// showStatusPage renders a page explaining why the request cannot be redirected.
+func (h *Handler) showStatusPage(wr http.ResponseWriter, req *http.Request, code int, title, message string) {
+	wr.Header().Set("Content-Type", "text/html; charset=utf-8")
+	wr.Header().Set("Cache-Control", "no-store")
+	wr.WriteHeader(code)
+
+	if err := statusTemplate.Execute(wr, map[string]any{
+		"Title":   title,
+		"Message": message,
+	}); err != nil {
+		h.logger.ErrorContext(req.Context(), "Failed to execute template", logging.Error(err))
 	}
 }
@@ -1,34 +1,187 @@
 package urlshortenerhandler
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"html/template"
-	"log"
+	"log/slog"
 	"net/http"
+	"strings"
 
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/config"
 	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/db"
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/logging"
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/metrics"
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/reserved"
 	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/service/urlshortenerservice"
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/tracing"
 	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/urlshortenererror"
 )
 
 // Handler struct to hold the dependencies.
 type Handler struct {
-	service *urlshortenerservice.URLShortenerService
-	db      *db.DB
+	service              *urlshortenerservice.URLShortenerService
+	db                   *db.DB
+	redirectCacheControl string
+	// baseURL is the public origin of short URLs without a trailing slash,
+	// empty to use the host of each request.
+	baseURL string
+	// adminToken is the bearer token of the admin API.
+	adminToken         string
+	interstitialPolicy urlshortenerservice.InterstitialPolicy
+	reserved           *reserved.Registry
+	metrics            *metrics.Metrics
+	logger             *slog.Logger
+	tracer             trace.Tracer
+	secret             []byte
+	redirectCode       int
+	fallbackAfter      int
+	codeLength         int
+	// interstitialCountdown is the number of seconds before the interstitial
+	// page continues on its own, 0 waits for the visitor.
+	interstitialCountdown int
+	// adminClientCert requires a verified client certificate on admin routes.
+	adminClientCert bool
+}
+
+// Option type for functional options.
+type Option func(*Handler)
+
+// WithRedirectCode sets the status code of redirects for links without their own.
+func WithRedirectCode(code int) Option {
+	return func(h *Handler) {
+		h.redirectCode = code
+	}
+}
+
+// WithRedirectCacheControl sets the Cache-Control header of redirects for links without their own.
+func WithRedirectCacheControl(cacheControl string) Option {
+	return func(h *Handler) {
+		h.redirectCacheControl = cacheControl
+	}
+}
+
+// WithSecret sets the key signing the cookies of unlocked password protected links.
+func WithSecret(secret []byte) Option {
+	return func(h *Handler) {
+		h.secret = secret
+	}
+}
+
+// WithInterstitialPolicy sets which destinations are shown behind a warning page.
+func WithInterstitialPolicy(policy urlshortenerservice.InterstitialPolicy) Option {
+	return func(h *Handler) {
+		h.interstitialPolicy = policy
+	}
+}
+
+// WithInterstitialCountdown sets the seconds before the warning page continues to the destination.
+func WithInterstitialCountdown(seconds int) Option {
+	return func(h *Handler) {
+		h.interstitialCountdown = seconds
+	}
+}
+
+// WithFallbackAfter sets the number of failed checks in a row after which links use their fallback.
+func WithFallbackAfter(failures int) Option {
+	return func(h *Handler) {
+		h.fallbackAfter = failures
+	}
+}
+
+// WithCodeLength sets the length of generated short URLs.
+func WithCodeLength(length int) Option {
+	return func(h *Handler) {
+		h.codeLength = length
+	}
+}
+
+// WithBaseURL sets the public URL short URLs are built on, such as
+// https://sho.rt. Without one they use the scheme and host of the request.
+func WithBaseURL(baseURL string) Option {
+	return func(h *Handler) {
+		h.baseURL = strings.TrimSuffix(baseURL, "/")
+	}
+}
+
+// WithAdminToken sets the bearer token required by the admin API.
+func WithAdminToken(token string) Option {
+	return func(h *Handler) {
+		h.adminToken = token
+	}
+}
+
+// WithAdminClientCert requires a verified TLS client certificate on the
+// admin API in addition to the token.
+func WithAdminClientCert(required bool) Option {
+	return func(h *Handler) {
+		h.adminClientCert = required
+	}
+}
+
+// WithReserved sets the words that cannot be used as short codes.
+func WithReserved(registry *reserved.Registry) Option {
+	return func(h *Handler) {
+		h.reserved = registry
+	}
+}
+
+// WithMetrics sets the metrics counting redirect and shorten outcomes.
+func WithMetrics(m *metrics.Metrics) Option {
+	return func(h *Handler) {
+		h.metrics = m
+	}
+}
+
+// WithLogger sets the logger of the handler and the service behind it.
+func WithLogger(logger *slog.Logger) Option {
+	return func(h *Handler) {
+		h.logger = logger
+	}
+}
+
+// WithTracer sets the tracer of the service behind the handler.
+func WithTracer(tracer trace.Tracer) Option {
+	return func(h *Handler) {
+		h.tracer = tracer
+	}
 }
 
 // New creates a new Handler instance.
-func New(database *db.DB) (*Handler, error) {
-	service, err := urlshortenerservice.New(database)
+func New(database *db.DB, opts ...Option) (*Handler, error) {
+	h := &Handler{
+		db:                    database,
+		redirectCode:          config.DefaultRedirectCode,
+		interstitialCountdown: config.DefaultInterstitialCountdown,
+		fallbackAfter:         config.DefaultHealthCheckFailures,
+		codeLength:            config.DefaultShortCodeLength,
+		logger:                slog.Default(),
+		tracer:                tracing.NoopTracer(),
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	service, err := urlshortenerservice.New(
+		database,
+		urlshortenerservice.WithSecret(h.secret),
+		urlshortenerservice.WithInterstitialPolicy(h.interstitialPolicy),
+		urlshortenerservice.WithFallbackAfter(h.fallbackAfter),
+		urlshortenerservice.WithCodeLength(h.codeLength),
+		urlshortenerservice.WithReserved(h.reserved),
+		urlshortenerservice.WithMetrics(h.metrics),
+		urlshortenerservice.WithLogger(h.logger),
+		urlshortenerservice.WithTracer(h.tracer),
+	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create URL shortener service: %w", err)
 	}
+	h.service = service
 
-	return &Handler{
-		service: service,
-		db:      database,
-	}, nil
+	return h, nil
 }
 
 // ShowShortenPage handles the request to show the shorten page.
@@ -47,15 +200,23 @@ func (h *Handler) ShowShortenPage() http.HandlerFunc {
 			return
 		}
 
-		shortURL, err := h.service.ShortenURL(originalURL)
+		domain, err := h.service.ResolveDomain(req.Context(), req.Host)
+		if err != nil {
+			h.writeWebError(wr, req, err)
+
+			return
+		}
+
+		shortURL, err := h.service.ShortenURL(req.Context(), namespace(domain), originalURL)
+		h.metrics.Shorten(shortenOutcome(err))
 		if err != nil {
 			var webErr *urlshortenererror.WebError
 			if errors.As(err, &webErr) {
 				wr.Header().Set("Content-Type", "text/plain; charset=utf-8")
-				log.Printf("Error: %v", webErr.Message) // Logs detailed error
+				h.logWebError(req, webErr)
 				wr.WriteHeader(webErr.Code)
 				if _, writeErr := wr.Write([]byte(webErr.Message)); writeErr != nil {
-					log.Printf("Failed to write error message: %v", writeErr)
+					h.logger.ErrorContext(req.Context(), "Failed to write error message", logging.Error(writeErr))
 				}
 
 				return
@@ -68,19 +229,42 @@ func (h *Handler) ShowShortenPage() http.HandlerFunc {
 		// Success case
 		tmpl, err := template.ParseFiles("src/internal/views/shorten.html")
 		if err != nil {
-			log.Printf("Template error: %v", err)
+			h.logger.ErrorContext(req.Context(), "Failed to parse template", logging.Error(err))
 			http.Error(wr, "Internal server error", http.StatusInternalServerError)
 
 			return
 		}
 
 		if err = tmpl.Execute(wr, map[string]any{
-			"ShortURL": shortURL,
+			"ShortURL":     shortURL,
+			"ShortLinkURL": h.shortLinkURL(req, domain, shortURL),
 		}); err != nil {
-			log.Printf("Template execution error: %v", err)
+			h.logger.ErrorContext(req.Context(), "Failed to execute template", logging.Error(err))
 			http.Error(wr, "Internal server error", http.StatusInternalServerError)
 
 			return
 		}
 	}
 }
+
+// ReservedConflicts returns the existing short URLs shadowed by application routes.
+func (h *Handler) ReservedConflicts(ctx context.Context) ([]string, error) {
+	return h.service.ReservedConflicts(ctx)
+}
+
+// shortenOutcome classifies the result of a shorten request for the metrics.
+func shortenOutcome(err error) string {
+	var webErr *urlshortenererror.WebError
+	switch {
+	case err == nil:
+		return metrics.ShortenCreated
+	case !errors.As(err, &webErr):
+		return metrics.ShortenError
+	case webErr.Code == http.StatusBadRequest:
+		return metrics.ShortenInvalid
+	case webErr.Code == http.StatusConflict:
+		return metrics.ShortenConflict
+	default:
+		return metrics.ShortenError
+	}
+}
@@ -0,0 +1,80 @@
+package urlshortenerhandler
+
+import (
+	"errors"
+	"html/template"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/db"
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/forwarded"
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/logging"
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/urlshortenererror"
+)
+
+// unlockCookiePrefix names the cookie holding the unlock token of a protected link.
+const unlockCookiePrefix = "pw_"
+
+var unlockTemplate = template.Must(template.ParseFiles("src/internal/views/unlock.html"))
+
+// unlock checks the password posted to a protected link. On success the
+// visitor is sent back to the link with a cookie proving the password was
+// entered.
+func (h *Handler) unlock(wr http.ResponseWriter, req *http.Request, domain *db.Domain, shortPath string) {
+	now := time.Now()
+	token, expires, err := h.service.Unlock(req.Context(), namespace(domain), shortPath, req.PostFormValue("password"), clientIP(req), now)
+	if err != nil {
+		var webErr *urlshortenererror.WebError
+		if errors.As(err, &webErr) &&
+			(errors.Is(webErr.ErrType, urlshortenererror.ErrLocked) || errors.Is(webErr.ErrType, urlshortenererror.ErrTooManyRequests)) {
+			h.showUnlockPage(wr, req, webErr.Code, webErr.Message)
+
+			return
+		}
+		h.writeWebError(wr, req, err)
+
+		return
+	}
+
+	http.SetCookie(wr, &http.Cookie{
+		Name:     unlockCookiePrefix + shortPath,
+		Value:    token,
+		Path:     "/" + shortPath,
+		MaxAge:   int(expires.Sub(now).Seconds()),
+		HttpOnly: true,
+		Secure:   forwarded.Scheme(req) == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(wr, req, req.URL.RequestURI(), http.StatusSeeOther)
+}
+
+// showUnlockPage renders the password form of a protected link.
+func (h *Handler) showUnlockPage(wr http.ResponseWriter, req *http.Request, code int, message string) {
+	wr.Header().Set("Content-Type", "text/html; charset=utf-8")
+	wr.Header().Set("Cache-Control", "no-store")
+	wr.WriteHeader(code)
+
+	data := map[string]any{
+		"Action": req.URL.RequestURI(),
+	}
+	if req.Method == http.MethodPost {
+		data["Error"] = message
+	}
+
+	if err := unlockTemplate.Execute(wr, data); err != nil {
+		h.logger.ErrorContext(req.Context(), "Failed to execute template", logging.Error(err))
+	}
+}
+
+// clientIP returns the address the request came from, used to throttle
+// password guesses. Behind trusted proxies the forwarded middleware has
+// already replaced RemoteAddr with the address of the client.
+func clientIP(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+
+	return host
+}
@@ -13,16 +13,28 @@ var (
 	ErrDBQuery = errors.New("database query error")
 	// ErrNotFound ...
 	ErrNotFound = errors.New("resource not found")
+	// ErrNotActive ...
+	ErrNotActive = errors.New("resource not active yet")
+	// ErrGone ...
+	ErrGone = errors.New("resource gone")
+	// ErrLocked ...
+	ErrLocked = errors.New("resource locked")
+	// ErrTooManyRequests ...
+	ErrTooManyRequests = errors.New("too many requests")
 	// ErrDuplicate ...
 	ErrDuplicate = errors.New("duplicate entry")
 	// ErrInvalidInput ...
 	ErrInvalidInput = errors.New("invalid input")
 	// ErrInvalidDBPort ...
 	ErrInvalidDBPort = errors.New("invalid database port")
+	// ErrInvalidConfig ...
+	ErrInvalidConfig = errors.New("invalid configuration")
 	// ErrInvalidURL ...
 	ErrInvalidURL = errors.New("invalid URL format")
 	// ErrServerError ...
 	ErrServerError = errors.New("internal server error")
+	// ErrTimeout ...
+	ErrTimeout = errors.New("operation timed out")
 )
 
 // WebError struct to hold the error details.
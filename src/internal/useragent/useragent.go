@@ -0,0 +1,121 @@
+package useragent
+
+import "strings"
+
+// Operating systems reported by Parse.
+const (
+	OSiOS      = "ios"
+	OSAndroid  = "android"
+	OSWindows  = "windows"
+	OSMacOS    = "macos"
+	OSLinux    = "linux"
+	OSChromeOS = "chromeos"
+	OSOther    = "other"
+)
+
+// Device classes reported by Parse.
+const (
+	DeviceMobile  = "mobile"
+	DeviceTablet  = "tablet"
+	DeviceDesktop = "desktop"
+	DeviceBot     = "bot"
+)
+
+// Browsers reported by Parse.
+const (
+	BrowserChrome  = "chrome"
+	BrowserFirefox = "firefox"
+	BrowserSafari  = "safari"
+	BrowserEdge    = "edge"
+	BrowserOpera   = "opera"
+	BrowserSamsung = "samsung"
+	BrowserOther   = "other"
+)
+
+// UserAgent holds the client details read from a User-Agent header.
+type UserAgent struct {
+	OS      string
+	Device  string
+	Browser string
+}
+
+// OSes lists the operating systems a targeting rule can match.
+var OSes = []string{OSiOS, OSAndroid, OSWindows, OSMacOS, OSLinux, OSChromeOS, OSOther}
+
+// Devices lists the device classes a targeting rule can match.
+var Devices = []string{DeviceMobile, DeviceTablet, DeviceDesktop, DeviceBot}
+
+// Browsers lists the browsers a targeting rule can match.
+var Browsers = []string{BrowserChrome, BrowserFirefox, BrowserSafari, BrowserEdge, BrowserOpera, BrowserSamsung, BrowserOther}
+
+var botMarkers = []string{"bot", "crawler", "spider", "slurp", "facebookexternalhit", "curl/", "wget/", "python-requests"}
+
+// Parse reads the operating system, device class and browser from a User-Agent header.
+// Unknown values are reported as other, and a missing header as a desktop.
+func Parse(header string) UserAgent {
+	lower := strings.ToLower(header)
+
+	return UserAgent{
+		OS:      parseOS(header),
+		Device:  parseDevice(header, lower),
+		Browser: parseBrowser(header),
+	}
+}
+
+func parseOS(header string) string {
+	switch {
+	case strings.Contains(header, "iPhone"), strings.Contains(header, "iPad"), strings.Contains(header, "iPod"):
+		return OSiOS
+	case strings.Contains(header, "Android"):
+		return OSAndroid
+	case strings.Contains(header, "Windows"):
+		return OSWindows
+	case strings.Contains(header, "CrOS"):
+		return OSChromeOS
+	case strings.Contains(header, "Macintosh"), strings.Contains(header, "Mac OS X"):
+		return OSMacOS
+	case strings.Contains(header, "Linux"), strings.Contains(header, "X11"):
+		return OSLinux
+	default:
+		return OSOther
+	}
+}
+
+func parseDevice(header, lower string) string {
+	for _, marker := range botMarkers {
+		if strings.Contains(lower, marker) {
+			return DeviceBot
+		}
+	}
+
+	switch {
+	case strings.Contains(header, "iPad"), strings.Contains(lower, "tablet"),
+		strings.Contains(header, "Android") && !strings.Contains(header, "Mobile"):
+		return DeviceTablet
+	case strings.Contains(header, "Mobi"), strings.Contains(header, "iPhone"), strings.Contains(header, "iPod"):
+		return DeviceMobile
+	default:
+		return DeviceDesktop
+	}
+}
+
+// parseBrowser checks the most specific tokens first, since most browsers
+// also claim to be Chrome or Safari.
+func parseBrowser(header string) string {
+	switch {
+	case strings.Contains(header, "Edg/"), strings.Contains(header, "EdgA/"), strings.Contains(header, "EdgiOS/"):
+		return BrowserEdge
+	case strings.Contains(header, "OPR/"), strings.Contains(header, "Opera"):
+		return BrowserOpera
+	case strings.Contains(header, "SamsungBrowser/"):
+		return BrowserSamsung
+	case strings.Contains(header, "Firefox/"), strings.Contains(header, "FxiOS/"):
+		return BrowserFirefox
+	case strings.Contains(header, "Chrome/"), strings.Contains(header, "CriOS/"):
+		return BrowserChrome
+	case strings.Contains(header, "Safari/"):
+		return BrowserSafari
+	default:
+		return BrowserOther
+	}
+}
@@ -0,0 +1,70 @@
+package useragent_test
+
+import (
+	"testing"
+
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/useragent"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   string
+		expected useragent.UserAgent
+	}{
+		{
+			name:     "iPhone Safari",
+			header:   "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Mobile/15E148 Safari/604.1",
+			expected: useragent.UserAgent{OS: useragent.OSiOS, Device: useragent.DeviceMobile, Browser: useragent.BrowserSafari},
+		},
+		{
+			name:     "iPad Chrome",
+			header:   "Mozilla/5.0 (iPad; CPU OS 16_6 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) CriOS/118.0.5993.69 Mobile/15E148 Safari/604.1",
+			expected: useragent.UserAgent{OS: useragent.OSiOS, Device: useragent.DeviceTablet, Browser: useragent.BrowserChrome},
+		},
+		{
+			name:     "Android phone Samsung Internet",
+			header:   "Mozilla/5.0 (Linux; Android 13; SM-S901B) AppleWebKit/537.36 (KHTML, like Gecko) SamsungBrowser/22.0 Chrome/111.0.5563.116 Mobile Safari/537.36",
+			expected: useragent.UserAgent{OS: useragent.OSAndroid, Device: useragent.DeviceMobile, Browser: useragent.BrowserSamsung},
+		},
+		{
+			name:     "Android tablet Chrome",
+			header:   "Mozilla/5.0 (Linux; Android 13; SM-X700) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/118.0.0.0 Safari/537.36",
+			expected: useragent.UserAgent{OS: useragent.OSAndroid, Device: useragent.DeviceTablet, Browser: useragent.BrowserChrome},
+		},
+		{
+			name:     "Windows Edge",
+			header:   "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/118.0.0.0 Safari/537.36 Edg/118.0.2088.46",
+			expected: useragent.UserAgent{OS: useragent.OSWindows, Device: useragent.DeviceDesktop, Browser: useragent.BrowserEdge},
+		},
+		{
+			name:     "macOS Firefox",
+			header:   "Mozilla/5.0 (Macintosh; Intel Mac OS X 14.0; rv:109.0) Gecko/20100101 Firefox/118.0",
+			expected: useragent.UserAgent{OS: useragent.OSMacOS, Device: useragent.DeviceDesktop, Browser: useragent.BrowserFirefox},
+		},
+		{
+			name:     "Linux Opera",
+			header:   "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/118.0.0.0 Safari/537.36 OPR/104.0.0.0",
+			expected: useragent.UserAgent{OS: useragent.OSLinux, Device: useragent.DeviceDesktop, Browser: useragent.BrowserOpera},
+		},
+		{
+			name:     "Search engine bot",
+			header:   "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+			expected: useragent.UserAgent{OS: useragent.OSOther, Device: useragent.DeviceBot, Browser: useragent.BrowserOther},
+		},
+		{
+			name:     "Empty header",
+			header:   "",
+			expected: useragent.UserAgent{OS: useragent.OSOther, Device: useragent.DeviceDesktop, Browser: useragent.BrowserOther},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := useragent.Parse(tt.header)
+			if result != tt.expected {
+				t.Errorf("Expected %+v, got %+v", tt.expected, result)
+			}
+		})
+	}
+}
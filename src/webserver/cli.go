@@ -0,0 +1,139 @@
+package webserver
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/config"
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/forwarded"
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/logging"
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/service/urlshortenerservice"
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/tracing"
+)
+
+// Exit codes of Run.
+const (
+	ExitOK            = 0
+	ExitFailure       = 1
+	ExitUsage         = 2
+	ExitInvalidConfig = 3
+)
+
+// Run is the command line of the server. Without a command it loads the
+// configuration from the file, the environment and the flags in args and
+// serves until it is stopped. config print writes the merged configuration
+// with its secrets redacted instead, followed by its problems, so a broken
+// configuration can be inspected. It returns the exit code.
+func Run(args []string, stdout, stderr io.Writer) int {
+	printConfig := false
+	if len(args) > 0 && args[0] == "config" {
+		if len(args) < 2 || args[1] != "print" {
+			fmt.Fprintln(stderr, "usage: web-app config print [flags]")
+
+			return ExitUsage
+		}
+		printConfig, args = true, args[2:]
+	}
+
+	cfg, err := config.Load(args, os.LookupEnv, checkConfig)
+	switch {
+	case errors.Is(err, flag.ErrHelp):
+		fmt.Fprintln(stderr, "usage: web-app [flags]\n       web-app config print [flags]\n\nflags:")
+		config.Usage(stderr)
+
+		return ExitOK
+	case cfg == nil:
+		reportConfigError(stderr, err)
+
+		return ExitUsage
+	}
+
+	if printConfig {
+		if printErr := cfg.Print(stdout); printErr != nil {
+			fmt.Fprintln(stderr, printErr)
+
+			return ExitFailure
+		}
+	}
+	if err != nil {
+		reportConfigError(stderr, err)
+
+		return ExitInvalidConfig
+	}
+	if printConfig {
+		return ExitOK
+	}
+
+	if err = New(WithConfig(cfg)); err != nil {
+		fmt.Fprintln(stderr, "Failed to start server:", err)
+
+		return ExitFailure
+	}
+
+	return ExitOK
+}
+
+// reportConfigError writes every configuration problem on its own line.
+func reportConfigError(w io.Writer, err error) {
+	var problems config.Problems
+	if !errors.As(err, &problems) {
+		fmt.Fprintln(w, err)
+
+		return
+	}
+
+	fmt.Fprintln(w, "invalid configuration:")
+	for _, problem := range problems {
+		fmt.Fprintln(w, "  -", problem)
+	}
+}
+
+// checkConfig validates the settings whose values are defined by the
+// packages using them.
+func checkConfig(cfg *config.Config) config.Problems {
+	var problems config.Problems
+
+	if _, err := forwarded.ParseTrusted(cfg.TrustedProxies); err != nil {
+		problems = append(problems, "TRUSTED_PROXIES: "+err.Error())
+	}
+
+	if !urlshortenerservice.IsRedirectCode(cfg.RedirectCode) {
+		problems = append(problems, "REDIRECT_CODE: must be one of 301, 302, 307 or 308")
+	}
+	if cfg.RedirectCacheControl != "" {
+		if err := urlshortenerservice.ValidateCacheControl(cfg.RedirectCacheControl); err != nil {
+			problems = append(problems, "REDIRECT_CACHE_CONTROL: "+err.Error())
+		}
+	}
+
+	switch cfg.LogFormat {
+	case "", logging.FormatJSON, logging.FormatText:
+	default:
+		problems = append(problems, "LOG_FORMAT: must be json or text")
+	}
+
+	if _, err := logging.ParseLevel(cfg.LogLevel); err != nil {
+		problems = append(problems, "LOG_LEVEL: must be debug, info, warn or error")
+	}
+
+	switch cfg.AccessLogFormat {
+	case "", logging.AccessLogJSON, logging.AccessLogCombined, logging.AccessLogOff:
+	default:
+		problems = append(problems, "ACCESS_LOG_FORMAT: must be json, combined or off")
+	}
+
+	switch cfg.TraceExporter {
+	case "", tracing.ExporterNone, tracing.ExporterStdout:
+	case tracing.ExporterOTLP:
+		if cfg.OTLPEndpoint == "" {
+			problems = append(problems, "OTEL_EXPORTER_OTLP_ENDPOINT: must be set for the otlp trace exporter")
+		}
+	default:
+		problems = append(problems, "TRACE_EXPORTER: must be none, stdout or otlp")
+	}
+
+	return problems
+}
@@ -2,37 +2,107 @@ package webserver
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
+	"go.opentelemetry.io/otel"
+
 	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/config"
 	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/db"
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/forwarded"
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/health"
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/linkhealth"
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/logging"
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/metrics"
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/reserved"
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/service/urlshortenerservice"
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/tlsserver"
+	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/tracing"
 	"github.com/tberk-s/learning-url-shortener-with-go/src/internal/transport/http/urlshortenerhandler"
 )
 
-// Constants for default server settings.
-const (
-	DefaultReadTimeout     = 10 * time.Second
-	DefaultWriteTimeout    = 10 * time.Second
-	DefaultIdleTimeout     = 15 * time.Second
-	DefaultShutdownTimeout = 10 * time.Second
-)
-
 // WebServer represents the web server instance.
 type WebServer struct {
 	config *config.Config
 	db     *db.DB
-	logger *log.Logger // Add this
+	logger *slog.Logger
 }
 
 // Option type for functional options.
 type Option func(*WebServer)
 
+// WithConfig replaces the whole configuration, usually one built by
+// config.Load. Options after it change single settings of it.
+func WithConfig(cfg *config.Config) Option {
+	return func(s *WebServer) {
+		copied := *cfg
+		s.config = &copied
+	}
+}
+
+// WithListenAddr sets the address the HTTP server listens on.
+func WithListenAddr(addr string) Option {
+	return func(s *WebServer) {
+		s.config.ListenAddr = addr
+	}
+}
+
+// WithBaseURL sets the public URL short URLs are built on. Without one they
+// use the scheme and host of each request.
+func WithBaseURL(baseURL string) Option {
+	return func(s *WebServer) {
+		s.config.BaseURL = baseURL
+	}
+}
+
+// WithTLS serves HTTPS with the certificate and key in the PEM files, which
+// are reloaded when they change.
+func WithTLS(certFile, keyFile string) Option {
+	return func(s *WebServer) {
+		s.config.TLSCertFile = certFile
+		s.config.TLSKeyFile = keyFile
+	}
+}
+
+// WithTLSClientCAFile sets the CAs whose client certificates the admin API requires.
+func WithTLSClientCAFile(file string) Option {
+	return func(s *WebServer) {
+		s.config.TLSClientCAFile = file
+	}
+}
+
+// WithHTTPRedirectAddr sets the address of a listener redirecting HTTP
+// requests to HTTPS.
+func WithHTTPRedirectAddr(addr string) Option {
+	return func(s *WebServer) {
+		s.config.HTTPRedirectAddr = addr
+	}
+}
+
+// WithHSTS sets the max-age of the Strict-Transport-Security header of HTTPS
+// responses, 0 disables it.
+func WithHSTS(maxAge time.Duration, includeSubdomains bool) Option {
+	return func(s *WebServer) {
+		s.config.HSTSMaxAge = maxAge
+		s.config.HSTSIncludeSubdomains = includeSubdomains
+	}
+}
+
+// WithTrustedProxies sets the addresses and networks of the reverse proxies
+// whose forwarded headers are believed.
+func WithTrustedProxies(proxies []string) Option {
+	return func(s *WebServer) {
+		s.config.TrustedProxies = proxies
+	}
+}
+
 // WithDBName sets the database name.
 func WithDBName(name string) Option {
 	return func(s *WebServer) {
@@ -75,29 +145,208 @@ func WithDBPort(port int) Option {
 	}
 }
 
-// New creates a new WebServer instance.
-func New(opts ...Option) error {
-	cfg, err := config.LoadConfig()
-	if err != nil {
-		return fmt.Errorf("failed to load configuration: %w", err)
+// WithRedirectCode sets the default status code of redirects.
+func WithRedirectCode(code int) Option {
+	return func(s *WebServer) {
+		s.config.RedirectCode = code
+	}
+}
+
+// WithRedirectCacheControl sets the default Cache-Control header of redirects.
+func WithRedirectCacheControl(cacheControl string) Option {
+	return func(s *WebServer) {
+		s.config.RedirectCacheControl = cacheControl
+	}
+}
+
+// WithSecretKey sets the key signing the cookies of unlocked password protected links.
+func WithSecretKey(key string) Option {
+	return func(s *WebServer) {
+		s.config.SecretKey = key
+	}
+}
+
+// WithAdminToken sets the bearer token of the admin API, which is only
+// served with one.
+func WithAdminToken(token string) Option {
+	return func(s *WebServer) {
+		s.config.AdminToken = token
+	}
+}
+
+// WithInterstitialDomains sets the domains whose destinations are shown behind a warning page.
+func WithInterstitialDomains(domains []string) Option {
+	return func(s *WebServer) {
+		s.config.InterstitialDomains = domains
+	}
+}
+
+// WithInternalDomains sets the domains of the organization. Destinations on
+// any other domain are shown behind a warning page.
+func WithInternalDomains(domains []string) Option {
+	return func(s *WebServer) {
+		s.config.InternalDomains = domains
+	}
+}
+
+// WithInterstitialUnsafe shows the warning page for destinations failing the safety checks.
+func WithInterstitialUnsafe(unsafe bool) Option {
+	return func(s *WebServer) {
+		s.config.InterstitialUnsafe = unsafe
+	}
+}
+
+// WithInterstitialCountdown sets the seconds before the warning page continues to the destination.
+func WithInterstitialCountdown(seconds int) Option {
+	return func(s *WebServer) {
+		s.config.InterstitialCountdown = seconds
+	}
+}
+
+// WithHealthCheckInterval sets how often link destinations are probed, 0 disables the checks.
+func WithHealthCheckInterval(interval time.Duration) Option {
+	return func(s *WebServer) {
+		s.config.HealthCheckInterval = interval
+	}
+}
+
+// WithHealthCheckConcurrency sets how many hosts are probed at the same time.
+func WithHealthCheckConcurrency(concurrency int) Option {
+	return func(s *WebServer) {
+		s.config.HealthCheckConcurrency = concurrency
+	}
+}
+
+// WithHealthCheckFailures sets the number of failed checks in a row after which links use their fallback.
+func WithHealthCheckFailures(failures int) Option {
+	return func(s *WebServer) {
+		s.config.HealthCheckFailures = failures
+	}
+}
+
+// WithReservedCodes sets words that cannot be used as short codes, in
+// addition to the paths of the application routes.
+func WithReservedCodes(codes []string) Option {
+	return func(s *WebServer) {
+		s.config.ReservedCodes = codes
+	}
+}
+
+// WithLogFormat sets the output format of the logs, json or text.
+func WithLogFormat(format string) Option {
+	return func(s *WebServer) {
+		s.config.LogFormat = format
+	}
+}
+
+// WithLogLevel sets the lowest level that is logged.
+func WithLogLevel(level string) Option {
+	return func(s *WebServer) {
+		s.config.LogLevel = level
+	}
+}
+
+// WithAccessLogFormat sets the format of the access log, json, combined or off.
+func WithAccessLogFormat(format string) Option {
+	return func(s *WebServer) {
+		s.config.AccessLogFormat = format
+	}
+}
+
+// WithDBReadTimeout sets the deadline of database reads, 0 disables it.
+func WithDBReadTimeout(timeout time.Duration) Option {
+	return func(s *WebServer) {
+		s.config.DBReadTimeout = timeout
+	}
+}
+
+// WithDBWriteTimeout sets the deadline of database writes, 0 disables it.
+func WithDBWriteTimeout(timeout time.Duration) Option {
+	return func(s *WebServer) {
+		s.config.DBWriteTimeout = timeout
+	}
+}
+
+// WithShutdownDrainDelay sets how long the server keeps serving after it
+// reports as not ready on shutdown.
+func WithShutdownDrainDelay(delay time.Duration) Option {
+	return func(s *WebServer) {
+		s.config.ShutdownDrainDelay = delay
+	}
+}
+
+// WithTraceExporter sets where spans are sent: none, stdout or otlp.
+func WithTraceExporter(exporter string) Option {
+	return func(s *WebServer) {
+		s.config.TraceExporter = exporter
+	}
+}
+
+// WithOTLPEndpoint sets the collector the otlp trace exporter sends spans to.
+func WithOTLPEndpoint(endpoint string) Option {
+	return func(s *WebServer) {
+		s.config.OTLPEndpoint = endpoint
 	}
+}
+
+// WithTraceSampleRatio sets the share of new traces that are recorded.
+func WithTraceSampleRatio(ratio float64) Option {
+	return func(s *WebServer) {
+		s.config.TraceSampleRatio = ratio
+	}
+}
+
+// WithLogger sets the logger used by every layer, replacing the one built
+// from the log format and level.
+func WithLogger(logger *slog.Logger) Option {
+	return func(s *WebServer) {
+		s.logger = logger
+	}
+}
+
+// New creates a new WebServer instance. Settings that no option sets keep
+// their defaults, the server does not read the environment itself.
+func New(opts ...Option) error {
 	ws := &WebServer{
-		config: cfg,
+		config: config.Default(),
 	}
-	ws.logger = log.New(os.Stdout, "[URL-Shortener] ", log.LstdFlags|log.Lshortfile)
 	for _, opt := range opts {
 		opt(ws)
 	}
-	if ws.config.ServerEnv == "" {
-		ws.config.ServerEnv = "development"
+	if err := ws.config.Validate(checkConfig); err != nil {
+		return err
 	}
 
-	database, err := db.New(
-		ws.config.DBUser,
-		ws.config.DBPassword,
-		ws.config.DBHost,
-		ws.config.DBName,
-		ws.config.DBPort,
+	var err error
+	if ws.logger == nil {
+		level, _ := logging.ParseLevel(ws.config.LogLevel)
+		if ws.logger, err = logging.New(os.Stdout, ws.config.LogFormat, level); err != nil {
+			return fmt.Errorf("failed to create logger: %w", err)
+		}
+	}
+	// Code logging through the standard log package or slog.Default writes to the same output.
+	slog.SetDefault(ws.logger)
+
+	tracer, err := ws.newTracer(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to create tracer: %w", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), ws.config.ShutdownTimeout)
+		defer cancel()
+		if shutdownErr := tracer.Shutdown(ctx); shutdownErr != nil {
+			ws.logger.Error("Failed to export the remaining spans", logging.Error(shutdownErr))
+		}
+	}()
+
+	database, err := db.Open(
+		ws.config.DSN(),
+		db.WithLogger(ws.logger),
+		db.WithTracer(tracer.Tracer()),
+		db.WithReadTimeout(ws.config.DBReadTimeout),
+		db.WithWriteTimeout(ws.config.DBWriteTimeout),
+		db.WithMaxConns(ws.config.DBMaxConns),
+		db.WithMinConns(ws.config.DBMinConns),
 	)
 
 	if err != nil {
@@ -106,66 +355,315 @@ func New(opts ...Option) error {
 
 	ws.db = database
 
-	urlHandler, err := urlshortenerhandler.New(ws.db)
+	if err = ws.db.Migrate(context.Background()); err != nil {
+		ws.db.Close()
+
+		return fmt.Errorf("failed to migrate database: %w", err)
+	}
+
+	if ws.config.SecretKey == "" {
+		ws.logger.Warn("SECRET_KEY is not set, unlocked password protected links will be locked again after a restart")
+	}
+
+	// The registry is filled with the routes below before the server starts.
+	reservedCodes := reserved.New(ws.config.ReservedCodes...)
+
+	// Without metrics the nil collector leaves the handlers uninstrumented.
+	var appMetrics *metrics.Metrics
+	if ws.config.MetricsEnabled {
+		appMetrics = metrics.New()
+	}
+	appMetrics.RegisterDBStats(ws.db.PoolStats)
+
+	urlHandler, err := urlshortenerhandler.New(
+		ws.db,
+		urlshortenerhandler.WithReserved(reservedCodes),
+		urlshortenerhandler.WithMetrics(appMetrics),
+		urlshortenerhandler.WithLogger(ws.logger),
+		urlshortenerhandler.WithTracer(tracer.Tracer()),
+		urlshortenerhandler.WithSecret([]byte(ws.config.SecretKey)),
+		urlshortenerhandler.WithAdminToken(ws.config.AdminToken),
+		urlshortenerhandler.WithAdminClientCert(ws.config.TLSClientCAFile != ""),
+		urlshortenerhandler.WithInterstitialPolicy(urlshortenerservice.InterstitialPolicy{
+			Domains:         ws.config.InterstitialDomains,
+			InternalDomains: ws.config.InternalDomains,
+			Unsafe:          ws.config.InterstitialUnsafe,
+		}),
+		urlshortenerhandler.WithInterstitialCountdown(ws.config.InterstitialCountdown),
+		urlshortenerhandler.WithFallbackAfter(ws.config.HealthCheckFailures),
+		urlshortenerhandler.WithCodeLength(ws.config.ShortCodeLength),
+		urlshortenerhandler.WithBaseURL(ws.config.BaseURL),
+		urlshortenerhandler.WithRedirectCode(ws.config.RedirectCode),
+		urlshortenerhandler.WithRedirectCacheControl(ws.config.RedirectCacheControl),
+	)
 	if err != nil {
 		return fmt.Errorf("failed to create URL handler: %w", err)
 	}
 
 	mux := http.NewServeMux()
+	// Every route reserves its first path segment, so no short code can be shadowed by it.
+	handle := func(pattern string, handler http.Handler) {
+		mux.Handle(pattern, appMetrics.Instrument(pattern, handler))
+		reservedCodes.AddPattern(pattern)
+	}
 	fs := http.FileServer(http.Dir("src/internal/static"))
-	mux.Handle("/static/", http.StripPrefix("/static/", fs))
-	mux.HandleFunc("/shorten", urlHandler.ShowShortenPage())
-	mux.HandleFunc("/home", urlshortenerhandler.ShowHomePage) // Move home page to explicit path
-	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		if r.URL.Path == "/" {
+	handle("/static/", http.StripPrefix("/static/", fs))
+	handle("/shorten", urlHandler.ShowShortenPage())
+	handle("/api/shorten", urlHandler.ShortenAPI())
+	if ws.config.PreviewsEnabled {
+		handle("GET /api/links/{code}", urlHandler.PreviewAPI())
+	}
+	handle("/api/expand", urlHandler.ExpandAPI())
+	if ws.config.AdminToken != "" {
+		handle("GET /api/domains", urlHandler.RequireAdmin(urlHandler.ListDomainsAPI()))
+		handle("PUT /api/domains/{host}", urlHandler.RequireAdmin(urlHandler.SaveDomainAPI()))
+		handle("DELETE /api/domains/{host}", urlHandler.RequireAdmin(urlHandler.DeleteDomainAPI()))
+	}
+	handle("/home", urlHandler.ShowHomePage()) // Move home page to explicit path
+	if ws.config.MetricsEnabled {
+		// The metrics show the internals of the connection pool, they are
+		// kept to the admins when there are any.
+		metricsHandler := appMetrics.Handler()
+		if ws.config.AdminToken != "" {
+			metricsHandler = urlHandler.RequireAdmin(metricsHandler)
+		}
+		handle("GET /metrics", metricsHandler)
+	}
+
+	probe := health.New(
+		health.WithLogger(ws.logger),
+		health.WithCheck("database", ws.db.Ping),
+		health.WithCheck("migrations", func(ctx context.Context) error {
+			pending, pendingErr := ws.db.PendingMigrations(ctx)
+			if pendingErr == nil && len(pending) > 0 {
+				pendingErr = fmt.Errorf("%d pending migrations, first %s", len(pending), pending[0])
+			}
+
+			return pendingErr
+		}),
+	)
+	handle("GET /healthz", probe.Live())
+	handle("GET /readyz", probe.Ready())
+
+	// The catch-all route is measured per kind of request it serves.
+	qrCodeRoute := appMetrics.Instrument("/{code}"+urlshortenerhandler.QRCodeSuffix, urlHandler.ShowQRCode())
+	previewRoute := appMetrics.Instrument("/{code}"+urlshortenerhandler.PreviewSuffix, urlHandler.ShowPreviewPage())
+	redirectRoute := appMetrics.Instrument("/{code}", urlHandler.RedirectHandler())
+	mux.Handle("/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Suffixes only apply to bare short URLs, longer paths are passed through.
+		bareCode := !strings.Contains(r.URL.Path[1:], "/")
+
+		switch {
+		case r.URL.Path == "/":
 			http.Redirect(w, r, "/home", http.StatusPermanentRedirect)
-		} else {
-			urlshortenerhandler.RedirectHandler(ws.db)(w, r)
+		case bareCode && strings.HasSuffix(r.URL.Path, urlshortenerhandler.QRCodeSuffix):
+			qrCodeRoute.ServeHTTP(w, r)
+		case ws.config.PreviewsEnabled && bareCode && strings.HasSuffix(r.URL.Path, urlshortenerhandler.PreviewSuffix):
+			previewRoute.ServeHTTP(w, r)
+		default:
+			redirectRoute.ServeHTTP(w, r)
 		}
-	})
+	}))
+
+	conflicts, err := urlHandler.ReservedConflicts(context.Background())
+	if err != nil {
+		ws.logger.Error("Failed to check short URLs against reserved routes", logging.Error(err))
+	}
+	for _, code := range conflicts {
+		ws.logger.Warn("Short URL is shadowed by an application route and cannot be reached", "short_url", code)
+	}
+
+	// The access log runs inside the logging middleware to see the request ID,
+	// the tracing middleware between them adds the trace ID to the logs.
+	accessLog, err := logging.AccessLog(os.Stdout, ws.config.AccessLogFormat, mux)
+	if err != nil {
+		return fmt.Errorf("failed to create access log: %w", err)
+	}
+
+	// Validated with the configuration.
+	trustedProxies, _ := forwarded.ParseTrusted(ws.config.TrustedProxies)
+
+	hsts := tlsserver.HSTS{MaxAge: ws.config.HSTSMaxAge, IncludeSubdomains: ws.config.HSTSIncludeSubdomains}
 
 	webServer := &http.Server{
-		Addr:         ":8000",
-		Handler:      mux,
-		ReadTimeout:  DefaultReadTimeout,
-		WriteTimeout: DefaultWriteTimeout,
-		IdleTimeout:  DefaultIdleTimeout,
+		Addr: ws.config.ListenAddr,
+		// Forwarded headers are applied first, so every layer sees the client.
+		Handler:      trustedProxies.Middleware(hsts.Middleware(logging.Middleware(tracer.Middleware(accessLog)))),
+		ErrorLog:     slog.NewLogLogger(ws.logger.Handler(), slog.LevelError),
+		ReadTimeout:  ws.config.ReadTimeout,
+		WriteTimeout: ws.config.WriteTimeout,
+		IdleTimeout:  ws.config.IdleTimeout,
+	}
+
+	checkCtx, stopChecks := context.WithCancel(context.Background())
+	defer stopChecks()
+
+	if ws.config.TLSEnabled() {
+		if webServer.TLSConfig, err = ws.newTLSConfig(checkCtx); err != nil {
+			ws.db.Close()
+
+			return err
+		}
+	}
+
+	// The redirect listener is optional, the server stays nil without one.
+	var redirectServer *http.Server
+	if ws.config.HTTPRedirectAddr != "" {
+		redirectServer = &http.Server{
+			Addr:         ws.config.HTTPRedirectAddr,
+			Handler:      tlsserver.RedirectHandler(ws.config.ListenAddr),
+			ErrorLog:     webServer.ErrorLog,
+			ReadTimeout:  ws.config.ReadTimeout,
+			WriteTimeout: ws.config.WriteTimeout,
+			IdleTimeout:  ws.config.IdleTimeout,
+		}
+	}
+
+	if ws.config.HealthCheckInterval > 0 {
+		checker := linkhealth.New(
+			ws.db,
+			linkhealth.WithInterval(ws.config.HealthCheckInterval),
+			linkhealth.WithConcurrency(ws.config.HealthCheckConcurrency),
+			linkhealth.WithLogger(ws.logger),
+		)
+		go checker.Run(checkCtx)
 	}
 
 	shutdown := make(chan os.Signal, 1)
-	webError := make(chan error)
+	// Room for an error of each listener, so neither blocks after the first.
+	webError := make(chan error, 2)
 	signal.Notify(shutdown, os.Interrupt, syscall.SIGTERM)
 
 	go func() {
-		log.Println("Starting API server on", webServer.Addr)
-		if serverErr := webServer.ListenAndServe(); serverErr != nil {
+		ws.logger.Info("Starting API server", "addr", webServer.Addr, "base_url", ws.config.BaseURL, "tls", ws.config.TLSEnabled())
+
+		var serverErr error
+		if webServer.TLSConfig != nil {
+			// The certificate comes from TLSConfig.GetCertificate.
+			serverErr = webServer.ListenAndServeTLS("", "")
+		} else {
+			serverErr = webServer.ListenAndServe()
+		}
+		if serverErr != nil {
 			webError <- serverErr
 		}
 	}()
 
+	if redirectServer != nil {
+		go func() {
+			ws.logger.Info("Starting HTTP to HTTPS redirect", "addr", redirectServer.Addr)
+			if serverErr := redirectServer.ListenAndServe(); serverErr != nil {
+				webError <- serverErr
+			}
+		}()
+	}
+
 	select {
 	case serverErr := <-webError:
-		log.Printf("Server error: %v", err)
+		ws.logger.Error("Server error", logging.Error(serverErr))
+		stopChecks()
+		// The other listener may still be serving.
+		for _, server := range []*http.Server{webServer, redirectServer} {
+			if server == nil {
+				continue
+			}
+			if closeErr := server.Close(); closeErr != nil {
+				ws.logger.Error("Failed to close server", "addr", server.Addr, logging.Error(closeErr))
+			}
+		}
 		ws.db.Close()
 
 		return serverErr
 
 	case sig := <-shutdown:
-		log.Printf("Received shutdown signal: %s", sig)
+		ws.logger.Info("Received shutdown signal", "signal", sig.String())
 
-		ctx, cancel := context.WithTimeout(context.Background(), DefaultShutdownTimeout)
+		// Failing readiness first lets load balancers move traffic away
+		// while requests are still served.
+		probe.StartDraining()
+		if ws.config.ShutdownDrainDelay > 0 {
+			ws.logger.Info("Draining before shutdown", "delay", ws.config.ShutdownDrainDelay.String())
+			select {
+			case <-time.After(ws.config.ShutdownDrainDelay):
+			case <-shutdown:
+				ws.logger.Info("Received second shutdown signal, skipping the drain delay")
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), ws.config.ShutdownTimeout)
 		defer cancel()
 
+		if redirectServer != nil {
+			if shutdownErr := redirectServer.Shutdown(ctx); shutdownErr != nil {
+				ws.logger.Error("Graceful shutdown of the HTTP redirect failed", logging.Error(shutdownErr))
+			}
+		}
 		if shutdownErr := webServer.Shutdown(ctx); shutdownErr != nil {
-			log.Printf("Graceful shutdown failed, forcing server close: %v", shutdownErr)
+			ws.logger.Error("Graceful shutdown failed, forcing server close", logging.Error(shutdownErr))
 		}
+		stopChecks()
 
 		ws.db.Close() // Close DB after successful shutdown
 		if ctx.Err() != nil {
-			log.Printf("Shutdown timed out: %v", ctx.Err())
+			ws.logger.Error("Shutdown timed out", logging.Error(ctx.Err()))
 		}
-		log.Println("Server shutdown gracefully")
+		ws.logger.Info("Server shutdown gracefully")
 	}
 
 	return nil
 }
+
+// newTLSConfig loads the certificate, reloaded from its files until the
+// context is cancelled, and the CAs of the client certificates of the admin
+// API. Clients are asked for a certificate but only the admin API requires one.
+func (ws *WebServer) newTLSConfig(ctx context.Context) (*tls.Config, error) {
+	reloader, err := tlsserver.New(
+		ws.config.TLSCertFile,
+		ws.config.TLSKeyFile,
+		tlsserver.WithInterval(ws.config.TLSReloadInterval),
+		tlsserver.WithLogger(ws.logger),
+	)
+	if err != nil {
+		return nil, err
+	}
+	go reloader.Run(ctx)
+
+	tlsConfig := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+	}
+
+	if ws.config.TLSClientCAFile != "" {
+		if tlsConfig.ClientCAs, err = tlsserver.LoadCertPool(ws.config.TLSClientCAFile); err != nil {
+			return nil, fmt.Errorf("failed to load client CAs: %w", err)
+		}
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+
+	return tlsConfig, nil
+}
+
+// newTracer creates the tracer provider of the configured exporter, or nil
+// when tracing is disabled. Export errors are logged.
+func (ws *WebServer) newTracer(ctx context.Context) (*tracing.Provider, error) {
+	exporter, err := tracing.NewExporter(ctx, ws.config.TraceExporter, ws.config.OTLPEndpoint, os.Stdout)
+	if err != nil || exporter == nil {
+		return nil, err
+	}
+
+	serviceName := ws.config.TraceServiceName
+	if serviceName == "" {
+		serviceName = tracing.DefaultServiceName
+	}
+
+	// The SDK reports export failures through its global error handler.
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		ws.logger.Error("Failed to export spans", logging.Error(err))
+	}))
+
+	return tracing.New(ctx,
+		tracing.WithExporter(exporter),
+		tracing.WithServiceName(serviceName),
+		tracing.WithSampleRatio(ws.config.TraceSampleRatio),
+	)
+}